@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/faroshq/faros-ingress/pkg/config"
 	"github.com/faroshq/faros-ingress/pkg/connector"
@@ -21,7 +24,9 @@ func main() {
 	flag.Parse()
 	flag.Lookup("v").Value.Set("6")
 
-	ctx := context.Background()
+	// connector finishes requests in flight on termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	err := run(ctx)
 	if err != nil {
@@ -34,6 +39,10 @@ func run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if config.TunnelsFile != "" {
+		connector.NewSupervisor(config).Run(ctx)
+		return nil
+	}
 	return runClient(ctx, config)
 }
 
@@ -42,7 +51,7 @@ func runClient(ctx context.Context, config *config.ConnectorConfig) error {
 	if err != nil {
 		return err
 	}
-	go client.Run(ctx)
-	<-ctx.Done()
+	// returns once the connection is disconnected remotely
+	client.Run(ctx)
 	return nil
 }
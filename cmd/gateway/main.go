@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"k8s.io/klog/v2"
 
@@ -16,7 +18,9 @@ func main() {
 
 	flag.Parse()
 
-	ctx := context.Background()
+	// gateway drains tunnels and requests on termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	ctx = klog.NewContext(ctx, klog.NewKlogr())
 
 	err := run(ctx)
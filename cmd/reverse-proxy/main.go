@@ -53,7 +53,7 @@ func run(ctx context.Context) error {
 }
 
 func runServer(ctx context.Context) error {
-	server, err := devproxyserver.New(*serverAddress, *certFile, *keyFile)
+	server, err := devproxyserver.New(*serverAddress, *certFile, *keyFile, *clientID)
 	if err != nil {
 		return err
 	}
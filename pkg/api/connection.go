@@ -6,6 +6,12 @@ const ConnectionClientHeader = "Faros-Connection-Client"
 const ConnectionClientValue = "connector"
 const ConnectionProxyValue = "proxy"
 
+// DefaultAPIKeyHeader is the header api-key credentials are read from if none is set
+const DefaultAPIKeyHeader = "X-Faros-Api-Key"
+
+// GatewayPingPath is the gateway endpoint connectors measure latency against
+const GatewayPingPath = "/api/v1alpha1/ping"
+
 type ConnectionState string
 
 var (
@@ -20,6 +26,7 @@ type Connection struct {
 	LastUsed time.Time       `json:"lastUsed,omitempty" yaml:"lastUsed,omitempty"`
 	TTL      time.Duration   `json:"ttl,omitempty" yaml:"ttl,omitempty"`
 	State    ConnectionState `json:"state,omitempty" yaml:"state,omitempty"`
+	Replicas int             `json:"replicas,omitempty" yaml:"replicas,omitempty"`
 
 	Token    string `json:"token,omitempty" yaml:"token,omitempty"`
 	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
@@ -27,6 +34,69 @@ type Connection struct {
 	Secure   bool   `json:"secure,omitempty" yaml:"secure,omitempty"`
 	Username string `json:"username,omitempty" yaml:"username,omitempty"`
 	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	Credentials []ConnectionCredential `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	Domains     []ConnectionDomain     `json:"domains,omitempty" yaml:"domains,omitempty"`
+
+	AuthFailures    int64     `json:"authFailures,omitempty" yaml:"authFailures,omitempty"`
+	AuthLockouts    int64     `json:"authLockouts,omitempty" yaml:"authLockouts,omitempty"`
+	AuthLockedUntil time.Time `json:"authLockedUntil,omitempty" yaml:"authLockedUntil,omitempty"`
+
+	// RateLimit, RateBurst and MaxInFlight override server default limits.
+	// 0 means server default, negative means unlimited.
+	RateLimit   float64 `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+	RateBurst   int     `json:"rateBurst,omitempty" yaml:"rateBurst,omitempty"`
+	MaxInFlight int     `json:"maxInFlight,omitempty" yaml:"maxInFlight,omitempty"`
+
+	// HoldTimeout and HoldMaxBodyBytes override how requests wait for the
+	// connector to reconnect. 0 means server default, negative disables.
+	HoldTimeout      time.Duration `json:"holdTimeout,omitempty" yaml:"holdTimeout,omitempty"`
+	HoldMaxBodyBytes int64         `json:"holdMaxBodyBytes,omitempty" yaml:"holdMaxBodyBytes,omitempty"`
+
+	// Agent is the last reported information about the connector serving the connection
+	Agent *ConnectionAgent `json:"agent,omitempty" yaml:"agent,omitempty"`
+}
+
+// ConnectionAgent is the information connector reports about itself
+type ConnectionAgent struct {
+	Version       string    `json:"version,omitempty" yaml:"version,omitempty"`
+	Hostname      string    `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	OS            string    `json:"os,omitempty" yaml:"os,omitempty"`
+	StartedAt     time.Time `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	DownstreamURL string    `json:"downstreamUrl,omitempty" yaml:"downstreamUrl,omitempty"`
+	ReportedAt    time.Time `json:"reportedAt,omitempty" yaml:"reportedAt,omitempty"`
+
+	Gateways   []ConnectionAgentGateway `json:"gateways,omitempty" yaml:"gateways,omitempty"`
+	Conditions []ConnectionCondition    `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Compression is the size of responses connector compressed over tunnels
+	Compression *ConnectionAgentCompression `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+// ConnectionAgentCompression is the size of compressed responses before and
+// after compression since connector started
+type ConnectionAgentCompression struct {
+	UncompressedBytes int64   `json:"uncompressedBytes,omitempty" yaml:"uncompressedBytes,omitempty"`
+	CompressedBytes   int64   `json:"compressedBytes,omitempty" yaml:"compressedBytes,omitempty"`
+	Ratio             float64 `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+}
+
+type ConditionStatus string
+
+var (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// ConditionDownstreamHealthy reports if downstream answers health probes of the connector
+const ConditionDownstreamHealthy = "DownstreamHealthy"
+
+type ConnectionCondition struct {
+	Type               string          `json:"type,omitempty" yaml:"type,omitempty"`
+	Status             ConditionStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	Reason             string          `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message            string          `json:"message,omitempty" yaml:"message,omitempty"`
+	LastTransitionTime time.Time       `json:"lastTransitionTime,omitempty" yaml:"lastTransitionTime,omitempty"`
 }
 
 type ConnectionList struct {
@@ -35,4 +105,127 @@ type ConnectionList struct {
 
 type ConnectionGateway struct {
 	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// Candidates are the live gateways connector may hold tunnels to, ranked
+	// by preference. Connector measures latency to them to choose the best.
+	Candidates []ConnectionGatewayCandidate `json:"candidates,omitempty" yaml:"candidates,omitempty"`
+}
+
+type ConnectionGatewayCandidate struct {
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Region   string `json:"region,omitempty" yaml:"region,omitempty"`
+}
+
+// ConnectionAgentGateway is the gateway connector holds tunnel to, with
+// latency connector measured to it
+type ConnectionAgentGateway struct {
+	Hostname string        `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Latency  time.Duration `json:"latency,omitempty" yaml:"latency,omitempty"`
+}
+
+type CredentialType string
+
+var (
+	CredentialTypeBasicAuth CredentialType = "basic-auth"
+	CredentialTypeAPIKey    CredentialType = "api-key"
+)
+
+// ConnectionCredential is an external named credential model. Secrets are
+// only returned once, on creation.
+type ConnectionCredential struct {
+	ID        string         `json:"id,omitempty" yaml:"id,omitempty"`
+	Name      string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Type      CredentialType `json:"type,omitempty" yaml:"type,omitempty"`
+	CreatedAt time.Time      `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	LastUsed  time.Time      `json:"lastUsed,omitempty" yaml:"lastUsed,omitempty"`
+	ExpiresAt time.Time      `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+}
+
+type ConnectionCredentialList struct {
+	Items []ConnectionCredential `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// DomainChallengeRecordPrefix is prepended to the custom domain to form the
+// name of the TXT verification record.
+const DomainChallengeRecordPrefix = "_faros-challenge."
+
+// ConnectionDomain is an external custom domain model. Ownership is verified
+// either by TXT record named Challenge.TXTName with Challenge.TXTValue, or by
+// CNAME record of the domain pointing to Challenge.CNAMETarget.
+type ConnectionDomain struct {
+	ID            string          `json:"id,omitempty" yaml:"id,omitempty"`
+	Hostname      string          `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	Verified      bool            `json:"verified" yaml:"verified"`
+	VerifiedAt    time.Time       `json:"verifiedAt,omitempty" yaml:"verifiedAt,omitempty"`
+	LastCheckedAt time.Time       `json:"lastCheckedAt,omitempty" yaml:"lastCheckedAt,omitempty"`
+	Message       string          `json:"message,omitempty" yaml:"message,omitempty"`
+	Challenge     DomainChallenge `json:"challenge,omitempty" yaml:"challenge,omitempty"`
+}
+
+type DomainChallenge struct {
+	TXTName     string `json:"txtName,omitempty" yaml:"txtName,omitempty"`
+	TXTValue    string `json:"txtValue,omitempty" yaml:"txtValue,omitempty"`
+	CNAMETarget string `json:"cnameTarget,omitempty" yaml:"cnameTarget,omitempty"`
+}
+
+type ConnectionDomainList struct {
+	Items []ConnectionDomain `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+type ConnectionActionType string
+
+var (
+	// ConnectionActionDisconnect stops the tunnel of the connector
+	ConnectionActionDisconnect ConnectionActionType = "disconnect"
+	// ConnectionActionReconnect reconnects the tunnel, to GatewayURL if set
+	ConnectionActionReconnect ConnectionActionType = "reconnect"
+	// ConnectionActionSetDownstream changes default downstream to DownstreamURL
+	ConnectionActionSetDownstream ConnectionActionType = "set-downstream"
+	// ConnectionActionReloadRoutes reloads routes from the connector routes file
+	ConnectionActionReloadRoutes ConnectionActionType = "reload-routes"
+	// ConnectionActionDiagnostics collects diagnostics of the connector
+	ConnectionActionDiagnostics ConnectionActionType = "diagnostics"
+)
+
+type ConnectionActionStatus string
+
+var (
+	ConnectionActionPending   ConnectionActionStatus = "Pending"
+	ConnectionActionSucceeded ConnectionActionStatus = "Succeeded"
+	ConnectionActionFailed    ConnectionActionStatus = "Failed"
+)
+
+// ConnectionAction is a remote control command sent to the connector serving
+// the connection over the tunnel control channel. Status stays Pending until
+// the connector acknowledges it.
+type ConnectionAction struct {
+	ID            string                 `json:"id,omitempty" yaml:"id,omitempty"`
+	Type          ConnectionActionType   `json:"type,omitempty" yaml:"type,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	UpdatedAt     time.Time              `json:"updatedAt,omitempty" yaml:"updatedAt,omitempty"`
+	GatewayURL    string                 `json:"gatewayUrl,omitempty" yaml:"gatewayUrl,omitempty"`
+	DownstreamURL string                 `json:"downstreamUrl,omitempty" yaml:"downstreamUrl,omitempty"`
+	Status        ConnectionActionStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	Message       string                 `json:"message,omitempty" yaml:"message,omitempty"`
+	Diagnostics   map[string]string      `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty"`
+}
+
+type ConnectionUsage struct {
+	Hour     time.Time `json:"hour,omitempty" yaml:"hour,omitempty"`
+	Requests int64     `json:"requests" yaml:"requests"`
+	BytesIn  int64     `json:"bytesIn" yaml:"bytesIn"`
+	BytesOut int64     `json:"bytesOut" yaml:"bytesOut"`
+}
+
+type ConnectionUsageList struct {
+	From  time.Time         `json:"from,omitempty" yaml:"from,omitempty"`
+	To    time.Time         `json:"to,omitempty" yaml:"to,omitempty"`
+	Total ConnectionUsage   `json:"total" yaml:"total"`
+	Items []ConnectionUsage `json:"items,omitempty" yaml:"items,omitempty"`
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/faroshq/faros-ingress/pkg/api"
 
@@ -25,6 +26,21 @@ type Client interface {
 	GetConnection(ctx context.Context, agent api.Connection) (*api.Connection, error)
 	DeleteConnection(ctx context.Context, agent api.Connection) error
 	CreateConnection(ctx context.Context, agent api.Connection) (*api.Connection, error)
+	UpdateConnection(ctx context.Context, agent api.Connection) (*api.Connection, error)
+
+	ListConnectionCredentials(ctx context.Context, conn api.Connection) (*api.ConnectionCredentialList, error)
+	CreateConnectionCredential(ctx context.Context, conn api.Connection, credential api.ConnectionCredential) (*api.ConnectionCredential, error)
+	DeleteConnectionCredential(ctx context.Context, conn api.Connection, credential api.ConnectionCredential) error
+
+	ListConnectionDomains(ctx context.Context, conn api.Connection) (*api.ConnectionDomainList, error)
+	CreateConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) (*api.ConnectionDomain, error)
+	VerifyConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) (*api.ConnectionDomain, error)
+	DeleteConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) error
+
+	CreateConnectionAction(ctx context.Context, conn api.Connection, action api.ConnectionAction) (*api.ConnectionAction, error)
+	GetConnectionAction(ctx context.Context, conn api.Connection, action api.ConnectionAction) (*api.ConnectionAction, error)
+
+	GetConnectionUsage(ctx context.Context, conn api.Connection, from, to time.Time) (*api.ConnectionUsageList, error)
 }
 
 type client struct {
@@ -100,6 +116,114 @@ func (c *client) GetConnection(ctx context.Context, conn api.Connection) (*api.C
 	return &result, nil
 }
 
+func (c *client) ListConnectionCredentials(ctx context.Context, conn api.Connection) (*api.ConnectionCredentialList, error) {
+	var result api.ConnectionCredentialList
+	err := c.get(ctx, &result, "connections", conn.ID, "credentials")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) CreateConnectionCredential(ctx context.Context, conn api.Connection, credential api.ConnectionCredential) (*api.ConnectionCredential, error) {
+	var result api.ConnectionCredential
+	err := c.post(ctx, credential, &result, "connections", conn.ID, "credentials")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) DeleteConnectionCredential(ctx context.Context, conn api.Connection, credential api.ConnectionCredential) error {
+	var result api.ConnectionCredential
+	err := c.delete(ctx, credential, &result, "connections", conn.ID, "credentials", credential.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) ListConnectionDomains(ctx context.Context, conn api.Connection) (*api.ConnectionDomainList, error) {
+	var result api.ConnectionDomainList
+	err := c.get(ctx, &result, "connections", conn.ID, "domains")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) CreateConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) (*api.ConnectionDomain, error) {
+	var result api.ConnectionDomain
+	err := c.post(ctx, domain, &result, "connections", conn.ID, "domains")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) VerifyConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) (*api.ConnectionDomain, error) {
+	var result api.ConnectionDomain
+	err := c.post(ctx, domain, &result, "connections", conn.ID, "domains", domain.ID, "verify")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) DeleteConnectionDomain(ctx context.Context, conn api.Connection, domain api.ConnectionDomain) error {
+	var result api.ConnectionDomain
+	err := c.delete(ctx, domain, &result, "connections", conn.ID, "domains", domain.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) CreateConnectionAction(ctx context.Context, conn api.Connection, action api.ConnectionAction) (*api.ConnectionAction, error) {
+	var result api.ConnectionAction
+	err := c.post(ctx, action, &result, "connections", conn.ID, "actions", string(action.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) GetConnectionAction(ctx context.Context, conn api.Connection, action api.ConnectionAction) (*api.ConnectionAction, error) {
+	var result api.ConnectionAction
+	err := c.get(ctx, &result, "connections", conn.ID, "actions", action.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *client) GetConnectionUsage(ctx context.Context, conn api.Connection, from, to time.Time) (*api.ConnectionUsageList, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	var result api.ConnectionUsageList
+	err := c.get(ctx, &result, "connections", conn.ID, "usage?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 func (c *client) get(ctx context.Context, out interface{}, s ...string) error {
 	bytes, err := c.getB(ctx, s...)
 	if err != nil {
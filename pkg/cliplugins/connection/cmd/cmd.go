@@ -23,6 +23,49 @@ var (
 	# Delete a connection
 	%[1]s <connection-name1> <connection-name2> ...
 `
+
+	actionExample = `
+	# Collect diagnostics of the connector
+	%[1]s <connection-name> diagnostics
+
+	# Change default downstream of the connector
+	%[1]s <connection-name> set-downstream --downstream http://localhost:3000
+
+	# Reconnect the connector to a different gateway
+	%[1]s <connection-name> reconnect --gateway-url https://gateway-2.faros.sh
+
+	# Reload routes file of the connector, or disconnect it
+	%[1]s <connection-name> reload-routes
+	%[1]s <connection-name> disconnect
+`
+
+	credentialsExample = `
+	# Add basic auth credential to a connection
+	%[1]s add <connection-name> <credential-name> --username partner
+
+	# Add api key credential to a connection
+	%[1]s add <connection-name> <credential-name> --type api-key --header X-Api-Key --ttl 720h
+
+	# List credentials of a connection
+	%[1]s list <connection-name>
+
+	# Revoke credential of a connection
+	%[1]s revoke <connection-name> <credential-name>
+`
+
+	domainsExample = `
+	# Add custom domain to a connection
+	%[1]s add <connection-name> preview.ourcompany.com
+
+	# List custom domains of a connection
+	%[1]s list <connection-name>
+
+	# Verify DNS challenge of a custom domain
+	%[1]s verify <connection-name> preview.ourcompany.com
+
+	# Remove custom domain from a connection
+	%[1]s remove <connection-name> preview.ourcompany.com
+`
 )
 
 // New provides a cobra command for workload operations.
@@ -196,5 +239,267 @@ func New(streams genericclioptions.IOStreams) (*cobra.Command, error) {
 	connectOptions.BindFlags(connectCmd)
 	cmd.AddCommand(connectCmd)
 
+	// Usage command
+	usageOptions := plugin.NewUsageOptions(streams)
+	usageCmd := &cobra.Command{
+		Use:          "usage",
+		Short:        "Show hourly usage of a connection",
+		Example:      fmt.Sprintf(connectionExample, "kubectl faros connection usage"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return c.Help()
+			}
+
+			if err := usageOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := usageOptions.Validate(); err != nil {
+				return err
+			}
+
+			return usageOptions.Run(c.Context())
+		},
+	}
+
+	usageOptions.BindFlags(usageCmd)
+	cmd.AddCommand(usageCmd)
+
+	// Action command
+	actionOptions := plugin.NewActionOptions(streams)
+	actionCmd := &cobra.Command{
+		Use:          "action",
+		Short:        "Send remote control action to the connector of a connection",
+		Example:      fmt.Sprintf(actionExample, "kubectl faros connection action"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := actionOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := actionOptions.Validate(); err != nil {
+				return err
+			}
+
+			return actionOptions.Run(c.Context())
+		},
+	}
+
+	actionOptions.BindFlags(actionCmd)
+	cmd.AddCommand(actionCmd)
+
+	// Credentials command
+	credentialsCmd := &cobra.Command{
+		Aliases:      []string{"credential", "creds"},
+		Use:          "credentials",
+		Short:        "Manage named credentials of a connection",
+		Example:      fmt.Sprintf(credentialsExample, "kubectl faros connection credentials"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			return c.Help()
+		},
+	}
+	cmd.AddCommand(credentialsCmd)
+
+	credentialsAddOptions := plugin.NewCredentialsOptions(streams)
+	credentialsAddCmd := &cobra.Command{
+		Use:          "add",
+		Short:        "Add a credential to a connection",
+		Example:      fmt.Sprintf(credentialsExample, "kubectl faros connection credentials"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := credentialsAddOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := credentialsAddOptions.Validate(); err != nil {
+				return err
+			}
+
+			return credentialsAddOptions.RunAdd(c.Context())
+		},
+	}
+
+	credentialsAddOptions.BindAddFlags(credentialsAddCmd)
+	credentialsCmd.AddCommand(credentialsAddCmd)
+
+	credentialsListOptions := plugin.NewCredentialsOptions(streams)
+	credentialsListCmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List credentials of a connection",
+		Example:      fmt.Sprintf(credentialsExample, "kubectl faros connection credentials"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return c.Help()
+			}
+
+			if err := credentialsListOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := credentialsListOptions.Validate(); err != nil {
+				return err
+			}
+
+			return credentialsListOptions.RunList(c.Context())
+		},
+	}
+
+	credentialsListOptions.BindFlags(credentialsListCmd)
+	credentialsCmd.AddCommand(credentialsListCmd)
+
+	credentialsRevokeOptions := plugin.NewCredentialsOptions(streams)
+	credentialsRevokeCmd := &cobra.Command{
+		Use:          "revoke",
+		Short:        "Revoke a credential of a connection",
+		Example:      fmt.Sprintf(credentialsExample, "kubectl faros connection credentials"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := credentialsRevokeOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := credentialsRevokeOptions.Validate(); err != nil {
+				return err
+			}
+
+			return credentialsRevokeOptions.RunRevoke(c.Context())
+		},
+	}
+
+	credentialsRevokeOptions.BindFlags(credentialsRevokeCmd)
+	credentialsCmd.AddCommand(credentialsRevokeCmd)
+
+	// Domains command
+	domainsCmd := &cobra.Command{
+		Aliases:      []string{"domain"},
+		Use:          "domains",
+		Short:        "Manage custom domains of a connection",
+		Example:      fmt.Sprintf(domainsExample, "kubectl faros connection domains"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			return c.Help()
+		},
+	}
+	cmd.AddCommand(domainsCmd)
+
+	domainsAddOptions := plugin.NewDomainsOptions(streams)
+	domainsAddCmd := &cobra.Command{
+		Use:          "add",
+		Short:        "Add a custom domain to a connection",
+		Example:      fmt.Sprintf(domainsExample, "kubectl faros connection domains"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := domainsAddOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := domainsAddOptions.Validate(); err != nil {
+				return err
+			}
+
+			return domainsAddOptions.RunAdd(c.Context())
+		},
+	}
+
+	domainsAddOptions.BindFlags(domainsAddCmd)
+	domainsCmd.AddCommand(domainsAddCmd)
+
+	domainsListOptions := plugin.NewDomainsOptions(streams)
+	domainsListCmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List custom domains of a connection",
+		Example:      fmt.Sprintf(domainsExample, "kubectl faros connection domains"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return c.Help()
+			}
+
+			if err := domainsListOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := domainsListOptions.Validate(); err != nil {
+				return err
+			}
+
+			return domainsListOptions.RunList(c.Context())
+		},
+	}
+
+	domainsListOptions.BindFlags(domainsListCmd)
+	domainsCmd.AddCommand(domainsListCmd)
+
+	domainsVerifyOptions := plugin.NewDomainsOptions(streams)
+	domainsVerifyCmd := &cobra.Command{
+		Use:          "verify",
+		Short:        "Verify DNS challenge of a custom domain",
+		Example:      fmt.Sprintf(domainsExample, "kubectl faros connection domains"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := domainsVerifyOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := domainsVerifyOptions.Validate(); err != nil {
+				return err
+			}
+
+			return domainsVerifyOptions.RunVerify(c.Context())
+		},
+	}
+
+	domainsVerifyOptions.BindFlags(domainsVerifyCmd)
+	domainsCmd.AddCommand(domainsVerifyCmd)
+
+	domainsRemoveOptions := plugin.NewDomainsOptions(streams)
+	domainsRemoveCmd := &cobra.Command{
+		Use:          "remove",
+		Short:        "Remove a custom domain from a connection",
+		Example:      fmt.Sprintf(domainsExample, "kubectl faros connection domains"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return c.Help()
+			}
+
+			if err := domainsRemoveOptions.Complete(args); err != nil {
+				return err
+			}
+
+			if err := domainsRemoveOptions.Validate(); err != nil {
+				return err
+			}
+
+			return domainsRemoveOptions.RunRemove(c.Context())
+		},
+	}
+
+	domainsRemoveOptions.BindFlags(domainsRemoveCmd)
+	domainsCmd.AddCommand(domainsRemoveCmd)
+
 	return cmd, nil
 }
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/client"
+	"github.com/faroshq/faros-ingress/pkg/cliplugins/base"
+	utilprint "github.com/faroshq/faros-ingress/pkg/util/print"
+)
+
+// ActionOptions contains options for sending remote control actions to the connector.
+type ActionOptions struct {
+	*base.Options
+
+	Name   string
+	Action string
+	// GatewayURL is the gateway to reconnect to. Used by reconnect action.
+	GatewayURL string
+	// DownstreamURL is the new default downstream. Used by set-downstream action.
+	DownstreamURL string
+	// Timeout is how long to wait for connector to acknowledge the action.
+	Timeout time.Duration
+}
+
+// NewActionOptions returns a new ActionOptions.
+func NewActionOptions(streams genericclioptions.IOStreams) *ActionOptions {
+	return &ActionOptions{
+		Options: base.NewOptions(streams),
+	}
+}
+
+// BindFlags binds fields ActionOptions as command line flags to cmd's flagset.
+func (o *ActionOptions) BindFlags(cmd *cobra.Command) {
+	o.Options.BindFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.GatewayURL, "gateway-url", "", "", "Gateway to reconnect to. Defaults to gateway assigned by the API")
+	cmd.Flags().StringVarP(&o.DownstreamURL, "downstream", "d", "", "New default downstream URL")
+	cmd.Flags().DurationVarP(&o.Timeout, "timeout", "", 30*time.Second, "Time to wait for the connector to acknowledge the action")
+}
+
+// Complete ensures all dynamically populated fields are initialized.
+func (o *ActionOptions) Complete(args []string) error {
+	if err := o.Options.Complete(); err != nil {
+		return err
+	}
+
+	o.Name = args[0]
+	o.Action = args[1]
+
+	return nil
+}
+
+// Validate validates the ActionOptions are complete and usable.
+func (o *ActionOptions) Validate() error {
+	var errs []error
+
+	if err := o.Options.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch api.ConnectionActionType(o.Action) {
+	case api.ConnectionActionDisconnect, api.ConnectionActionReconnect, api.ConnectionActionReloadRoutes, api.ConnectionActionDiagnostics:
+	case api.ConnectionActionSetDownstream:
+		if o.DownstreamURL == "" {
+			errs = append(errs, fmt.Errorf("--downstream is required for %s", o.Action))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("action %q is not supported", o.Action))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Run sends the action to the connector and waits for it to be acknowledged
+func (o *ActionOptions) Run(ctx context.Context) error {
+	config, err := o.ClientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewClient(u, config.BearerToken, nil)
+
+	conns, err := c.ListConnections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name != o.Name {
+			continue
+		}
+
+		action, err := c.CreateConnectionAction(ctx, conn, api.ConnectionAction{
+			Type:          api.ConnectionActionType(o.Action),
+			GatewayURL:    o.GatewayURL,
+			DownstreamURL: o.DownstreamURL,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = wait.PollImmediate(time.Second, o.Timeout, func() (bool, error) {
+			action, err = c.GetConnectionAction(ctx, conn, *action)
+			if err != nil {
+				return false, err
+			}
+			return action.Status != api.ConnectionActionPending, nil
+		})
+		if err != nil && err != wait.ErrWaitTimeout {
+			return err
+		}
+
+		if o.Output == utilprint.FormatTable {
+			fmt.Printf("Action '%s' on connection '%s': %s\n", action.Type, conn.Name, action.Status)
+			if action.Message != "" {
+				fmt.Println(action.Message)
+			}
+			if len(action.Diagnostics) > 0 {
+				table := utilprint.DefaultTable()
+				table.SetHeader([]string{"KEY", "VALUE"})
+				for _, key := range sortedKeys(action.Diagnostics) {
+					table.Append([]string{key, action.Diagnostics[key]})
+				}
+				table.Render()
+			}
+			return nil
+		}
+
+		return utilprint.PrintWithFormat(action, o.Output)
+	}
+
+	return fmt.Errorf("connection %q not found", o.Name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -33,6 +33,8 @@ type ConnectOptions struct {
 	Token string
 	// ConnectionID is the ID of the connection to use.
 	ConnectionID string
+	// PreserveHost passes original Host header to the downstream.
+	PreserveHost bool
 
 	// create is set to true if the connection should be created.
 	create bool
@@ -54,6 +56,7 @@ func (o *ConnectOptions) BindFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.DownstreamURL, "downstream", "d", "http://localhost:8080", "Downstream URL")
 	cmd.Flags().StringVarP(&o.Token, "token", "t", "", "Token for the connection")
 	cmd.Flags().StringVarP(&o.ConnectionID, "connection-id", "c", "", "Connection ID")
+	cmd.Flags().BoolVarP(&o.PreserveHost, "preserve-host", "", false, "Pass original Host header to the downstream")
 }
 
 // Complete ensures all dynamically populated fields are initialized.
@@ -142,12 +145,18 @@ func (o *ConnectOptions) Run(ctx context.Context) error {
 	cfg.DownstreamURL = o.DownstreamURL
 	cfg.Token = existing.Token
 	cfg.ConnectionID = existing.ID
+	cfg.PreserveHost = o.PreserveHost
 
 	client, err := connector.New(cfg)
 	if err != nil {
 		return err
 	}
-	go client.Run(ctx)
+	// Run returns on Ctrl+C or once the connection is disconnected remotely
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
 
 	fmt.Println("Connecting to connection: " + o.Name)
 	fmt.Println("Press Ctrl+C to exit")
@@ -159,7 +168,7 @@ func (o *ConnectOptions) Run(ctx context.Context) error {
 		fmt.Println("Username: " + existing.Username)
 		fmt.Println("Password: " + existing.Password)
 	}
-	<-ctx.Done()
+	<-done
 
 	return nil
 }
@@ -26,6 +26,16 @@ type CreateOptions struct {
 	TTL time.Duration
 	// Secure is the flag to use secure connection with basic auth
 	Secure bool
+	// RateLimit is the number of requests per second allowed. Negative means unlimited.
+	RateLimit float64
+	// RateBurst is the number of requests allowed to burst over RateLimit.
+	RateBurst int
+	// MaxInFlight is the number of concurrent requests allowed. Negative means unlimited.
+	MaxInFlight int
+	// HoldTimeout is how long requests wait for the connector to reconnect. Negative disables holding.
+	HoldTimeout time.Duration
+	// HoldMaxBodyBytes is the size up to which requests with body are held too. Negative holds only idempotent requests.
+	HoldMaxBodyBytes int64
 }
 
 // NewCreateOptions returns a new CreateOptions.
@@ -40,8 +50,13 @@ func (o *CreateOptions) BindFlags(cmd *cobra.Command) {
 	o.Options.BindFlags(cmd)
 
 	cmd.Flags().BoolVarP(&o.Secure, "secure", "s", false, "Secure with basic auth")
-	cmd.Flags().StringVarP(&o.Hostname, "hostname", "", "", "Hostname of the agent")
+	cmd.Flags().StringVarP(&o.Hostname, "hostname", "", "", "Hostname of the agent. Leftmost label may be a wildcard, e.g. '*.myapp'")
 	cmd.Flags().DurationVarP(&o.TTL, "ttl", "", 24*time.Hour, "Timeout TTL for the connection")
+	cmd.Flags().Float64VarP(&o.RateLimit, "rate-limit", "", 0, "Requests per second allowed. 0 uses server default, negative is unlimited")
+	cmd.Flags().IntVarP(&o.RateBurst, "rate-burst", "", 0, "Requests allowed to burst over rate limit. 0 uses server default")
+	cmd.Flags().IntVarP(&o.MaxInFlight, "max-in-flight", "", 0, "Concurrent requests allowed. 0 uses server default, negative is unlimited")
+	cmd.Flags().DurationVarP(&o.HoldTimeout, "hold-timeout", "", 0, "Time requests wait for the connector to reconnect. 0 uses server default, negative disables holding")
+	cmd.Flags().Int64VarP(&o.HoldMaxBodyBytes, "hold-max-body-bytes", "", 0, "Size up to which requests with body are held too. 0 uses server default, negative holds only idempotent requests")
 }
 
 // Complete ensures all dynamically populated fields are initialized.
@@ -86,6 +101,13 @@ func (o *CreateOptions) Run(ctx context.Context) error {
 		Secure:   o.Secure,
 		Hostname: o.Hostname,
 		TTL:      o.TTL,
+
+		RateLimit:   o.RateLimit,
+		RateBurst:   o.RateBurst,
+		MaxInFlight: o.MaxInFlight,
+
+		HoldTimeout:      o.HoldTimeout,
+		HoldMaxBodyBytes: o.HoldMaxBodyBytes,
 	})
 	if err != nil {
 		return err
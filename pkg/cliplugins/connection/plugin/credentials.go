@@ -0,0 +1,224 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/client"
+	"github.com/faroshq/faros-ingress/pkg/cliplugins/base"
+	utilprint "github.com/faroshq/faros-ingress/pkg/util/print"
+	utiltime "github.com/faroshq/faros-ingress/pkg/util/time"
+)
+
+// CredentialsOptions contains options for managing named credentials of a connection.
+type CredentialsOptions struct {
+	*base.Options
+	// ConnectionName is the name of the connection credentials belong to.
+	ConnectionName string
+	// Name is the name of the credential.
+	Name string
+	// Type is the type of the credential: basic-auth or api-key.
+	Type string
+	// Username is the username of basic-auth credential.
+	Username string
+	// Password is the password of basic-auth credential.
+	Password string
+	// Header is the header api-key credential is read from.
+	Header string
+	// APIKey is the key of api-key credential.
+	APIKey string
+	// TTL is the time after which credential expires. 0 means never.
+	TTL time.Duration
+}
+
+// NewCredentialsOptions returns a new CredentialsOptions.
+func NewCredentialsOptions(streams genericclioptions.IOStreams) *CredentialsOptions {
+	return &CredentialsOptions{
+		Options: base.NewOptions(streams),
+	}
+}
+
+// BindFlags binds fields CredentialsOptions as command line flags to cmd's flagset.
+func (o *CredentialsOptions) BindFlags(cmd *cobra.Command) {
+	o.Options.BindFlags(cmd)
+}
+
+// BindAddFlags binds flags used when adding credential to cmd's flagset.
+func (o *CredentialsOptions) BindAddFlags(cmd *cobra.Command) {
+	o.BindFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.Type, "type", "", string(api.CredentialTypeBasicAuth), "Type of the credential [basic-auth,api-key]")
+	cmd.Flags().StringVarP(&o.Username, "username", "", "", "Username for basic-auth credential")
+	cmd.Flags().StringVarP(&o.Password, "password", "", "", "Password for basic-auth credential. Generated if empty")
+	cmd.Flags().StringVarP(&o.Header, "header", "", api.DefaultAPIKeyHeader, "Header for api-key credential")
+	cmd.Flags().StringVarP(&o.APIKey, "api-key", "", "", "Key for api-key credential. Generated if empty")
+	cmd.Flags().DurationVarP(&o.TTL, "ttl", "", 0, "Time after which credential expires. 0 means never")
+}
+
+// Complete ensures all dynamically populated fields are initialized.
+func (o *CredentialsOptions) Complete(args []string) error {
+	if err := o.Options.Complete(); err != nil {
+		return err
+	}
+
+	o.ConnectionName = args[0]
+	if len(args) > 1 {
+		o.Name = args[1]
+	}
+
+	return nil
+}
+
+// Validate validates the CredentialsOptions are complete and usable.
+func (o *CredentialsOptions) Validate() error {
+	var errs []error
+
+	if err := o.Options.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch api.CredentialType(o.Type) {
+	case api.CredentialTypeBasicAuth, api.CredentialTypeAPIKey, "":
+	default:
+		errs = append(errs, fmt.Errorf("credential type %q is not supported", o.Type))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// RunAdd adds a named credential to the connection.
+func (o *CredentialsOptions) RunAdd(ctx context.Context) error {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	request := api.ConnectionCredential{
+		Name:     o.Name,
+		Type:     api.CredentialType(o.Type),
+		Username: o.Username,
+		Password: o.Password,
+		Header:   o.Header,
+		APIKey:   o.APIKey,
+	}
+	if o.TTL > 0 {
+		request.ExpiresAt = time.Now().Add(o.TTL)
+	}
+
+	credential, err := c.CreateConnectionCredential(ctx, *conn, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Credential '%s' added to connection '%s'\n", credential.Name, conn.Name)
+	switch credential.Type {
+	case api.CredentialTypeAPIKey:
+		fmt.Printf("Header: '%s'\n", credential.Header)
+		fmt.Printf("API key: '%s'\n", credential.APIKey)
+	default:
+		fmt.Printf("Username: '%s'\n", credential.Username)
+		fmt.Printf("Password: '%s'\n", credential.Password)
+	}
+	fmt.Printf("Secrets will be shown only once. Please save it now\n\n")
+
+	return nil
+}
+
+// RunList lists named credentials of the connection.
+func (o *CredentialsOptions) RunList(ctx context.Context) error {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.ListConnectionCredentials(ctx, *conn)
+	if err != nil {
+		return err
+	}
+
+	if o.Output == utilprint.FormatTable {
+		table := utilprint.DefaultTable()
+		table.SetHeader([]string{"NAME", "TYPE", "USERNAME", "HEADER", "LAST USED", "EXPIRES"})
+		for _, credential := range list.Items {
+			lastUsed := "never"
+			if !credential.LastUsed.IsZero() {
+				lastUsed = utiltime.Since(credential.LastUsed).String() + " ago"
+			}
+			expires := "never"
+			if !credential.ExpiresAt.IsZero() {
+				expires = credential.ExpiresAt.Format(time.RFC3339)
+			}
+			table.Append([]string{
+				credential.Name,
+				string(credential.Type),
+				credential.Username,
+				credential.Header,
+				lastUsed,
+				expires,
+			})
+		}
+		table.Render()
+		return nil
+	}
+
+	return utilprint.PrintWithFormat(list, o.Output)
+}
+
+// RunRevoke revokes named credential of the connection.
+func (o *CredentialsOptions) RunRevoke(ctx context.Context) error {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.ListConnectionCredentials(ctx, *conn)
+	if err != nil {
+		return err
+	}
+
+	for _, credential := range list.Items {
+		if credential.Name == o.Name {
+			err = c.DeleteConnectionCredential(ctx, *conn, credential)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Credential '%s' revoked \n", o.Name)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("credential %q not found", o.Name)
+}
+
+func (o *CredentialsOptions) connection(ctx context.Context) (client.Client, *api.Connection, error) {
+	config, err := o.ClientConfig.ClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := client.NewClient(u, config.BearerToken, nil)
+
+	conns, err := c.ListConnections(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name == o.ConnectionName {
+			return c, &conn, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("connection %q not found", o.ConnectionName)
+}
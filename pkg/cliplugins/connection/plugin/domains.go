@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/client"
+	"github.com/faroshq/faros-ingress/pkg/cliplugins/base"
+	utilprint "github.com/faroshq/faros-ingress/pkg/util/print"
+)
+
+// DomainsOptions contains options for managing custom domains of a connection.
+type DomainsOptions struct {
+	*base.Options
+	// ConnectionName is the name of the connection domains belong to.
+	ConnectionName string
+	// Domain is the custom domain name.
+	Domain string
+}
+
+// NewDomainsOptions returns a new DomainsOptions.
+func NewDomainsOptions(streams genericclioptions.IOStreams) *DomainsOptions {
+	return &DomainsOptions{
+		Options: base.NewOptions(streams),
+	}
+}
+
+// BindFlags binds fields DomainsOptions as command line flags to cmd's flagset.
+func (o *DomainsOptions) BindFlags(cmd *cobra.Command) {
+	o.Options.BindFlags(cmd)
+}
+
+// Complete ensures all dynamically populated fields are initialized.
+func (o *DomainsOptions) Complete(args []string) error {
+	if err := o.Options.Complete(); err != nil {
+		return err
+	}
+
+	o.ConnectionName = args[0]
+	if len(args) > 1 {
+		o.Domain = args[1]
+	}
+
+	return nil
+}
+
+// Validate validates the DomainsOptions are complete and usable.
+func (o *DomainsOptions) Validate() error {
+	return o.Options.Validate()
+}
+
+// RunAdd attaches a custom domain to the connection and prints its DNS challenge.
+func (o *DomainsOptions) RunAdd(ctx context.Context) error {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	domain, err := c.CreateConnectionDomain(ctx, *conn, api.ConnectionDomain{
+		Hostname: o.Domain,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Domain '%s' added to connection '%s'\n", domain.Hostname, conn.Name)
+	printDomainChallenge(domain)
+
+	return nil
+}
+
+// RunList lists custom domains of the connection.
+func (o *DomainsOptions) RunList(ctx context.Context) error {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.ListConnectionDomains(ctx, *conn)
+	if err != nil {
+		return err
+	}
+
+	if o.Output == utilprint.FormatTable {
+		table := utilprint.DefaultTable()
+		table.SetHeader([]string{"DOMAIN", "VERIFIED", "MESSAGE"})
+		for _, domain := range list.Items {
+			verified := "no"
+			if domain.Verified {
+				verified = domain.VerifiedAt.Format(time.RFC3339)
+			}
+			table.Append([]string{
+				domain.Hostname,
+				verified,
+				domain.Message,
+			})
+		}
+		table.Render()
+		return nil
+	}
+
+	return utilprint.PrintWithFormat(list, o.Output)
+}
+
+// RunVerify checks DNS challenge of the custom domain right away.
+func (o *DomainsOptions) RunVerify(ctx context.Context) error {
+	c, conn, domain, err := o.domain(ctx)
+	if err != nil {
+		return err
+	}
+
+	domain, err = c.VerifyConnectionDomain(ctx, *conn, *domain)
+	if err != nil {
+		return err
+	}
+
+	if domain.Verified {
+		fmt.Printf("Domain '%s' verified\n", domain.Hostname)
+		return nil
+	}
+
+	fmt.Printf("Domain '%s' not verified yet: %s\n", domain.Hostname, domain.Message)
+	printDomainChallenge(domain)
+
+	return nil
+}
+
+// RunRemove detaches custom domain from the connection.
+func (o *DomainsOptions) RunRemove(ctx context.Context) error {
+	c, conn, domain, err := o.domain(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.DeleteConnectionDomain(ctx, *conn, *domain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Domain '%s' removed \n", domain.Hostname)
+
+	return nil
+}
+
+func printDomainChallenge(domain *api.ConnectionDomain) {
+	fmt.Printf("To verify ownership create either of DNS records:\n")
+	fmt.Printf("  %s TXT %q\n", domain.Challenge.TXTName, domain.Challenge.TXTValue)
+	fmt.Printf("  %s CNAME %s\n", domain.Hostname, domain.Challenge.CNAMETarget)
+}
+
+func (o *DomainsOptions) domain(ctx context.Context) (client.Client, *api.Connection, *api.ConnectionDomain, error) {
+	c, conn, err := o.connection(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	list, err := c.ListConnectionDomains(ctx, *conn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, domain := range list.Items {
+		if domain.Hostname == o.Domain {
+			return c, conn, &domain, nil
+		}
+	}
+
+	return nil, nil, nil, fmt.Errorf("domain %q not found", o.Domain)
+}
+
+func (o *DomainsOptions) connection(ctx context.Context) (client.Client, *api.Connection, error) {
+	config, err := o.ClientConfig.ClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := client.NewClient(u, config.BearerToken, nil)
+
+	conns, err := c.ListConnections(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name == o.ConnectionName {
+			return c, &conn, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("connection %q not found", o.ConnectionName)
+}
@@ -74,7 +74,7 @@ func (o *ListOptions) Run(ctx context.Context) error {
 
 	if o.Output == utilprint.FormatTable {
 		table := utilprint.DefaultTable()
-		table.SetHeader([]string{"NAME", "HOSTNAME", "LAST USED", "TTL", "STATE", "SECURE"})
+		table.SetHeader([]string{"NAME", "HOSTNAME", "LAST USED", "TTL", "STATE", "REPLICAS", "SECURE"})
 		for _, conn := range list.Items {
 			{
 				table.Append([]string{
@@ -83,6 +83,7 @@ func (o *ListOptions) Run(ctx context.Context) error {
 					utiltime.Since(conn.LastUsed).String() + " ago",
 					conn.TTL.String(),
 					string(conn.State),
+					strconv.Itoa(conn.Replicas),
 					strconv.FormatBool(conn.Secure),
 				})
 			}
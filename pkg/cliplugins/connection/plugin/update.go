@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/spf13/cobra"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -26,6 +27,16 @@ type UpdateOptions struct {
 	Hostname string
 	// Secure is the secure of the Connection to be Updated.
 	Secure bool
+	// RateLimit is the number of requests per second allowed. Negative means unlimited.
+	RateLimit float64
+	// RateBurst is the number of requests allowed to burst over RateLimit.
+	RateBurst int
+	// MaxInFlight is the number of concurrent requests allowed. Negative means unlimited.
+	MaxInFlight int
+	// HoldTimeout is how long requests wait for the connector to reconnect. Negative disables holding.
+	HoldTimeout time.Duration
+	// HoldMaxBodyBytes is the size up to which requests with body are held too. Negative holds only idempotent requests.
+	HoldMaxBodyBytes int64
 }
 
 // NewUpdateOptions returns a new UpdateOptions.
@@ -43,6 +54,11 @@ func (o *UpdateOptions) BindFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Password, "password", "", "", "Password for the connection")
 	cmd.Flags().StringVarP(&o.Hostname, "hostname", "", "", "Hostname of the connection")
 	cmd.Flags().BoolVarP(&o.Secure, "secure", "", false, "Secure the connection")
+	cmd.Flags().Float64VarP(&o.RateLimit, "rate-limit", "", 0, "Requests per second allowed. 0 uses server default, negative is unlimited")
+	cmd.Flags().IntVarP(&o.RateBurst, "rate-burst", "", 0, "Requests allowed to burst over rate limit. 0 uses server default")
+	cmd.Flags().IntVarP(&o.MaxInFlight, "max-in-flight", "", 0, "Concurrent requests allowed. 0 uses server default, negative is unlimited")
+	cmd.Flags().DurationVarP(&o.HoldTimeout, "hold-timeout", "", 0, "Time requests wait for the connector to reconnect. 0 uses server default, negative disables holding")
+	cmd.Flags().Int64VarP(&o.HoldMaxBodyBytes, "hold-max-body-bytes", "", 0, "Size up to which requests with body are held too. 0 uses server default, negative holds only idempotent requests")
 }
 
 // Complete ensures all dynamically populated fields are initialized.
@@ -93,6 +109,11 @@ func (o *UpdateOptions) Run(ctx context.Context) error {
 			conn.Password = o.Password
 			conn.Hostname = o.Hostname
 			conn.Secure = o.Secure
+			conn.RateLimit = o.RateLimit
+			conn.RateBurst = o.RateBurst
+			conn.MaxInFlight = o.MaxInFlight
+			conn.HoldTimeout = o.HoldTimeout
+			conn.HoldMaxBodyBytes = o.HoldMaxBodyBytes
 
 			_, err := c.UpdateConnection(ctx, conn)
 			if err != nil {
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/faroshq/faros-ingress/pkg/client"
+	"github.com/faroshq/faros-ingress/pkg/cliplugins/base"
+	utilprint "github.com/faroshq/faros-ingress/pkg/util/print"
+)
+
+// UsageOptions contains options for showing connection usage.
+type UsageOptions struct {
+	*base.Options
+
+	Name string
+	// From is the start of the usage range in RFC3339. Defaults to start of the month.
+	From string
+	// To is the end of the usage range in RFC3339. Defaults to now.
+	To string
+
+	from time.Time
+	to   time.Time
+}
+
+// NewUsageOptions returns a new UsageOptions.
+func NewUsageOptions(streams genericclioptions.IOStreams) *UsageOptions {
+	return &UsageOptions{
+		Options: base.NewOptions(streams),
+	}
+}
+
+// BindFlags binds fields UsageOptions as command line flags to cmd's flagset.
+func (o *UsageOptions) BindFlags(cmd *cobra.Command) {
+	o.Options.BindFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.From, "from", "", "", "Start of the range in RFC3339. Defaults to start of the month")
+	cmd.Flags().StringVarP(&o.To, "to", "", "", "End of the range in RFC3339. Defaults to now")
+}
+
+// Complete ensures all dynamically populated fields are initialized.
+func (o *UsageOptions) Complete(args []string) error {
+	if err := o.Options.Complete(); err != nil {
+		return err
+	}
+
+	o.Name = args[0]
+
+	return nil
+}
+
+// Validate validates the UsageOptions are complete and usable.
+func (o *UsageOptions) Validate() error {
+	var errs []error
+
+	if err := o.Options.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var err error
+	if o.From != "" {
+		if o.from, err = time.Parse(time.RFC3339, o.From); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --from: %w", err))
+		}
+	}
+	if o.To != "" {
+		if o.to, err = time.Parse(time.RFC3339, o.To); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --to: %w", err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Run shows hourly usage of the connection
+func (o *UsageOptions) Run(ctx context.Context) error {
+	config, err := o.ClientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewClient(u, config.BearerToken, nil)
+
+	conns, err := c.ListConnections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name != o.Name {
+			continue
+		}
+
+		usage, err := c.GetConnectionUsage(ctx, conn, o.from, o.to)
+		if err != nil {
+			return err
+		}
+
+		if o.Output == utilprint.FormatTable {
+			table := utilprint.DefaultTable()
+			table.SetHeader([]string{"HOUR", "REQUESTS", "BYTES IN", "BYTES OUT"})
+			for _, item := range usage.Items {
+				table.Append([]string{
+					item.Hour.Format(time.RFC3339),
+					strconv.FormatInt(item.Requests, 10),
+					strconv.FormatInt(item.BytesIn, 10),
+					strconv.FormatInt(item.BytesOut, 10),
+				})
+			}
+			table.Append([]string{
+				"TOTAL",
+				strconv.FormatInt(usage.Total.Requests, 10),
+				strconv.FormatInt(usage.Total.BytesIn, 10),
+				strconv.FormatInt(usage.Total.BytesOut, 10),
+			})
+			table.Render()
+			return nil
+		}
+
+		return utilprint.PrintWithFormat(usage, o.Output)
+	}
+
+	return fmt.Errorf("connection %q not found", o.Name)
+}
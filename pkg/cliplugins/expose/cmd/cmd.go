@@ -22,6 +22,12 @@ var (
 
 	# Connect to specific localhost address and use basic auth
 	%[1]s --secure
+
+	# Route API requests to other downstream, stripping /api prefix
+	%[1]s http://localhost:3000 --route /api=http://localhost:8080,strip
+
+	# Route requests using rules from file
+	%[1]s http://localhost:3000 --routes-file routes.yaml
 `
 )
 
@@ -35,7 +41,7 @@ func New(streams genericclioptions.IOStreams) (*cobra.Command, error) {
 		Example:      fmt.Sprintf(connectExample, "kubectl faros expose"),
 		SilenceUsage: true,
 		RunE: func(c *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			if len(args) == 0 && len(exposeOptions.Routes) == 0 && exposeOptions.RoutesFile == "" {
 				return c.Help()
 			}
 			if err := exposeOptions.Complete(args); err != nil {
@@ -32,6 +32,15 @@ type ExposeOptions struct {
 	Token string
 	// ConnectionID is the ID of the connection to use.
 	ConnectionID string
+	// PreserveHost passes original Host header to the downstream.
+	PreserveHost bool
+	// Routes are routing rules in [host]/path=downstream[,strip] format.
+	Routes []string
+	// RoutesFile is the path to YAML file with routing rules.
+	RoutesFile string
+
+	// routes are parsed Routes and RoutesFile rules.
+	routes []config.ConnectorRoute
 	// TTL is the TTL for the connection.
 	TTL time.Duration
 
@@ -52,9 +61,12 @@ func (o *ExposeOptions) BindFlags(cmd *cobra.Command) {
 
 	cmd.Flags().BoolVarP(&o.Secure, "secure", "s", false, "Secure with Basic Auth")
 	cmd.Flags().StringVarP(&o.Name, "name", "", utilstrings.GetRandomName(), "Name of the connection")
-	cmd.Flags().StringVarP(&o.DownstreamURL, "downstream", "d", "http://localhost:8080", "Downstream URL")
+	cmd.Flags().StringVarP(&o.DownstreamURL, "downstream", "d", "", "Downstream URL. Defaults to "+config.DefaultDownstreamURL+" without routes, with routes unmatched requests get 404")
 	cmd.Flags().StringVarP(&o.Token, "token", "t", "", "Token for the connection")
 	cmd.Flags().StringVarP(&o.ConnectionID, "connection-id", "c", "", "Connection ID")
+	cmd.Flags().BoolVarP(&o.PreserveHost, "preserve-host", "", false, "Pass original Host header to the downstream")
+	cmd.Flags().StringArrayVarP(&o.Routes, "route", "", nil, "Routing rule in [host]/path=downstream[,strip] format, e.g. '/api=http://localhost:8080,strip'. Can be repeated, first match wins")
+	cmd.Flags().StringVarP(&o.RoutesFile, "routes-file", "", "", "YAML file with routing rules. Rules from --route flags take precedence")
 	cmd.Flags().DurationVarP(&o.TTL, "ttl", "", time.Hour, "Timeout TTL for the connection")
 }
 
@@ -64,7 +76,9 @@ func (o *ExposeOptions) Complete(args []string) error {
 		return err
 	}
 
-	o.DownstreamURL = args[0]
+	if len(args) > 0 {
+		o.DownstreamURL = args[0]
+	}
 
 	if o.Name == "" {
 		o.Name = utilstrings.GetRandomName()
@@ -87,6 +101,22 @@ func (o *ExposeOptions) Validate() error {
 		o.create = false
 	}
 
+	for _, value := range o.Routes {
+		route, err := config.ParseRoute(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		o.routes = append(o.routes, route)
+	}
+	if o.RoutesFile != "" {
+		routes, err := config.LoadRoutes(o.RoutesFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		o.routes = append(o.routes, routes...)
+	}
+
 	return utilerrors.NewAggregate(errs)
 }
 
@@ -144,24 +174,45 @@ func (o *ExposeOptions) Run(ctx context.Context) error {
 	cfg.DownstreamURL = o.DownstreamURL
 	cfg.Token = existing.Token
 	cfg.ConnectionID = existing.ID
+	cfg.PreserveHost = o.PreserveHost
+	if len(o.routes) > 0 {
+		cfg.Routes = o.routes
+	}
+	if cfg.DownstreamURL == "" && len(cfg.Routes) == 0 {
+		cfg.DownstreamURL = config.DefaultDownstreamURL
+	}
 
 	client, err := connector.New(cfg)
 	if err != nil {
 		return err
 	}
-	go client.Run(ctx)
+	// Run returns on Ctrl+C or once the connection is disconnected remotely
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
 
 	fmt.Println("Connecting to connection: " + o.Name)
 	fmt.Println("Press Ctrl+C to exit")
 	fmt.Println("")
-	fmt.Println("URL: " + existing.Hostname + " --> " + o.DownstreamURL)
+	for _, route := range cfg.Routes {
+		hostname := existing.Hostname
+		if route.Host != "" {
+			hostname = "https://" + route.Host
+		}
+		fmt.Println("URL: " + hostname + route.PathPrefix + " --> " + route.Downstream)
+	}
+	if cfg.DownstreamURL != "" {
+		fmt.Println("URL: " + existing.Hostname + " --> " + cfg.DownstreamURL)
+	}
 	fmt.Println("")
 	if existing.Secure {
 		fmt.Println("Basic auth:")
 		fmt.Println("Username: " + existing.Username)
 		fmt.Println("Password: " + existing.Password)
 	}
-	<-ctx.Done()
+	<-done
 
 	return nil
 }
@@ -19,6 +19,16 @@ type Config struct {
 	ExternalAPIURL string `envconfig:"FAROS_API_EXTERNAL_URL" required:"true" default:"https://faros.dev.faros.sh"`
 	// ExternalGatewayURL is the URL that the gateway is externally accessible at.
 	ExternalGatewayURL string `envconfig:"FAROS_GATEWAY_EXTERNAL_URL" required:"true" default:"https://gateway.faros.sh"`
+	// GatewayAdvertiseURL is the URL this gateway replica is directly accessible
+	// at by connectors and other replicas. It must be distinct for every
+	// replica, not the shared ExternalGatewayURL. Replicas without it are not
+	// offered to connectors as gateway candidates, so connectors hold a single
+	// tunnel through the shared URL regardless of FAROS_GATEWAYS, and requests
+	// are not forwarded to them by other replicas.
+	GatewayAdvertiseURL string `envconfig:"FAROS_GATEWAY_ADVERTISE_URL"`
+	// GatewayRegion is the region this gateway replica runs in. Connectors are
+	// offered gateways in the region of their assigned gateway first.
+	GatewayRegion string `envconfig:"FAROS_GATEWAY_REGION"`
 	// DefaultGateway is the default gateway to use for the access.
 	DefaultGateway string `envconfig:"FAROS_DEFAULT_GATEWAY" required:"true" default:"https://gateway.dev.faros.sh"`
 	// InternalGatewayURL is the URL that the gateway is internally accessible at.
@@ -49,6 +59,57 @@ type Config struct {
 
 	// Quota is the quota to use for the connections per user. 0 means no quota.
 	ConnectionQuota int `envconfig:"FAROS_CONNECTIONS_QUOTA" default:"0"`
+	// UserMonthlyBandwidthQuota is the bandwidth in bytes all connections of the user can carry per month. 0 means no quota.
+	UserMonthlyBandwidthQuota int64 `envconfig:"FAROS_USER_MONTHLY_BANDWIDTH_QUOTA" default:"0"`
+
+	// DomainVerificationInterval is how often DNS challenges of pending custom domains are checked.
+	DomainVerificationInterval time.Duration `envconfig:"FAROS_DOMAIN_VERIFICATION_INTERVAL" default:"1m"`
+	// DomainClaimTTL is how long custom domain stays claimed without being verified. Expired claims
+	// are released so other users can claim the domain. 0 keeps them until deleted.
+	DomainClaimTTL time.Duration `envconfig:"FAROS_DOMAIN_CLAIM_TTL" default:"72h"`
+
+	// GatewayAuthCacheTTL is how long gateway caches successful connection credential checks. 0 disables the cache.
+	GatewayAuthCacheTTL time.Duration `envconfig:"FAROS_GATEWAY_AUTH_CACHE_TTL" default:"1m"`
+	// GatewayAuthLockoutIPThreshold is the number of failed credential checks from a client against a hostname before it is locked out of it. 0 disables it.
+	GatewayAuthLockoutIPThreshold int `envconfig:"FAROS_GATEWAY_AUTH_LOCKOUT_IP_THRESHOLD" default:"5"`
+	// GatewayAuthLockoutHostThreshold is the number of failed credential checks against a hostname before clients which failed against it are locked out of it. 0 disables it.
+	GatewayAuthLockoutHostThreshold int `envconfig:"FAROS_GATEWAY_AUTH_LOCKOUT_HOST_THRESHOLD" default:"50"`
+	// GatewayAuthLockoutBase is the first lockout duration. It doubles with every following failure.
+	GatewayAuthLockoutBase time.Duration `envconfig:"FAROS_GATEWAY_AUTH_LOCKOUT_BASE" default:"5s"`
+	// GatewayAuthLockoutMax is the maximum lockout duration.
+	GatewayAuthLockoutMax time.Duration `envconfig:"FAROS_GATEWAY_AUTH_LOCKOUT_MAX" default:"15m"`
+	// GatewayAuthLockoutReset is the quiet period after which failures are forgotten.
+	GatewayAuthLockoutReset time.Duration `envconfig:"FAROS_GATEWAY_AUTH_LOCKOUT_RESET" default:"15m"`
+	// GatewayTrustForwardedFor identifies clients by the address the proxy in front of the gateway
+	// appends to X-Forwarded-For. Enable only when every request comes through such proxy.
+	GatewayTrustForwardedFor bool `envconfig:"FAROS_GATEWAY_TRUST_FORWARDED_FOR" default:"false"`
+
+	// GatewayRateLimit is the number of requests per second allowed per connection which does not set its own.
+	// Connections setting 0 use it. 0 disables the default limit.
+	GatewayRateLimit float64 `envconfig:"FAROS_GATEWAY_RATE_LIMIT" default:"0"`
+	// GatewayRateBurst is the number of requests allowed to burst over the rate limit of connections which do not set their own.
+	GatewayRateBurst int `envconfig:"FAROS_GATEWAY_RATE_BURST" default:"0"`
+	// GatewayMaxInFlight is the number of concurrent requests allowed per connection which does not set its own.
+	// Connections setting 0 use it. 0 disables the default limit. Upgraded connections do not count once upgraded.
+	GatewayMaxInFlight int `envconfig:"FAROS_GATEWAY_MAX_IN_FLIGHT" default:"0"`
+	// GatewayHoldTimeout is the default time idempotent requests wait for the connector to reconnect. Requests are held
+	// only if a connector disconnected within it, connections offline for longer are unavailable right away. 0 disables holding.
+	GatewayHoldTimeout time.Duration `envconfig:"FAROS_GATEWAY_HOLD_TIMEOUT" default:"10s"`
+	// GatewayHoldMaxBodyBytes is the default size up to which requests with body are held too. 0 holds only idempotent requests.
+	GatewayHoldMaxBodyBytes int64 `envconfig:"FAROS_GATEWAY_HOLD_MAX_BODY_BYTES" default:"0"`
+
+	// GatewayLoadBalancing is how requests are spread between connector replicas
+	// of a connection. One of round-robin, least-in-flight.
+	GatewayLoadBalancing string `envconfig:"FAROS_GATEWAY_LOAD_BALANCING" default:"least-in-flight"`
+	// GatewayTunnelPingInterval is how often gateway pings connectors over the tunnel. 0 disables pings.
+	GatewayTunnelPingInterval time.Duration `envconfig:"FAROS_GATEWAY_TUNNEL_PING_INTERVAL" default:"15s"`
+	// GatewayTunnelPingMaxMissed is the number of pings in a row without pong after which tunnel is evicted.
+	GatewayTunnelPingMaxMissed int `envconfig:"FAROS_GATEWAY_TUNNEL_PING_MAX_MISSED" default:"3"`
+	// GatewayQUICEnabled accepts connector tunnels over QUIC on the UDP port of GatewayAddr.
+	GatewayQUICEnabled bool `envconfig:"FAROS_GATEWAY_QUIC_ENABLED" default:"false"`
+	// GatewayDrainTimeout is how long gateway going down waits for connectors
+	// to move their tunnels to other gateways before closing them.
+	GatewayDrainTimeout time.Duration `envconfig:"FAROS_GATEWAY_DRAIN_TIMEOUT" default:"30s"`
 }
 
 type OIDCConfig struct {
@@ -83,15 +144,70 @@ type Database struct {
 	MaxConnLifeTime time.Duration `envconfig:"FAROS_DATABASE_MAX_CONN_LIFE_TIME" default:"1h"`
 }
 
+// DefaultDownstreamURL is the downstream of connectors configured without routes
+const DefaultDownstreamURL = "http://localhost:8080"
+
 type ConnectorConfig struct {
 	// ControllerURL is the URL that the controller is externally reachable at.
 	ControllerURL string `envconfig:"FAROS_EXTERNAL_URL" required:"true" default:"https://ingress.faros.sh"`
 	// DownstreamURL is downstream URL for the connector to connect to.
-	DownstreamURL string `envconfig:"FAROS_DOWNSTREAM_URL" required:"true" default:"http://localhost:8080"`
+	// Requests not matching any of Routes are sent to it. Defaults to
+	// DefaultDownstreamURL without Routes, with Routes unmatched requests get 404.
+	DownstreamURL string `envconfig:"FAROS_DOWNSTREAM_URL"`
+	// TunnelsFile is the path to YAML file listing tunnels to run. When set, the
+	// connector serves all listed connections and reloads the file on changes.
+	TunnelsFile string `envconfig:"FAROS_TUNNELS_FILE"`
+	// RoutesFile is the path to YAML file with Routes.
+	RoutesFile string `envconfig:"FAROS_ROUTES_FILE"`
+	// Routes are ordered rules sending requests to different downstreams. First match wins.
+	Routes []ConnectorRoute `ignored:"true"`
 	// Token is the token used to authenticate with the API server.
 	Token string `envconfig:"FAROS_TOKEN" default:""`
 	// ConnectionID is the ID of the connection.
 	ConnectionID string `envconfig:"FAROS_CONNECTION_ID" default:""`
+	// PreserveHost passes original Host header of the request to the downstream
+	// instead of rewriting it to the downstream host.
+	PreserveHost bool `envconfig:"FAROS_PRESERVE_HOST" default:"false"`
+	// Gateways is the number of distinct gateways connector holds tunnels to at
+	// once, so restart of a single gateway does not drop the connection.
+	// Only gateway replicas with FAROS_GATEWAY_ADVERTISE_URL are distinct.
+	Gateways int `envconfig:"FAROS_GATEWAYS" default:"1"`
+	// GatewayEvaluateInterval is how often latency to candidate gateways is
+	// measured again to migrate tunnels to a faster gateway. 0 disables it.
+	GatewayEvaluateInterval time.Duration `envconfig:"FAROS_GATEWAY_EVALUATE_INTERVAL" default:"5m"`
+	// GatewayMigrateRatio is how much faster other gateway must be to migrate
+	// the tunnel to it. 0.5 migrates when its latency is less than half.
+	GatewayMigrateRatio float64 `envconfig:"FAROS_GATEWAY_MIGRATE_RATIO" default:"0.5"`
+	// ProxyURL is the outbound proxy to reach API and gateways through, with
+	// http, https or socks5 scheme and optional user:password. When empty,
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are used.
+	ProxyURL string `envconfig:"FAROS_PROXY_URL"`
+	// NoProxy lists hosts reached directly when ProxyURL is set, in NO_PROXY format.
+	NoProxy string `envconfig:"FAROS_NO_PROXY"`
+	// ProxyAuthorization is sent as Proxy-Authorization header to HTTP proxies
+	// instead of Basic credentials from ProxyURL.
+	ProxyAuthorization string `envconfig:"FAROS_PROXY_AUTHORIZATION"`
+	// TunnelTransport carries the tunnel to the gateway. One of auto, http2, websocket, quic.
+	// auto uses HTTP/2 and falls back to WebSocket when proxies downgrade to HTTP/1.1.
+	// quic falls back to auto when UDP is blocked, and is not dialed through ProxyURL.
+	TunnelTransport string `envconfig:"FAROS_TUNNEL_TRANSPORT" default:"auto"`
+	// TunnelCompression compresses responses over the tunnel when the gateway
+	// supports it. Already compressed content types are sent as they are.
+	TunnelCompression bool `envconfig:"FAROS_TUNNEL_COMPRESSION" default:"true"`
+	// RemoteActions lists remote control actions the connector executes. set-downstream and
+	// reconnect, which point the tunnel to hosts given by the action, must be opted in.
+	RemoteActions []string `envconfig:"FAROS_REMOTE_ACTIONS" default:"disconnect,reload-routes,diagnostics"`
+	// DrainTimeout bounds waiting for in-flight requests when the tunnel is
+	// closed on shutdown or moved away from a gateway going down.
+	DrainTimeout time.Duration `envconfig:"FAROS_DRAIN_TIMEOUT" default:"30s"`
+	// TunnelPingInterval is how often connector pings gateway over the tunnel. 0 disables pings.
+	TunnelPingInterval time.Duration `envconfig:"FAROS_TUNNEL_PING_INTERVAL" default:"15s"`
+	// TunnelPingMaxMissed is the number of pings in a row without pong after which connector reconnects.
+	TunnelPingMaxMissed int `envconfig:"FAROS_TUNNEL_PING_MAX_MISSED" default:"3"`
+	// HealthCheckPath is the downstream path probed to report DownstreamHealthy condition.
+	HealthCheckPath string `envconfig:"FAROS_HEALTH_CHECK_PATH" default:"/"`
+	// HealthCheckInterval is how often downstream is probed and agent information reported.
+	HealthCheckInterval time.Duration `envconfig:"FAROS_HEALTH_CHECK_INTERVAL" default:"30s"`
 	// StateDir is the directory where the connector will store its state.
 	StateDir string `envconfig:"FAROS_STATE_DIR" required:"true" default:"/var/tmp/faros/connector"`
 
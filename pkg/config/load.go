@@ -101,6 +101,16 @@ func LoadConnector() (*ConnectorConfig, error) {
 		return c, err
 	}
 
+	if c.RoutesFile != "" {
+		c.Routes, err = LoadRoutes(c.RoutesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.DownstreamURL == "" && len(c.Routes) == 0 {
+		c.DownstreamURL = DefaultDownstreamURL
+	}
+
 	err = utilfile.EnsureDirExits(c.StateDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to ensure agent data dir exists: %w", err)
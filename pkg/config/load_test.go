@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConnectorDownstream(t *testing.T) {
+	routesFile := filepath.Join(t.TempDir(), "routes.yaml")
+	require.NoError(t, os.WriteFile(routesFile, []byte("routes:\n- pathPrefix: /api\n  downstream: http://localhost:9090\n"), 0600))
+
+	for _, tc := range []struct {
+		name       string
+		downstream string
+		routesFile string
+		expected   string
+	}{
+		{name: "default without routes", expected: DefaultDownstreamURL},
+		{name: "none with routes", routesFile: routesFile, expected: ""},
+		{name: "explicit with routes", downstream: "http://localhost:3000", routesFile: routesFile, expected: "http://localhost:3000"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("FAROS_STATE_DIR", t.TempDir())
+			t.Setenv("FAROS_DOWNSTREAM_URL", tc.downstream)
+			t.Setenv("FAROS_ROUTES_FILE", tc.routesFile)
+
+			c, err := LoadConnector()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, c.DownstreamURL)
+		})
+	}
+}
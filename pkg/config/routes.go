@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ConnectorRoute sends requests matching Host and PathPrefix to Downstream.
+type ConnectorRoute struct {
+	// Host is the request host to match. Leftmost label may be a wildcard.
+	// Empty matches any host.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// PathPrefix is the path prefix to match, on path segment boundaries.
+	// Empty matches any path.
+	PathPrefix string `json:"pathPrefix,omitempty" yaml:"pathPrefix,omitempty"`
+	// Downstream is the URL requests are sent to.
+	Downstream string `json:"downstream" yaml:"downstream"`
+	// StripPrefix removes PathPrefix from the path before sending request downstream.
+	StripPrefix bool `json:"stripPrefix,omitempty" yaml:"stripPrefix,omitempty"`
+}
+
+// ConnectorRoutes is the format of the connector routes file.
+type ConnectorRoutes struct {
+	Routes []ConnectorRoute `json:"routes" yaml:"routes"`
+}
+
+// Validate checks the route is usable.
+func (r ConnectorRoute) Validate() error {
+	if r.PathPrefix != "" && !strings.HasPrefix(r.PathPrefix, "/") {
+		return fmt.Errorf("route path prefix %q must start with '/'", r.PathPrefix)
+	}
+	u, err := url.Parse(r.Downstream)
+	if err != nil {
+		return fmt.Errorf("route downstream %q is not valid: %w", r.Downstream, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("route downstream %q must be absolute URL", r.Downstream)
+	}
+	return nil
+}
+
+// ParseRoute parses route from [host]/path=downstream[,strip] format, as
+// used by command line flags. For example '/api=http://localhost:8080,strip'.
+func ParseRoute(value string) (ConnectorRoute, error) {
+	match, downstream, ok := strings.Cut(value, "=")
+	if !ok {
+		return ConnectorRoute{}, fmt.Errorf("route %q must be in [host]/path=downstream[,strip] format", value)
+	}
+
+	route := ConnectorRoute{}
+	if i := strings.Index(match, "/"); i >= 0 {
+		route.Host, route.PathPrefix = match[:i], match[i:]
+	} else {
+		route.Host = match
+	}
+
+	if d, option, ok := strings.Cut(downstream, ","); ok {
+		if option != "strip" {
+			return ConnectorRoute{}, fmt.Errorf("route %q option %q is not supported", value, option)
+		}
+		downstream = d
+		route.StripPrefix = true
+	}
+	route.Downstream = downstream
+
+	return route, route.Validate()
+}
+
+// LoadRoutes loads routes from YAML file.
+func LoadRoutes(path string) ([]ConnectorRoute, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := ConnectorRoutes{}
+	if err := yaml.UnmarshalStrict(data, &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse routes file %s: %w", path, err)
+	}
+
+	for _, route := range routes.Routes {
+		if err := route.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return routes.Routes, nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoute(t *testing.T) {
+	route, err := ParseRoute("/api=http://localhost:8080,strip")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectorRoute{PathPrefix: "/api", Downstream: "http://localhost:8080", StripPrefix: true}, route)
+
+	route, err = ParseRoute("admin.example.com/=http://localhost:9090")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectorRoute{Host: "admin.example.com", PathPrefix: "/", Downstream: "http://localhost:9090"}, route)
+
+	for _, value := range []string{"/api", "/api=localhost:8080", "/api=http://localhost:8080,rewrite"} {
+		_, err = ParseRoute(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ConnectorTunnels is the format of the connector tunnels file, listing
+// connections a single connector process serves.
+type ConnectorTunnels struct {
+	// ControllerURL overrides FAROS_EXTERNAL_URL for all tunnels.
+	ControllerURL string `json:"controllerUrl,omitempty" yaml:"controllerUrl,omitempty"`
+	// Kubeconfig holds user credentials used to resolve tunnels referring to
+	// connections by name.
+	Kubeconfig string `json:"kubeconfig,omitempty" yaml:"kubeconfig,omitempty"`
+	// Tunnels are the tunnels to run, one per connection.
+	Tunnels []ConnectorTunnel `json:"tunnels" yaml:"tunnels"`
+}
+
+// ConnectorTunnel is a single tunnel of the tunnels file. Connection is
+// referred to either by ConnectionID with token, or by Name, in which case ID
+// and token are looked up using Kubeconfig credentials.
+type ConnectorTunnel struct {
+	// Name is the name of the connection.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// ConnectionID is the ID of the connection.
+	ConnectionID string `json:"connectionId,omitempty" yaml:"connectionId,omitempty"`
+
+	// Token is the connection token.
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// TokenFile is the path to file with the connection token.
+	TokenFile string `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"`
+	// TokenEnv is the name of environment variable with the connection token.
+	TokenEnv string `json:"tokenEnv,omitempty" yaml:"tokenEnv,omitempty"`
+
+	// Downstream is the URL requests not matching any of Routes are sent to.
+	Downstream string `json:"downstream,omitempty" yaml:"downstream,omitempty"`
+	// Routes are ordered rules sending requests to different downstreams.
+	Routes []ConnectorRoute `json:"routes,omitempty" yaml:"routes,omitempty"`
+	// PreserveHost passes original Host header to downstreams.
+	PreserveHost bool `json:"preserveHost,omitempty" yaml:"preserveHost,omitempty"`
+	// Compression overrides FAROS_TUNNEL_COMPRESSION for the tunnel.
+	Compression *bool `json:"compression,omitempty" yaml:"compression,omitempty"`
+
+	// TLS overrides connector TLS files for the tunnel.
+	TLS ConnectorTunnelTLS `json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+type ConnectorTunnelTLS struct {
+	ServerCertFile string `json:"serverCertFile,omitempty" yaml:"serverCertFile,omitempty"`
+	ServerKeyFile  string `json:"serverKeyFile,omitempty" yaml:"serverKeyFile,omitempty"`
+	ClientCertFile string `json:"clientCertFile,omitempty" yaml:"clientCertFile,omitempty"`
+	ClientKeyFile  string `json:"clientKeyFile,omitempty" yaml:"clientKeyFile,omitempty"`
+}
+
+// Key identifies the tunnel within the tunnels file.
+func (t ConnectorTunnel) Key() string {
+	if t.ConnectionID != "" {
+		return "id/" + t.ConnectionID
+	}
+	return "name/" + t.Name
+}
+
+// HasToken returns true if any token source is set.
+func (t ConnectorTunnel) HasToken() bool {
+	return t.Token != "" || t.TokenFile != "" || t.TokenEnv != ""
+}
+
+// ResolveToken reads the connection token from its source.
+func (t ConnectorTunnel) ResolveToken() (string, error) {
+	switch {
+	case t.Token != "":
+		return t.Token, nil
+	case t.TokenFile != "":
+		data, err := ioutil.ReadFile(t.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	case t.TokenEnv != "":
+		token := os.Getenv(t.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty", t.TokenEnv)
+		}
+		return token, nil
+	}
+	return "", fmt.Errorf("tunnel %s has no token source", t.Key())
+}
+
+// Validate checks the tunnel is usable.
+func (t ConnectorTunnel) Validate() error {
+	switch {
+	case t.ConnectionID == "" && t.Name == "":
+		return fmt.Errorf("tunnel must have either name or connectionId")
+	case t.ConnectionID != "" && !t.HasToken():
+		return fmt.Errorf("tunnel %s must have token, tokenFile or tokenEnv", t.Key())
+	case t.Downstream == "" && len(t.Routes) == 0:
+		return fmt.Errorf("tunnel %s must have downstream or routes", t.Key())
+	}
+	for _, route := range t.Routes {
+		if err := route.Validate(); err != nil {
+			return fmt.Errorf("tunnel %s: %w", t.Key(), err)
+		}
+	}
+	return nil
+}
+
+// ParseTunnels parses and validates tunnels file content.
+func ParseTunnels(data []byte) (*ConnectorTunnels, error) {
+	tunnels := &ConnectorTunnels{}
+	if err := yaml.UnmarshalStrict(data, tunnels); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, tunnel := range tunnels.Tunnels {
+		if err := tunnel.Validate(); err != nil {
+			return nil, err
+		}
+		if keys[tunnel.Key()] {
+			return nil, fmt.Errorf("tunnel %s is listed more than once", tunnel.Key())
+		}
+		keys[tunnel.Key()] = true
+
+		if tunnel.ConnectionID == "" && tunnels.Kubeconfig == "" {
+			return nil, fmt.Errorf("tunnel %s refers to connection by name, kubeconfig is required", tunnel.Key())
+		}
+	}
+
+	return tunnels, nil
+}
@@ -0,0 +1,132 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+	utiltunnel "github.com/faroshq/faros-ingress/pkg/util/tunnel"
+	"github.com/faroshq/faros-ingress/pkg/util/version"
+)
+
+// handleAction executes remote control command sent by the gateway and
+// acknowledges it. Actions closing the tunnel run after the acknowledgement.
+func (c *Connection) handleAction(ctx context.Context, t *tunnel, l *h2rev2.Listener, action api.ConnectionAction) {
+	logger := klog.FromContext(ctx).WithValues("action", action.Type, "id", action.ID)
+	logger.V(2).Info("executing action")
+
+	utiltunnel.HandleAction(logger, l, action, func(action api.ConnectionAction, result *api.ConnectionAction) (func(), error) {
+		if err := actionEnabled(c.currentConfig(), action.Type); err != nil {
+			logger.Info("rejected remote action not enabled on the connector")
+			return nil, err
+		}
+
+		switch action.Type {
+		case api.ConnectionActionDisconnect:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.stop, nil
+		case api.ConnectionActionReconnect:
+			c.mu.Lock()
+			t.nextGatewayURL = action.GatewayURL
+			c.mu.Unlock()
+			return func() { l.Close() }, nil
+		case api.ConnectionActionSetDownstream:
+			return nil, c.reconfigure(ctx, func(cfg *config.ConnectorConfig) error {
+				if _, err := url.Parse(action.DownstreamURL); err != nil {
+					return err
+				}
+				cfg.DownstreamURL = action.DownstreamURL
+				return nil
+			})
+		case api.ConnectionActionReloadRoutes:
+			return nil, c.reconfigure(ctx, func(cfg *config.ConnectorConfig) error {
+				if cfg.RoutesFile == "" {
+					return fmt.Errorf("connector has no routes file")
+				}
+				routes, err := config.LoadRoutes(cfg.RoutesFile)
+				if err != nil {
+					return err
+				}
+				cfg.Routes = routes
+				return nil
+			})
+		case api.ConnectionActionDiagnostics:
+			result.Diagnostics = c.diagnostics(ctx)
+			return nil, nil
+		default:
+			return nil, utiltunnel.NotSupportedError(action.Type)
+		}
+	})
+}
+
+// actionEnabled fails actions not listed in RemoteActions of the config
+func actionEnabled(cfg *config.ConnectorConfig, action api.ConnectionActionType) error {
+	for _, enabled := range cfg.RemoteActions {
+		if api.ConnectionActionType(strings.TrimSpace(enabled)) == action {
+			return nil
+		}
+	}
+	return fmt.Errorf("action '%s' is not enabled on the connector, see FAROS_REMOTE_ACTIONS", action)
+}
+
+// reconfigure applies change to a copy of the config and swaps the router,
+// so requests in flight finish against the old downstreams.
+func (c *Connection) reconfigure(ctx context.Context, change func(cfg *config.ConnectorConfig) error) error {
+	cfg := *c.currentConfig()
+	if err := change(&cfg); err != nil {
+		return err
+	}
+
+	router, err := newRouter(&cfg, klog.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = &cfg
+	c.router = router
+	return nil
+}
+
+// diagnostics collects information useful to debug the connector remotely
+func (c *Connection) diagnostics(ctx context.Context) map[string]string {
+	cfg := c.currentConfig()
+	hostname, _ := os.Hostname()
+
+	c.mu.Lock()
+	gatewayURLs := make([]string, 0, len(c.tunnels))
+	for _, t := range c.tunnels {
+		if t.gatewayURL != "" {
+			gatewayURLs = append(gatewayURLs, t.gatewayURL)
+		}
+	}
+	c.mu.Unlock()
+
+	status, reason, message := newAgentReporter(c.currentConfig, nil).probe(ctx)
+
+	return map[string]string{
+		"version":           version.GetVersion().Version,
+		"hostname":          hostname,
+		"os":                runtime.GOOS + "/" + runtime.GOARCH,
+		"uptime":            time.Since(startedAt).Round(time.Second).String(),
+		"goroutines":        strconv.Itoa(runtime.NumGoroutine()),
+		"gateway":           strings.Join(gatewayURLs, ","),
+		"downstream":        cfg.DownstreamURL,
+		"routes":            strconv.Itoa(len(cfg.Routes)),
+		"downstreamHealthy": fmt.Sprintf("%s (%s: %s)", status, reason, message),
+		"compression":       strconv.FormatBool(cfg.TunnelCompression),
+		"compressionRatio":  strconv.FormatFloat(c.compression.Ratio(), 'f', 2, 64),
+	}
+}
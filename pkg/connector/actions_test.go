@@ -0,0 +1,64 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+func TestReconfigure(t *testing.T) {
+	downstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+	}
+	old, new := downstream("old"), downstream("new")
+	defer old.Close()
+	defer new.Close()
+
+	ctx := context.Background()
+	c := &Connection{config: &config.ConnectorConfig{DownstreamURL: old.URL}}
+	require.NoError(t, c.reconfigure(ctx, func(cfg *config.ConnectorConfig) error { return nil }))
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Body.String()
+	}
+	assert.Equal(t, "old", get())
+
+	previous := c.currentConfig()
+	require.NoError(t, c.reconfigure(ctx, func(cfg *config.ConnectorConfig) error {
+		cfg.DownstreamURL = new.URL
+		return nil
+	}))
+	assert.Equal(t, "new", get())
+	assert.Equal(t, old.URL, previous.DownstreamURL, "config in use by others must not change")
+	assert.Equal(t, new.URL, c.diagnostics(ctx)["downstream"])
+
+	// failed change keeps serving current downstream
+	err := c.reconfigure(ctx, func(cfg *config.ConnectorConfig) error {
+		cfg.Routes = []config.ConnectorRoute{{PathPrefix: "api", Downstream: old.URL}}
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "new", get())
+}
+
+func TestActionEnabled(t *testing.T) {
+	cfg := &config.ConnectorConfig{RemoteActions: []string{"disconnect", " diagnostics"}}
+
+	assert.NoError(t, actionEnabled(cfg, api.ConnectionActionDisconnect))
+	assert.NoError(t, actionEnabled(cfg, api.ConnectionActionDiagnostics))
+	// actions pointing the tunnel elsewhere are opt-in
+	assert.Error(t, actionEnabled(cfg, api.ConnectionActionSetDownstream))
+	assert.Error(t, actionEnabled(cfg, api.ConnectionActionReconnect))
+}
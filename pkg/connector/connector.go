@@ -7,33 +7,51 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"sync"
 	"time"
 
-	"golang.org/x/net/http2"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"github.com/go-logr/logr"
 	"k8s.io/klog/v2"
-	"k8s.io/utils/clock"
 
 	"github.com/faroshq/faros-ingress/pkg/api"
 	"github.com/faroshq/faros-ingress/pkg/config"
 	"github.com/faroshq/faros-ingress/pkg/connector/client"
 	"github.com/faroshq/faros-ingress/pkg/h2rev2"
-	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
-)
-
-const (
-	dialerSufix = "/api/v1alpha1/proxy"
+	utilproxy "github.com/faroshq/faros-ingress/pkg/util/proxy"
+	utiltunnel "github.com/faroshq/faros-ingress/pkg/util/tunnel"
 )
 
 type Connection struct {
-	config         *config.ConnectorConfig
 	upstreamClient *http.Client
 	tlsConfig      *tls.Config
 	apiClient      client.Client
+	// compression counts responses compressed over all tunnels
+	compression h2rev2.CompressionStats
+
+	mu sync.Mutex // guards below
+	// config is replaced as a whole when actions change it
+	config  *config.ConnectorConfig
+	router  *router
+	tunnels []*tunnel
+	stop    context.CancelFunc
+}
 
+// tunnel is a single reverse tunnel to a gateway. Connection keeps one tunnel
+// per gateway it is configured to stay connected to.
+type tunnel struct {
+	// guarded by Connection.mu
 	gatewayURL string
+	// hostname is the gateway tunnel is connected to and latency measured to it
+	hostname string
+	latency  time.Duration
+	// listener is the tunnel currently served, it measures latency by pings
+	listener *h2rev2.Listener
+	// nextGatewayURL is used instead of asking API on the next reconnect
+	nextGatewayURL string
+	// drainPrevious drains the tunnel the gateway asked to leave, once its
+	// replacement is attached
+	drainPrevious func()
 }
 
 func New(config *config.ConnectorConfig) (*Connection, error) {
@@ -74,14 +92,23 @@ func New(config *config.ConnectorConfig) (*Connection, error) {
 		InsecureSkipVerify: true,
 	}
 
-	upstreamClient := &http.Client{Transport: transport2(config)}
+	dialer, err := utilproxy.NewDialer(utilproxy.Config{
+		URL:           config.ProxyURL,
+		NoProxy:       config.NoProxy,
+		Authorization: config.ProxyAuthorization,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamClient := utiltunnel.Client(gatewayTLSConfig(config), dialer)
 
 	u, err := url.Parse(config.ControllerURL)
 	if err != nil {
 		return nil, err
 	}
 
-	apiClient := client.NewClient(u, config.Token, nil)
+	apiClient := client.NewClient(u, config.Token, utiltunnel.APIHTTPClient(&tls.Config{InsecureSkipVerify: true}, dialer)) // TODO
 
 	return &Connection{
 		apiClient:      apiClient,
@@ -91,15 +118,7 @@ func New(config *config.ConnectorConfig) (*Connection, error) {
 	}, nil
 }
 
-func transport2(config *config.ConnectorConfig) *http2.Transport {
-	return &http2.Transport{
-		TLSClientConfig:    tlsConfig(config),
-		DisableCompression: true,
-		AllowHTTP:          false,
-	}
-}
-
-func tlsConfig(config *config.ConnectorConfig) *tls.Config {
+func gatewayTLSConfig(config *config.ConnectorConfig) *tls.Config {
 	crt, err := ioutil.ReadFile(config.TLSServerCertFile)
 	if err != nil {
 		log.Fatal(err)
@@ -115,90 +134,250 @@ func tlsConfig(config *config.ConnectorConfig) *tls.Config {
 	}
 }
 
-// startTunnel blocks until the context is cancelled trying to establish a tunnel against the specified target
+// Run blocks until the context is cancelled or the tunnel is disconnected
+// remotely, trying to establish tunnels against distinct gateways
 func (c *Connection) Run(ctx context.Context) {
-	// connect to create the reverse tunnels
-	var (
-		initBackoff   = time.Second
-		maxBackoff    = time.Minute
-		resetDuration = time.Second * 2
-		backoffFactor = 2.0
-		jitter        = 1.0
-		clock         = &clock.RealClock{}
-		sliding       = true
-	)
-
-	backoffMgr := wait.NewExponentialBackoffManager(initBackoff, maxBackoff, resetDuration, backoffFactor, jitter, clock)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := c.currentConfig().Gateways
+	if count < 1 {
+		count = 1
+	}
+
+	c.mu.Lock()
+	c.stop = cancel
+	c.tunnels = make([]*tunnel, count)
+	for i := range c.tunnels {
+		c.tunnels[i] = &tunnel{}
+	}
+	tunnels := c.tunnels
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, t := range tunnels {
+		wg.Add(1)
+		go func(i int, t *tunnel) {
+			defer wg.Done()
+			c.keepTunnel(klog.NewContext(ctx, klog.FromContext(ctx).WithValues("tunnel", i)), t)
+		}(i, t)
+	}
+	wg.Wait()
+}
+
+// keepTunnel reconnects the tunnel with backoff until the context is cancelled
+func (c *Connection) keepTunnel(ctx context.Context, t *tunnel) {
 	logger := klog.FromContext(ctx)
 
 	// get gateway url:
 	// call API and ask for agent gateway url
 
-	wait.BackoffUntil(func() {
-		logger.V(4).Info("get gateway for tunnel")
-		gateway, err := c.apiClient.GetConnectionGateway(ctx, api.Connection{ID: c.config.ConnectionID})
-		if err != nil {
-			klog.Error(err, "failed to get gateway", "connection", c.config.ConnectionID)
-			return
+	utiltunnel.Until(ctx, func() {
+		cfg := c.currentConfig()
+
+		c.mu.Lock()
+		gatewayURL := t.nextGatewayURL
+		t.nextGatewayURL = ""
+		t.gatewayURL = ""
+		// gateway the tunnel is moved to stays reserved
+		t.hostname = gatewayURL
+		t.latency = 0
+		c.mu.Unlock()
+
+		var latency time.Duration
+
+		if gatewayURL == "" {
+			logger.V(4).Info("get gateway for tunnel")
+			gateway, err := c.apiClient.GetConnectionGateway(ctx, api.Connection{ID: cfg.ConnectionID})
+			if err != nil {
+				klog.Error(err, "failed to get gateway", "connection", cfg.ConnectionID)
+				return
+			}
+			latencies := c.measureLatencies(ctx, gateway)
+			gatewayURL = c.reserveGateway(t, gateway, latencies)
+			if gatewayURL == "" {
+				logger.V(2).Info("no spare gateway for tunnel, waiting")
+				return
+			}
+			latency = latencies[gatewayURL]
+			logger.V(2).Info("chose gateway", "gateway", gatewayURL, "latency", latency)
 		}
 
-		c.gatewayURL = gateway.Hostname + dialerSufix
+		c.mu.Lock()
+		t.gatewayURL = gatewayURL + utiltunnel.GatewayPath
+		t.hostname = gatewayURL
+		t.latency = latency
+		c.mu.Unlock()
 
 		logger.V(4).Info("starting tunnel")
-		err = c.startTunneler(ctx)
+		err := c.startTunneler(ctx, t)
 		if err != nil {
 			logger.Error(err, "failed to create tunnel")
 		}
-	}, backoffMgr, sliding, ctx.Done())
+	})
+
+	c.mu.Lock()
+	drainPrevious := t.drainPrevious
+	t.drainPrevious = nil
+	c.mu.Unlock()
+	if drainPrevious != nil {
+		drainPrevious()
+	}
 }
 
-func (c *Connection) startTunneler(ctx context.Context) error {
+// reserveGateway picks the gateway for the tunnel and reserves it within the
+// same critical section, so tunnels connecting at once pick distinct ones
+func (c *Connection) reserveGateway(t *tunnel, gateway *api.ConnectionGateway, latencies map[string]time.Duration) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.hostname = pickGateway(gateway, latencies, c.usedGatewaysLocked())
+	return t.hostname
+}
+
+// usedGateways returns gateways tunnels are connected to
+func (c *Connection) usedGateways() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usedGatewaysLocked()
+}
+
+func (c *Connection) usedGatewaysLocked() map[string]bool {
+	used := map[string]bool{}
+	for _, t := range c.tunnels {
+		if t.hostname != "" {
+			used[t.hostname] = true
+		}
+	}
+	return used
+}
+
+func (c *Connection) startTunneler(ctx context.Context, t *tunnel) error {
 	logger := klog.FromContext(ctx)
+	cfg := c.currentConfig()
+
+	c.mu.Lock()
+	gatewayURL := t.gatewayURL
+	c.mu.Unlock()
 
-	logger = logger.WithValues("to", c.config.DownstreamURL).WithValues("from", c.gatewayURL)
+	logger = logger.WithValues("to", cfg.DownstreamURL).WithValues("from", gatewayURL)
 	logger.V(2).Info("connecting to destination URL")
 
-	l, err := h2rev2.NewListener(c.upstreamClient, c.gatewayURL, c.config.Token)
+	// client --> local dev instances
+	router, err := newRouter(cfg, logger)
 	if err != nil {
 		return err
 	}
-
-	// client --> local dev instance
-	downstreamURL, err := url.Parse(c.config.DownstreamURL)
+	c.mu.Lock()
+	c.router = router
+	c.mu.Unlock()
+
+	l, err := h2rev2.NewListener(c.upstreamClient, gatewayURL, cfg.Token, h2rev2.ListenerOptions{
+		Liveness: h2rev2.Liveness{
+			Interval:  cfg.TunnelPingInterval,
+			MaxMissed: cfg.TunnelPingMaxMissed,
+		},
+		Transport: h2rev2.Transport(cfg.TunnelTransport),
+	})
 	if err != nil {
 		return err
 	}
-
-	// dev-proxy-server --> local dev instance
-	proxy := httputil.NewSingleHostReverseProxy(downstreamURL)
-	if err != nil {
-		return err
+	c.mu.Lock()
+	t.listener = l
+	drainPrevious := t.drainPrevious
+	t.drainPrevious = nil
+	c.mu.Unlock()
+	if drainPrevious != nil {
+		logger.V(2).Info("replacement tunnel attached, draining previous one")
+		go drainPrevious()
 	}
+	defer func() {
+		c.mu.Lock()
+		t.listener = nil
+		c.mu.Unlock()
+	}()
 
-	director := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		logger.V(4).Info("proxying request", "method", req.Method, "path", req.URL.Path)
-		req.Host = downstreamURL.Host
-		director(req)
-	}
-	clientDownstream := utilhttp.DefaultInsecureClient // TODO
-	proxy.Transport = clientDownstream.Transport       // TODO
+	l.OnAction(func(action api.ConnectionAction) {
+		c.handleAction(ctx, t, l, action)
+	})
+
+	// report agent information and downstream health over the control channel
+	reporterCtx, cancelReporter := context.WithCancel(ctx)
+	defer cancelReporter()
+	reporter := newAgentReporter(c.currentConfig, l.ReportAgent)
+	reporter.gateways = c.agentGateways
+	reporter.compression = c.agentCompression
+	go reporter.run(reporterCtx)
+
+	// migrate the tunnel if other gateway becomes much faster
+	go c.evaluateGateway(reporterCtx, t, l)
 
 	// reverse proxy the request coming from the reverse connection to the apiserver
-	server := &http.Server{Handler: proxy}
-	defer server.Close()
+	var handler http.Handler = c
+	if cfg.TunnelCompression {
+		handler = h2rev2.CompressHandler(c, &c.compression)
+	}
+	server := &http.Server{Handler: handler}
 
 	logger.V(2).Info("serving on reverse connection")
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 	go func() {
 		errCh <- server.Serve(l)
 	}()
 
 	select {
 	case err = <-errCh:
+		server.Close()
 	case <-ctx.Done():
-		err = server.Close()
+		// finish in-flight requests before exiting
+		c.drain(logger, server, l)
+	case <-l.GoAway():
+		// tunnel keeps serving requests until the next one is attached to
+		// another gateway
+		logger.V(2).Info("gateway is going away, moving tunnel")
+		c.mu.Lock()
+		t.drainPrevious = func() { c.drain(logger, server, l) }
+		c.mu.Unlock()
 	}
 	logger.V(2).Info("stop serving on reverse connection")
 	return err
 }
+
+// drain closes the tunnel once its in-flight requests finish, or the drain
+// timeout passes
+func (c *Connection) drain(logger logr.Logger, server *http.Server, l *h2rev2.Listener) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.currentConfig().DrainTimeout)
+	defer cancel()
+
+	if err := l.Shutdown(ctx); err != nil {
+		logger.Info("closing tunnel with requests in flight", "err", err)
+	}
+	server.Close()
+}
+
+// ServeHTTP sends requests to the current router, which actions may replace
+func (c *Connection) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	router := c.router
+	c.mu.Unlock()
+
+	router.ServeHTTP(w, req)
+}
+
+// agentCompression returns the size of compressed responses, nil if none was compressed
+func (c *Connection) agentCompression() *api.ConnectionAgentCompression {
+	uncompressed, compressed := c.compression.Bytes()
+	if compressed == 0 {
+		return nil
+	}
+	return &api.ConnectionAgentCompression{
+		UncompressedBytes: uncompressed,
+		CompressedBytes:   compressed,
+		Ratio:             c.compression.Ratio(),
+	}
+}
+
+func (c *Connection) currentConfig() *config.ConnectorConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config
+}
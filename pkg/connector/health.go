@@ -0,0 +1,140 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/util/version"
+)
+
+// downstreamProbeTimeout bounds a single downstream health probe
+const downstreamProbeTimeout = 5 * time.Second
+
+// startedAt is the time connector process started
+var startedAt = time.Now()
+
+// agentReporter probes downstream periodically and reports agent information
+// with DownstreamHealthy condition to the gateway.
+type agentReporter struct {
+	// config returns current connector config, downstream may change at runtime
+	config func() *config.ConnectorConfig
+	client *http.Client
+	report func(api.ConnectionAgent)
+	// gateways returns gateways connector is connected to, if set
+	gateways func() []api.ConnectionAgentGateway
+	// compression returns size of compressed responses, if set
+	compression func() *api.ConnectionAgentCompression
+
+	condition api.ConnectionCondition
+}
+
+func newAgentReporter(cfg func() *config.ConnectorConfig, report func(api.ConnectionAgent)) *agentReporter {
+	return &agentReporter{
+		config: cfg,
+		client: &http.Client{
+			Timeout: downstreamProbeTimeout,
+			// redirect is an answer too, don't follow it
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		report: report,
+	}
+}
+
+// run reports agent information until the context is cancelled
+func (r *agentReporter) run(ctx context.Context) {
+	interval := r.config().HealthCheckInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.report(r.agent(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// agent returns agent information with result of a fresh downstream probe
+func (r *agentReporter) agent(ctx context.Context) api.ConnectionAgent {
+	hostname, _ := os.Hostname()
+
+	r.setCondition(r.probe(ctx))
+
+	var gateways []api.ConnectionAgentGateway
+	if r.gateways != nil {
+		gateways = r.gateways()
+	}
+
+	var compression *api.ConnectionAgentCompression
+	if r.compression != nil {
+		compression = r.compression()
+	}
+
+	return api.ConnectionAgent{
+		Version:       version.GetVersion().Version,
+		Hostname:      hostname,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		StartedAt:     startedAt,
+		DownstreamURL: r.config().DownstreamURL,
+		Gateways:      gateways,
+		Conditions:    []api.ConnectionCondition{r.condition},
+		Compression:   compression,
+	}
+}
+
+// probe sends request to downstream. Any response below 500 means downstream is answering.
+func (r *agentReporter) probe(ctx context.Context) (api.ConditionStatus, string, string) {
+	cfg := r.config()
+	if cfg.DownstreamURL == "" {
+		return api.ConditionUnknown, "NoDownstream", "no default downstream configured"
+	}
+
+	u, err := url.Parse(cfg.DownstreamURL)
+	if err != nil {
+		return api.ConditionFalse, "InvalidURL", err.Error()
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(cfg.HealthCheckPath, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return api.ConditionFalse, "InvalidURL", err.Error()
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return api.ConditionFalse, "ProbeFailed", err.Error()
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return api.ConditionFalse, "ProbeFailed", fmt.Sprintf("downstream responded with %d", resp.StatusCode)
+	}
+	return api.ConditionTrue, "ProbeSucceeded", fmt.Sprintf("downstream responded with %d", resp.StatusCode)
+}
+
+// setCondition updates DownstreamHealthy condition, moving transition time only when status changes
+func (r *agentReporter) setCondition(status api.ConditionStatus, reason, message string) {
+	if r.condition.Status != status {
+		r.condition.LastTransitionTime = time.Now()
+	}
+	r.condition.Type = api.ConditionDownstreamHealthy
+	r.condition.Status = status
+	r.condition.Reason = reason
+	r.condition.Message = message
+}
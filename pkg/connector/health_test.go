@@ -0,0 +1,61 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+func TestAgentReporterDownstreamHealth(t *testing.T) {
+	status := http.StatusOK
+	var probedPath string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probedPath = r.URL.Path
+		w.WriteHeader(status)
+	}))
+	defer downstream.Close()
+
+	cfg := &config.ConnectorConfig{
+		DownstreamURL:   downstream.URL + "/app/",
+		HealthCheckPath: "/healthz",
+	}
+	r := newAgentReporter(func() *config.ConnectorConfig { return cfg }, nil)
+
+	agent := r.agent(context.Background())
+	assert.Equal(t, "/app/healthz", probedPath)
+	assert.Equal(t, downstream.URL+"/app/", agent.DownstreamURL)
+	assert.False(t, agent.StartedAt.IsZero())
+	require.Len(t, agent.Conditions, 1)
+	healthy := agent.Conditions[0]
+	assert.Equal(t, api.ConditionDownstreamHealthy, healthy.Type)
+	assert.Equal(t, api.ConditionTrue, healthy.Status)
+
+	// transition time stays while status does not change
+	agent = r.agent(context.Background())
+	assert.Equal(t, healthy.LastTransitionTime, agent.Conditions[0].LastTransitionTime)
+
+	status = http.StatusBadGateway
+	agent = r.agent(context.Background())
+	assert.Equal(t, api.ConditionFalse, agent.Conditions[0].Status)
+	assert.True(t, agent.Conditions[0].LastTransitionTime.After(healthy.LastTransitionTime))
+
+	downstream.Close()
+	agent = r.agent(context.Background())
+	assert.Equal(t, api.ConditionFalse, agent.Conditions[0].Status)
+	assert.Equal(t, "ProbeFailed", agent.Conditions[0].Reason)
+}
+
+func TestAgentReporterNoDownstream(t *testing.T) {
+	r := newAgentReporter(func() *config.ConnectorConfig { return &config.ConnectorConfig{} }, nil)
+
+	agent := r.agent(context.Background())
+	require.Len(t, agent.Conditions, 1)
+	assert.Equal(t, api.ConditionUnknown, agent.Conditions[0].Status)
+}
@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+)
+
+const (
+	// latencyProbes is the number of pings sent to measure gateway latency.
+	// The fastest one is used, so connection setup is not counted.
+	latencyProbes = 3
+	// latencyProbeTimeout bounds a single gateway ping
+	latencyProbeTimeout = 5 * time.Second
+)
+
+// measureLatency returns round trip time of a ping to the gateway
+func (c *Connection) measureLatency(ctx context.Context, hostname string) (time.Duration, error) {
+	var best time.Duration
+	for i := 0; i < latencyProbes; i++ {
+		rtt, err := c.ping(ctx, hostname)
+		if err != nil {
+			return 0, err
+		}
+		if best == 0 || rtt < best {
+			best = rtt
+		}
+	}
+	return best, nil
+}
+
+func (c *Connection) ping(ctx context.Context, hostname string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, latencyProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(hostname, "/")+api.GatewayPingPath, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := c.upstreamClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	rtt := time.Since(start)
+
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, fmt.Errorf("gateway responded with %d", resp.StatusCode)
+	}
+	return rtt, nil
+}
+
+// measureLatencies measures latency to all candidates at once. Candidates
+// failing to answer are left out.
+func (c *Connection) measureLatencies(ctx context.Context, gateway *api.ConnectionGateway) map[string]time.Duration {
+	logger := klog.FromContext(ctx)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = map[string]time.Duration{}
+	)
+	for _, hostname := range candidates(gateway) {
+		wg.Add(1)
+		go func(hostname string) {
+			defer wg.Done()
+			rtt, err := c.measureLatency(ctx, hostname)
+			if err != nil {
+				logger.V(4).Info("failed to measure gateway latency", "gateway", hostname, "err", err)
+				return
+			}
+			mu.Lock()
+			result[hostname] = rtt
+			mu.Unlock()
+		}(hostname)
+	}
+	wg.Wait()
+	return result
+}
+
+// candidates returns distinct gateways in the order API ranked them. The
+// assigned gateway is used only without candidates, as it may be the shared
+// URL leading to any of them.
+func candidates(gateway *api.ConnectionGateway) []string {
+	hostnames := candidateHostnames(gateway.Candidates)
+	if len(hostnames) == 0 {
+		hostnames = []string{gateway.Hostname}
+	}
+
+	seen := map[string]bool{}
+	result := []string{}
+	for _, hostname := range hostnames {
+		if hostname == "" || seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+		result = append(result, hostname)
+	}
+	return result
+}
+
+func candidateHostnames(candidates []api.ConnectionGatewayCandidate) []string {
+	result := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, candidate.Hostname)
+	}
+	return result
+}
+
+// pickGateway returns the lowest latency gateway not used by other tunnels.
+// Without measurements the API ranking is used. Empty string means no
+// gateway is spare.
+func pickGateway(gateway *api.ConnectionGateway, latencies map[string]time.Duration, used map[string]bool) string {
+	var (
+		picked     string
+		pickedRTT  time.Duration
+		firstSpare string
+	)
+	for _, hostname := range candidates(gateway) {
+		if used[hostname] {
+			continue
+		}
+		if firstSpare == "" {
+			firstSpare = hostname
+		}
+		rtt, ok := latencies[hostname]
+		if ok && (picked == "" || rtt < pickedRTT) {
+			picked, pickedRTT = hostname, rtt
+		}
+	}
+	if picked == "" {
+		return firstSpare
+	}
+	return picked
+}
+
+// evaluateGateway periodically measures latency to candidate gateways and
+// closes the tunnel to reconnect it to a gateway which became much faster.
+func (c *Connection) evaluateGateway(ctx context.Context, t *tunnel, l *h2rev2.Listener) {
+	cfg := c.currentConfig()
+	if cfg.GatewayEvaluateInterval <= 0 {
+		return
+	}
+	logger := klog.FromContext(ctx)
+
+	ticker := time.NewTicker(cfg.GatewayEvaluateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		gateway, err := c.apiClient.GetConnectionGateway(ctx, api.Connection{ID: cfg.ConnectionID})
+		if err != nil {
+			logger.V(2).Info("failed to get gateway candidates", "err", err)
+			continue
+		}
+		latencies := c.measureLatencies(ctx, gateway)
+
+		c.mu.Lock()
+		current := t.hostname
+		if rtt, ok := latencies[current]; ok {
+			t.latency = rtt
+		}
+		currentRTT := t.latency
+		c.mu.Unlock()
+
+		best := pickGateway(gateway, latencies, c.usedGateways())
+		bestRTT, ok := latencies[best]
+		if !ok || currentRTT == 0 || float64(bestRTT) >= float64(currentRTT)*cfg.GatewayMigrateRatio {
+			continue
+		}
+
+		logger.V(2).Info("migrating tunnel to faster gateway", "from", current, "to", best, "latency", bestRTT, "previousLatency", currentRTT)
+		c.mu.Lock()
+		t.nextGatewayURL = best
+		c.mu.Unlock()
+		l.Close()
+		return
+	}
+}
+
+// agentGateways returns gateways the tunnels are connected to with their latency
+func (c *Connection) agentGateways() []api.ConnectionAgentGateway {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := []api.ConnectionAgentGateway{}
+	for _, t := range c.tunnels {
+		if t.hostname == "" {
+			continue
+		}
+		latency := t.latency
+		// ping over the tunnel is measured continuously
+		if t.listener != nil && t.listener.RTT() > 0 {
+			latency = t.listener.RTT()
+		}
+		result = append(result, api.ConnectionAgentGateway{
+			Hostname: t.hostname,
+			Latency:  latency,
+		})
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+)
+
+func TestPickGateway(t *testing.T) {
+	gateway := &api.ConnectionGateway{
+		Hostname: "https://gateway.example.com",
+		Candidates: []api.ConnectionGatewayCandidate{
+			{Hostname: "https://a.example.com"},
+			{Hostname: "https://b.example.com"},
+			{Hostname: "https://c.example.com"},
+		},
+	}
+
+	// assigned gateway is used only without candidates, it may be the shared
+	// URL of them
+	assert.Equal(t, "https://gateway.example.com", pickGateway(&api.ConnectionGateway{Hostname: "https://gateway.example.com"}, nil, map[string]bool{}))
+
+	// without measurements API ranking is used
+	assert.Equal(t, "https://a.example.com", pickGateway(gateway, nil, map[string]bool{}))
+	assert.Equal(t, "https://b.example.com", pickGateway(gateway, nil, map[string]bool{"https://a.example.com": true}))
+
+	latencies := map[string]time.Duration{
+		"https://a.example.com": 80 * time.Millisecond,
+		"https://b.example.com": 40 * time.Millisecond,
+		"https://c.example.com": 10 * time.Millisecond,
+	}
+	assert.Equal(t, "https://c.example.com", pickGateway(gateway, latencies, map[string]bool{}))
+	assert.Equal(t, "https://b.example.com", pickGateway(gateway, latencies, map[string]bool{"https://c.example.com": true}))
+
+	// gateways failing to answer are used only if nothing else is spare
+	delete(latencies, "https://a.example.com")
+	assert.Equal(t, "https://a.example.com", pickGateway(gateway, latencies, map[string]bool{
+		"https://b.example.com": true,
+		"https://c.example.com": true,
+	}))
+	assert.Equal(t, "", pickGateway(gateway, latencies, map[string]bool{
+		"https://a.example.com": true,
+		"https://b.example.com": true,
+		"https://c.example.com": true,
+	}))
+}
+
+func TestReserveGateway(t *testing.T) {
+	gateway := &api.ConnectionGateway{
+		Hostname: "https://a.example.com",
+		Candidates: []api.ConnectionGatewayCandidate{
+			{Hostname: "https://a.example.com"},
+			{Hostname: "https://b.example.com"},
+			{Hostname: "https://c.example.com"},
+		},
+	}
+	c := &Connection{}
+	c.tunnels = []*tunnel{{}, {}, {}}
+
+	// tunnels starting at once pick distinct gateways
+	var wg sync.WaitGroup
+	picked := make([]string, len(c.tunnels))
+	for i, tl := range c.tunnels {
+		wg.Add(1)
+		go func(i int, tl *tunnel) {
+			defer wg.Done()
+			picked[i] = c.reserveGateway(tl, gateway, nil)
+		}(i, tl)
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}, picked)
+}
+
+func TestMeasureLatencies(t *testing.T) {
+	var pinged string
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pinged = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+
+	c := &Connection{upstreamClient: gateway.Client()}
+	latencies := c.measureLatencies(context.Background(), &api.ConnectionGateway{
+		Hostname:   gateway.URL,
+		Candidates: []api.ConnectionGatewayCandidate{{Hostname: gateway.URL}, {Hostname: broken.URL}},
+	})
+
+	assert.Equal(t, api.GatewayPingPath, pinged)
+	require.Len(t, latencies, 1)
+	assert.Greater(t, latencies[gateway.URL], time.Duration(0))
+}
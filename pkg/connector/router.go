@@ -0,0 +1,139 @@
+package connector
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+)
+
+// route is a routing rule with its downstream proxy
+type route struct {
+	config.ConnectorRoute
+	proxy *httputil.ReverseProxy
+}
+
+// router sends requests to downstream of the first matching route, or the
+// default downstream if none matches.
+type router struct {
+	routes   []route
+	fallback *httputil.ReverseProxy
+}
+
+func newRouter(cfg *config.ConnectorConfig, logger logr.Logger) (*router, error) {
+	r := &router{}
+
+	for _, rule := range cfg.Routes {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		downstreamURL, err := url.Parse(rule.Downstream)
+		if err != nil {
+			return nil, err
+		}
+		r.routes = append(r.routes, route{
+			ConnectorRoute: rule,
+			proxy:          newDownstreamProxy(downstreamURL, cfg.PreserveHost, logger),
+		})
+	}
+
+	if cfg.DownstreamURL != "" {
+		downstreamURL, err := url.Parse(cfg.DownstreamURL)
+		if err != nil {
+			return nil, err
+		}
+		r.fallback = newDownstreamProxy(downstreamURL, cfg.PreserveHost, logger)
+	}
+
+	return r, nil
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := originalHost(req)
+
+	for _, route := range r.routes {
+		if !matchHost(route.Host, host) || !matchPath(route.PathPrefix, req.URL.Path) {
+			continue
+		}
+		if route.StripPrefix {
+			stripPrefix(req, route.PathPrefix)
+		}
+		route.proxy.ServeHTTP(w, req)
+		return
+	}
+
+	if r.fallback == nil {
+		http.Error(w, "No route to downstream", http.StatusNotFound)
+		return
+	}
+	r.fallback.ServeHTTP(w, req)
+}
+
+func newDownstreamProxy(downstreamURL *url.URL, preserveHost bool, logger logr.Logger) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(downstreamURL)
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		logger.V(4).Info("proxying request", "method", req.Method, "path", req.URL.Path, "downstream", downstreamURL.String())
+		req.Host = downstreamURL.Host
+		if preserveHost {
+			req.Host = originalHost(req)
+		}
+		director(req)
+	}
+	clientDownstream := utilhttp.DefaultInsecureClient // TODO
+	proxy.Transport = clientDownstream.Transport       // TODO
+
+	return proxy
+}
+
+// originalHost returns host the request was routed by. Gateway sets it to
+// X-Forwarded-Host.
+func originalHost(req *http.Request) string {
+	if host := req.Header.Get("X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return req.Host
+}
+
+func matchHost(pattern, host string) bool {
+	if pattern == "" {
+		return true
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return strings.EqualFold(pattern, host)
+}
+
+// matchPath matches path prefix on segment boundaries, so '/api' matches
+// '/api' and '/api/users' but not '/apis'.
+func matchPath(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func stripPrefix(req *http.Request, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, prefix)
+		if req.URL.RawPath == "" {
+			req.URL.RawPath = "/"
+		}
+	}
+}
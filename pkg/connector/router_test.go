@@ -0,0 +1,61 @@
+package connector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+func TestRouter(t *testing.T) {
+	downstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name+" "+r.Host+" "+r.URL.Path)
+		}))
+	}
+	frontend, api, admin := downstream("frontend"), downstream("api"), downstream("admin")
+	defer frontend.Close()
+	defer api.Close()
+	defer admin.Close()
+
+	for _, tt := range []struct {
+		name         string
+		preserveHost bool
+		host         string
+		path         string
+		expected     string
+	}{
+		{name: "fallback", host: "app.apps.faros.sh", path: "/index.html", expected: "frontend " + frontend.Listener.Addr().String() + " /index.html"},
+		{name: "stripped prefix", host: "app.apps.faros.sh", path: "/api/users", expected: "api " + api.Listener.Addr().String() + " /users"},
+		{name: "prefix only", host: "app.apps.faros.sh", path: "/api", expected: "api " + api.Listener.Addr().String() + " /"},
+		{name: "segment boundary", host: "app.apps.faros.sh", path: "/apis", expected: "frontend " + frontend.Listener.Addr().String() + " /apis"},
+		{name: "host before path", host: "admin.apps.faros.sh", path: "/api/users", expected: "admin " + admin.Listener.Addr().String() + " /api/users"},
+		{name: "preserved host", preserveHost: true, host: "tenant.apps.faros.sh", path: "/", expected: "frontend tenant.apps.faros.sh /"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRouter(&config.ConnectorConfig{
+				DownstreamURL: frontend.URL,
+				PreserveHost:  tt.preserveHost,
+				Routes: []config.ConnectorRoute{
+					{Host: "admin.apps.faros.sh", Downstream: admin.URL},
+					{PathPrefix: "/api/", Downstream: api.URL, StripPrefix: true},
+				},
+			}, logr.Discard())
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://gateway"+tt.path, nil)
+			req.Header.Set("X-Forwarded-Host", tt.host)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expected, w.Body.String())
+		})
+	}
+}
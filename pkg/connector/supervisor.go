@@ -0,0 +1,305 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/client"
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+// tunnelsReloadInterval is how often tunnels file is checked for changes
+const tunnelsReloadInterval = 5 * time.Second
+
+// Supervisor runs one tunnel per entry of the tunnels file and reconciles
+// them when the file changes. Tunnels which did not change keep running.
+type Supervisor struct {
+	base *config.ConnectorConfig
+	path string
+
+	// run runs a single tunnel until context is done
+	run func(ctx context.Context, cfg *config.ConnectorConfig) error
+	// resolve looks up connection by name using user credentials
+	resolve func(ctx context.Context, controllerURL, kubeconfig, name string) (*api.Connection, error)
+
+	mu      sync.Mutex
+	data    []byte
+	tunnels map[string]*supervisedTunnel
+}
+
+// tunnelSpec is everything a tunnel depends on, tunnels are restarted when it changes
+type tunnelSpec struct {
+	config.ConnectorTunnel
+	ControllerURL string
+	Kubeconfig    string
+}
+
+type supervisedTunnel struct {
+	spec   tunnelSpec
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSupervisor returns supervisor of tunnels listed in base.TunnelsFile. Base
+// config provides defaults for all tunnels.
+func NewSupervisor(base *config.ConnectorConfig) *Supervisor {
+	return &Supervisor{
+		base:    base,
+		path:    base.TunnelsFile,
+		run:     runTunnel,
+		resolve: resolveConnection,
+		tunnels: map[string]*supervisedTunnel{},
+	}
+}
+
+// Run blocks until the context is cancelled, reloading tunnels file periodically.
+func (s *Supervisor) Run(ctx context.Context) {
+	ticker := time.NewTicker(tunnelsReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		s.reload(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.stopAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reload reconciles tunnels if tunnels file content changed. Tunnels keep
+// running if the file can't be read or parsed, so a bad edit does not take
+// them down.
+func (s *Supervisor) reload(ctx context.Context) {
+	logger := klog.FromContext(ctx).WithValues("file", s.path)
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		logger.Error(err, "failed to read tunnels file")
+		return
+	}
+
+	s.mu.Lock()
+	unchanged := s.data != nil && bytes.Equal(s.data, data)
+	s.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	tunnels, err := config.ParseTunnels(data)
+	if err != nil {
+		logger.Error(err, "failed to parse tunnels file")
+		return
+	}
+
+	s.reconcile(ctx, tunnels)
+
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+}
+
+func (s *Supervisor) reconcile(ctx context.Context, tunnels *config.ConnectorTunnels) {
+	logger := klog.FromContext(ctx)
+
+	controllerURL := s.base.ControllerURL
+	if tunnels.ControllerURL != "" {
+		controllerURL = tunnels.ControllerURL
+	}
+
+	desired := map[string]tunnelSpec{}
+	for _, tunnel := range tunnels.Tunnels {
+		spec := tunnelSpec{
+			ConnectorTunnel: tunnel,
+			ControllerURL:   controllerURL,
+		}
+		// kubeconfig is only used to look up connections by name
+		if tunnel.ConnectionID == "" {
+			spec.Kubeconfig = tunnels.Kubeconfig
+		}
+		desired[tunnel.Key()] = spec
+	}
+
+	s.mu.Lock()
+	var stopping []*supervisedTunnel
+	for key, running := range s.tunnels {
+		spec, ok := desired[key]
+		if ok && reflect.DeepEqual(spec, running.spec) {
+			continue
+		}
+		if ok {
+			logger.Info("restarting changed tunnel", "tunnel", key)
+		} else {
+			logger.Info("stopping removed tunnel", "tunnel", key)
+		}
+		running.cancel()
+		stopping = append(stopping, running)
+		delete(s.tunnels, key)
+	}
+	s.mu.Unlock()
+
+	// tunnels drain in-flight requests, so wait without holding the lock
+	waitStopped(stopping)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, spec := range desired {
+		if _, ok := s.tunnels[key]; ok {
+			continue
+		}
+		logger.Info("starting tunnel", "tunnel", key)
+
+		ctx, cancel := context.WithCancel(klog.NewContext(ctx, logger.WithValues("tunnel", key)))
+		tunnel := &supervisedTunnel{
+			spec:   spec,
+			cancel: cancel,
+			done:   make(chan struct{}),
+		}
+		s.tunnels[key] = tunnel
+
+		go func() {
+			defer close(tunnel.done)
+			s.supervise(ctx, tunnel.spec)
+		}()
+	}
+}
+
+// supervise runs the tunnel with its own backoff until context is done
+func (s *Supervisor) supervise(ctx context.Context, spec tunnelSpec) {
+	backoffMgr := wait.NewExponentialBackoffManager(time.Second, time.Minute, time.Minute, 2.0, 1.0, &clock.RealClock{})
+	logger := klog.FromContext(ctx)
+
+	wait.BackoffUntil(func() {
+		cfg, err := s.tunnelConfig(ctx, spec)
+		if err != nil {
+			logger.Error(err, "failed to configure tunnel")
+			return
+		}
+		err = s.run(ctx, cfg)
+		if err != nil {
+			logger.Error(err, "failed to run tunnel")
+		}
+	}, backoffMgr, true, ctx.Done())
+}
+
+func (s *Supervisor) stopAll() {
+	s.mu.Lock()
+	var stopping []*supervisedTunnel
+	for key, tunnel := range s.tunnels {
+		tunnel.cancel()
+		stopping = append(stopping, tunnel)
+		delete(s.tunnels, key)
+	}
+	s.mu.Unlock()
+
+	waitStopped(stopping)
+}
+
+// waitStopped waits until cancelled tunnels stop
+func waitStopped(tunnels []*supervisedTunnel) {
+	for _, tunnel := range tunnels {
+		<-tunnel.done
+	}
+}
+
+// tunnelConfig builds connector config of the tunnel on top of base config
+func (s *Supervisor) tunnelConfig(ctx context.Context, spec tunnelSpec) (*config.ConnectorConfig, error) {
+	cfg := *s.base
+	cfg.TunnelsFile = ""
+	cfg.RoutesFile = ""
+	cfg.ControllerURL = spec.ControllerURL
+	cfg.DownstreamURL = spec.Downstream
+	cfg.Routes = spec.Routes
+	cfg.PreserveHost = spec.PreserveHost
+	if spec.Compression != nil {
+		cfg.TunnelCompression = *spec.Compression
+	}
+	cfg.ConnectionID = spec.ConnectionID
+
+	if spec.TLS.ServerCertFile != "" && spec.TLS.ServerKeyFile != "" {
+		cfg.TLSServerCertFile = spec.TLS.ServerCertFile
+		cfg.TLSServerKeyFile = spec.TLS.ServerKeyFile
+		cfg.TLSServerSkipVerify = false
+	}
+	if spec.TLS.ClientCertFile != "" && spec.TLS.ClientKeyFile != "" {
+		cfg.TLSClientCertFile = spec.TLS.ClientCertFile
+		cfg.TLSClientKeyFile = spec.TLS.ClientKeyFile
+		cfg.TLSClientSkipVerify = false
+	}
+
+	if spec.HasToken() {
+		token, err := spec.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = token
+	}
+
+	if cfg.ConnectionID == "" {
+		conn, err := s.resolve(ctx, spec.ControllerURL, spec.Kubeconfig, spec.Name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ConnectionID = conn.ID
+		if cfg.Token == "" {
+			cfg.Token = conn.Token
+		}
+	}
+
+	return &cfg, nil
+}
+
+func runTunnel(ctx context.Context, cfg *config.ConnectorConfig) error {
+	c, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	c.Run(ctx)
+
+	// tunnel was disconnected remotely, keep it stopped until it is reconfigured
+	<-ctx.Done()
+	return nil
+}
+
+func resolveConnection(ctx context.Context, controllerURL, kubeconfig, name string) (*api.Connection, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	host := controllerURL
+	if restConfig.Host != "" {
+		host = restConfig.Host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+
+	conns, err := client.NewClient(u, restConfig.BearerToken, nil).ListConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name == name {
+			return &conn, nil
+		}
+	}
+
+	return nil, fmt.Errorf("connection %q not found", name)
+}
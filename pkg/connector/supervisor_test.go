@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+type tunnelRecorder struct {
+	mu      sync.Mutex
+	started map[string]int
+	running map[string]*config.ConnectorConfig
+	ready   chan struct{}
+}
+
+func (r *tunnelRecorder) run(ctx context.Context, cfg *config.ConnectorConfig) error {
+	r.mu.Lock()
+	r.started[cfg.ConnectionID]++
+	r.running[cfg.ConnectionID] = cfg
+	r.mu.Unlock()
+	r.ready <- struct{}{}
+
+	<-ctx.Done()
+
+	r.mu.Lock()
+	delete(r.running, cfg.ConnectionID)
+	r.mu.Unlock()
+	return nil
+}
+
+func TestSupervisorReconcile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "tunnels.yaml")
+	write := func(content string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+
+	recorder := &tunnelRecorder{
+		started: map[string]int{},
+		running: map[string]*config.ConnectorConfig{},
+		ready:   make(chan struct{}, 10),
+	}
+
+	s := NewSupervisor(&config.ConnectorConfig{TunnelsFile: path, ControllerURL: "https://ingress.faros.sh"})
+	s.run = recorder.run
+	s.resolve = func(ctx context.Context, controllerURL, kubeconfig, name string) (*api.Connection, error) {
+		return &api.Connection{ID: name + "-id", Token: name + "-token"}, nil
+	}
+
+	wait := func(n int) {
+		for i := 0; i < n; i++ {
+			<-recorder.ready
+		}
+	}
+
+	write(`
+kubeconfig: /home/ci/.kube/config
+tunnels:
+- connectionId: frontend
+  token: secret
+  downstream: http://localhost:3000
+- name: api
+  downstream: http://localhost:8080
+  routes:
+  - pathPrefix: /metrics
+    downstream: http://localhost:9090
+`)
+	s.reload(ctx)
+	wait(2)
+
+	recorder.mu.Lock()
+	assert.Equal(t, map[string]int{"frontend": 1, "api-id": 1}, recorder.started)
+	assert.Equal(t, "secret", recorder.running["frontend"].Token)
+	assert.Equal(t, "api-token", recorder.running["api-id"].Token)
+	assert.Equal(t, "http://localhost:9090", recorder.running["api-id"].Routes[0].Downstream)
+	recorder.mu.Unlock()
+
+	// unchanged file does nothing
+	s.reload(ctx)
+
+	// invalid file keeps tunnels running
+	write(`tunnels: [{downstream: http://localhost:3000}]`)
+	s.reload(ctx)
+
+	// changed tunnel restarts, removed one stops, added one starts, others keep running
+	write(`
+kubeconfig: /home/ci/.kube/config
+tunnels:
+- connectionId: frontend
+  token: secret
+  downstream: http://localhost:3000
+- name: api
+  downstream: http://localhost:8081
+- connectionId: docs
+  token: secret
+  downstream: http://localhost:4000
+`)
+	s.reload(ctx)
+	wait(2)
+
+	recorder.mu.Lock()
+	assert.Equal(t, map[string]int{"frontend": 1, "api-id": 2, "docs": 1}, recorder.started)
+	assert.Equal(t, "http://localhost:8081", recorder.running["api-id"].DownstreamURL)
+	recorder.mu.Unlock()
+
+	write(`
+tunnels:
+- connectionId: docs
+  token: secret
+  downstream: http://localhost:4000
+`)
+	s.reload(ctx)
+
+	recorder.mu.Lock()
+	assert.Len(t, recorder.running, 1)
+	assert.Contains(t, recorder.running, "docs")
+	recorder.mu.Unlock()
+
+	s.stopAll()
+	recorder.mu.Lock()
+	assert.Empty(t, recorder.running)
+	recorder.mu.Unlock()
+}
@@ -111,7 +111,7 @@ func (c *Client) startTunneler(ctx context.Context) error {
 	logger = logger.WithValues("to", c.downstreamURL).WithValues("from", c.upstreamURL)
 	logger.V(2).Info("connecting to destination URL")
 
-	l, err := h2rev2.NewListener(c.upstreamClient, c.upstreamURL, c.clientID)
+	l, err := h2rev2.NewListener(c.upstreamClient, c.upstreamURL, c.clientID, h2rev2.ListenerOptions{Liveness: h2rev2.DefaultLiveness})
 	if err != nil {
 		panic(err)
 	}
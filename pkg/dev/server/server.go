@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/faroshq/faros-ingress/pkg/dev/revdial"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
@@ -25,16 +25,30 @@ type Service struct {
 	certFile string
 }
 
-func New(addr, certFile, keyFile string) (*Service, error) {
+// apiPrefix is the path dev clients connecting through the API prefix use
+const apiPrefix = "/api/v1alpha1/proxy"
+
+// New returns dev reverse proxy server forwarding every request to the dev
+// client connected with clientID
+func New(addr, certFile, keyFile, clientID string) (*Service, error) {
 	s := &Service{
 		addr:     addr,
 		keyFile:  keyFile,
 		certFile: certFile,
 	}
 
-	revPool := revdial.NewReversePool()
+	// dev server is single tenant, requests are proxied with the full path
+	revPool := h2rev2.NewReversePool(h2rev2.PoolOptions{
+		Authenticator: h2rev2.AllowAll,
+		Paths:         h2rev2.Paths{RevDial: h2rev2.DefaultPaths.RevDial},
+		Liveness:      h2rev2.DefaultLiveness,
+	})
 	mux := http.NewServeMux()
-	mux.Handle("/", revPool)
+	mux.Handle("/"+h2rev2.DefaultPaths.RevDial, revPool)
+	mux.Handle(apiPrefix+"/"+h2rev2.DefaultPaths.RevDial, revPool)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		revPool.Proxy(w, r, clientID, r.URL.Path)
+	})
 
 	server := http.Server{
 		Addr:    addr,
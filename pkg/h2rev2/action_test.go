@@ -0,0 +1,97 @@
+package h2rev2
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+)
+
+func TestAction(t *testing.T) {
+	type actionResult struct {
+		id     string
+		action api.ConnectionAction
+	}
+	results := make(chan actionResult, 1)
+	pool := NewReversePool(PoolOptions{
+		Hooks: Hooks{
+			OnActionResult: func(id string, action api.ConnectionAction) {
+				results <- actionResult{id: id, action: action}
+			},
+		},
+	})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	result := func(t *testing.T, id string) api.ConnectionAction {
+		select {
+		case result := <-results:
+			assert.Equal(t, id, result.id)
+			return result.action
+		case <-time.After(5 * time.Second):
+			t.Fatal("action not acknowledged")
+		}
+		return api.ConnectionAction{}
+	}
+
+	t.Run("should return result acknowledged by the listener", func(t *testing.T) {
+		l, err := NewListener(gateway.Client(), gateway.URL, "handled", ListenerOptions{Transport: TransportHTTP2})
+		require.NoError(t, err)
+		defer l.Close()
+		received := make(chan api.ConnectionAction, 1)
+		l.OnAction(func(action api.ConnectionAction) {
+			received <- action
+			action.Status = api.ConnectionActionSucceeded
+			action.Diagnostics = map[string]string{"listener": "ok"}
+			require.NoError(t, l.Acknowledge(action))
+		})
+		require.Eventually(t, func() bool { return pool.Replicas("handled") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, pool.SendAction("handled", api.ConnectionAction{
+			ID:         "action",
+			Type:       api.ConnectionActionReconnect,
+			GatewayURL: "https://other.faros.sh",
+			Status:     api.ConnectionActionPending,
+		}))
+		action := <-received
+		assert.Equal(t, "https://other.faros.sh", action.GatewayURL)
+
+		action = result(t, "handled")
+		assert.Equal(t, "action", action.ID)
+		assert.Equal(t, api.ConnectionActionSucceeded, action.Status)
+		assert.Equal(t, map[string]string{"listener": "ok"}, action.Diagnostics)
+	})
+
+	t.Run("should fail action of listener without handler", func(t *testing.T) {
+		l, err := NewListener(gateway.Client(), gateway.URL, "unhandled", ListenerOptions{Transport: TransportHTTP2})
+		require.NoError(t, err)
+		defer l.Close()
+		require.Eventually(t, func() bool { return pool.Replicas("unhandled") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, pool.SendAction("unhandled", api.ConnectionAction{
+			ID:     "action",
+			Type:   api.ConnectionActionDiagnostics,
+			Status: api.ConnectionActionPending,
+		}))
+
+		action := result(t, "unhandled")
+		assert.Equal(t, api.ConnectionActionFailed, action.Status)
+		assert.Equal(t, "connector does not support actions", action.Message)
+	})
+
+	t.Run("should not send action without replicas", func(t *testing.T) {
+		require.NoError(t, pool.SendAction("missing", api.ConnectionAction{ID: "action"}))
+		select {
+		case result := <-results:
+			t.Fatalf("unexpected result %v", result.action)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package h2rev2
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+)
+
+// ErrUnauthorized is returned by authenticators rejecting the request
+var ErrUnauthorized = errors.New("revdial: unauthorized")
+
+// Authenticator decides who may attach listeners to the pool and whose
+// requests are proxied to them
+type Authenticator interface {
+	// AuthenticateListener returns error if the request may not create
+	// reverse connections for the id
+	AuthenticateListener(r *http.Request, id string) error
+	// AuthenticateProxy returns error if the request may not be proxied to
+	// listeners of the id
+	AuthenticateProxy(r *http.Request, id string) error
+}
+
+// AllowAll authenticates every request. Use it only for pools not reachable
+// by untrusted clients.
+var AllowAll Authenticator = allowAll{}
+
+type allowAll struct{}
+
+func (allowAll) AuthenticateListener(r *http.Request, id string) error { return nil }
+func (allowAll) AuthenticateProxy(r *http.Request, id string) error    { return nil }
+
+// Hooks are called on lifecycle events of listeners attached to the pool.
+// Every hook is optional.
+type Hooks struct {
+	// OnConnect is called once the control connection of the listener
+	// replica is registered
+	OnConnect func(id, replicaID string)
+	// OnDisconnect is called once the control connection of the listener
+	// replica is closed and the replica removed
+	OnDisconnect func(id, replicaID string)
+	// OnAgent is called with agent information reported by the listener
+	OnAgent func(id, replicaID string, agent api.ConnectionAgent)
+	// OnActionResult is called with action results acknowledged by the listener
+	OnActionResult func(id string, action api.ConnectionAction)
+}
+
+// Paths are the path elements of the pool endpoints. Requests for
+// [base]/[RevDial]?id=[id] create reverse connections, requests for
+// [base]/[Proxy]/[id]/[path] are proxied to [path] of the listener.
+type Paths struct {
+	RevDial string
+	// Proxy is empty to serve reverse connections only, requests are then
+	// proxied with ReversePool.Proxy
+	Proxy string
+}
+
+// DefaultPaths are the paths listeners connect to by default
+var DefaultPaths = Paths{
+	RevDial: pathRevDial,
+	Proxy:   pathRevProxy,
+}
@@ -0,0 +1,177 @@
+package h2rev2
+
+import (
+	"sync"
+	"time"
+)
+
+// Strategy is the way requests are spread between replicas of a connection
+type Strategy string
+
+const (
+	// StrategyRoundRobin sends requests to replicas in turns
+	StrategyRoundRobin Strategy = "round-robin"
+	// StrategyLeastInFlight sends requests to replica with the fewest requests in flight
+	StrategyLeastInFlight Strategy = "least-in-flight"
+)
+
+var (
+	// ejectAfterFailures is the number of consecutive failed requests after
+	// which replica stops receiving requests
+	ejectAfterFailures = 3
+	// ejectDuration is how long ejected replica stops receiving requests
+	ejectDuration = 30 * time.Second
+)
+
+// replica is a single connector attached to the connection
+type replica struct {
+	id     string
+	dialer *Dialer
+
+	// guarded by replicaSet.mu
+	inFlight     int
+	failures     int
+	ejectedUntil time.Time
+	// unhealthy is set when connector reports its downstream is not healthy
+	unhealthy bool
+	// draining is set when connector finishes requests before disconnecting
+	draining bool
+}
+
+// replicaSet is a set of connectors attached to the same connection
+type replicaSet struct {
+	mu       sync.Mutex
+	replicas []*replica
+	next     int
+	// disconnected is when a replica was removed last
+	disconnected time.Time
+}
+
+// get returns replica by id
+func (s *replicaSet) get(id string) *replica {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.replicas {
+		if r.id == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// add adds replica replacing existing one with the same id
+func (s *replicaSet) add(r *replica) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.replicas {
+		if existing.id == r.id {
+			s.replicas[i] = r
+			return
+		}
+	}
+	s.replicas = append(s.replicas, r)
+}
+
+// remove removes replica if it is still the one in the set
+func (s *replicaSet) remove(r *replica, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.replicas {
+		if existing == r {
+			s.replicas = append(s.replicas[:i], s.replicas[i+1:]...)
+			s.disconnected = now
+			return
+		}
+	}
+}
+
+// disconnectedWithin returns true if a replica was removed within the
+// duration before now
+func (s *replicaSet) disconnectedWithin(d time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.disconnected.IsZero() && now.Sub(s.disconnected) < d
+}
+
+// list returns replicas which are not closed
+func (s *replicaSet) list() []*replica {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]*replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if !isClosedChan(r.dialer.Done()) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// pick chooses replica for a request and counts it in flight. Ejected,
+// unhealthy and draining replicas are used only if there is nothing else.
+func (s *replicaSet) pick(strategy Strategy, now time.Time) *replica {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var healthy, degraded []*replica
+	for i := range s.replicas {
+		// rotate start so equal candidates take turns
+		r := s.replicas[(s.next+i)%len(s.replicas)]
+		switch {
+		case isClosedChan(r.dialer.Done()):
+		case r.unhealthy || r.draining || now.Before(r.ejectedUntil):
+			degraded = append(degraded, r)
+		default:
+			healthy = append(healthy, r)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = degraded
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	s.next++
+
+	picked := candidates[0]
+	if strategy == StrategyLeastInFlight {
+		for _, r := range candidates[1:] {
+			if r.inFlight < picked.inFlight {
+				picked = r
+			}
+		}
+	}
+	picked.inFlight++
+	return picked
+}
+
+// done records result of the request picked replica served
+func (s *replicaSet) done(r *replica, failed bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.inFlight--
+	if !failed {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.failures >= ejectAfterFailures {
+		r.failures = 0
+		r.ejectedUntil = now.Add(ejectDuration)
+	}
+}
+
+// setHealthy records downstream health reported by the replica
+func (s *replicaSet) setHealthy(r *replica, healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.unhealthy = !healthy
+}
+
+// setDraining records the replica stopped accepting new requests
+func (s *replicaSet) setDraining(r *replica) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.draining = true
+}
@@ -0,0 +1,104 @@
+package h2rev2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaSet(t *testing.T) {
+	newReplica := func(id string) *replica {
+		return &replica{id: id, dialer: &Dialer{donec: make(chan struct{})}}
+	}
+	newSet := func(ids ...string) *replicaSet {
+		s := &replicaSet{}
+		for _, id := range ids {
+			s.add(newReplica(id))
+		}
+		return s
+	}
+	now := time.Now()
+
+	t.Run("should pick replicas in turns", func(t *testing.T) {
+		s := newSet("a", "b", "c")
+		picked := []string{}
+		for i := 0; i < 6; i++ {
+			r := s.pick(StrategyRoundRobin, now)
+			picked = append(picked, r.id)
+			s.done(r, false, now)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, picked)
+	})
+
+	t.Run("should pick replica with least requests in flight", func(t *testing.T) {
+		s := newSet("a", "b")
+		first := s.pick(StrategyLeastInFlight, now)
+		second := s.pick(StrategyLeastInFlight, now)
+		assert.NotEqual(t, first.id, second.id)
+
+		s.done(second, false, now)
+		assert.Equal(t, second.id, s.pick(StrategyLeastInFlight, now).id)
+	})
+
+	t.Run("should eject failing replica", func(t *testing.T) {
+		s := newSet("a", "b")
+		a := s.get("a")
+		for i := 0; i < ejectAfterFailures; i++ {
+			a.inFlight++
+			s.done(a, true, now)
+		}
+
+		for i := 0; i < 4; i++ {
+			r := s.pick(StrategyRoundRobin, now)
+			assert.Equal(t, "b", r.id)
+			s.done(r, false, now)
+		}
+
+		// back after ejection
+		later := now.Add(ejectDuration)
+		ids := map[string]bool{}
+		for i := 0; i < 2; i++ {
+			r := s.pick(StrategyRoundRobin, later)
+			ids[r.id] = true
+			s.done(r, false, later)
+		}
+		assert.Len(t, ids, 2)
+	})
+
+	t.Run("should skip unhealthy and closed replicas unless nothing else left", func(t *testing.T) {
+		s := newSet("a", "b")
+		s.setHealthy(s.get("a"), false)
+		close(s.get("b").dialer.donec)
+
+		r := s.pick(StrategyRoundRobin, now)
+		require.NotNil(t, r)
+		assert.Equal(t, "a", r.id)
+
+		s.remove(s.get("a"), now)
+		assert.Nil(t, s.pick(StrategyRoundRobin, now))
+		assert.Empty(t, s.list())
+		assert.True(t, s.disconnectedWithin(time.Second, now))
+		assert.False(t, s.disconnectedWithin(time.Second, now.Add(time.Second)))
+	})
+
+	t.Run("should skip draining replicas", func(t *testing.T) {
+		s := newSet("a", "b")
+		s.setDraining(s.get("a"))
+
+		for i := 0; i < 2; i++ {
+			r := s.pick(StrategyRoundRobin, now)
+			assert.Equal(t, "b", r.id)
+			s.done(r, false, now)
+		}
+	})
+
+	t.Run("should replace replica with same id", func(t *testing.T) {
+		s := newSet("a")
+		replacement := newReplica("a")
+		s.add(replacement)
+		assert.Same(t, replacement, s.get("a"))
+		assert.Len(t, s.list(), 1)
+	})
+}
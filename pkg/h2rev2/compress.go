@@ -0,0 +1,322 @@
+package h2rev2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/klog/v2"
+)
+
+// Responses proxied over the tunnel may be compressed per request. Dialer
+// advertises it decodes zstd with HeaderTunnelEncoding on the request, and
+// the listener marks compressed responses with the same header. Peers not
+// knowing the header ignore it, so responses stay uncompressed.
+
+const (
+	// HeaderTunnelEncoding negotiates compression of the response body over
+	// the tunnel. It is removed before the request or response leaves it.
+	HeaderTunnelEncoding = "X-Faros-Tunnel-Encoding"
+	// tunnelEncodingZstd is the only supported encoding
+	tunnelEncodingZstd = "zstd"
+	// compressMinLength is the smallest response of known length worth compressing
+	compressMinLength = 512
+)
+
+// incompressibleTypes are content types already compressed, or with
+// type prefix ending with "/" types of the whole family
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/vnd.rar",
+	"application/pdf",
+	"application/wasm",
+	"application/octet-stream",
+}
+
+// compressibleImages are image types stored as text
+var compressibleImages = map[string]bool{
+	"image/svg+xml": true,
+	"image/x-icon":  true,
+	"image/bmp":     true,
+}
+
+// compressible returns false for content types already compressed
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if compressibleImages[mediaType] {
+		return true
+	}
+	for _, t := range incompressibleTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return false
+		}
+	}
+	return true
+}
+
+// CompressionStats counts bytes of responses compressed over the tunnel
+type CompressionStats struct {
+	uncompressed atomic.Int64
+	compressed   atomic.Int64
+}
+
+// Bytes returns the size of compressed responses before and after compression
+func (s *CompressionStats) Bytes() (uncompressed, compressed int64) {
+	return s.uncompressed.Load(), s.compressed.Load()
+}
+
+// Ratio returns how many times compressed responses shrank, 0 if none was compressed
+func (s *CompressionStats) Ratio() float64 {
+	uncompressed, compressed := s.Bytes()
+	if compressed == 0 {
+		return 0
+	}
+	return float64(uncompressed) / float64(compressed)
+}
+
+var zstdEncoders = sync.Pool{
+	New: func() interface{} {
+		// options are valid, error is not possible
+		e, _ := zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstd.SpeedFastest),
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithWindowSize(1<<20),
+		)
+		return e
+	},
+}
+
+// CompressHandler compresses responses of the handler served over the tunnel
+// when the dialer supports it, unless the content is already compressed.
+// Stats are optional.
+func CompressHandler(h http.Handler, stats *CompressionStats) http.Handler {
+	if stats == nil {
+		stats = &CompressionStats{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted := r.Header.Get(HeaderTunnelEncoding) == tunnelEncodingZstd
+		r.Header.Del(HeaderTunnelEncoding)
+		if !accepted || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, stats: stats}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+var (
+	_ http.Flusher  = (*compressResponseWriter)(nil)
+	_ http.Hijacker = (*compressResponseWriter)(nil)
+)
+
+// compressResponseWriter decides to compress once the response header is
+// written
+type compressResponseWriter struct {
+	http.ResponseWriter
+	stats *CompressionStats
+
+	wroteHeader bool
+	// sniff delays the header until the first write, when content type has
+	// to be detected from the uncompressed body rather than by the server
+	// from the compressed one
+	sniff   bool
+	code    int
+	encoder *zstd.Encoder
+	counter *countingWriter
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	// informational responses precede the final one
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	// only the listener marks compressed responses
+	header.Del(HeaderTunnelEncoding)
+	if header.Get("Content-Type") == "" && header.Get("Content-Encoding") == "" && bodyAllowed(code) {
+		w.sniff, w.code = true, code
+		return
+	}
+	w.writeHeader(code)
+}
+
+func (w *compressResponseWriter) writeHeader(code int) {
+	header := w.Header()
+	if shouldCompress(code, header) {
+		header.Set(HeaderTunnelEncoding, tunnelEncodingZstd)
+		header.Del("Content-Length")
+		w.counter = &countingWriter{w: w.ResponseWriter}
+		w.encoder = zstdEncoders.Get().(*zstd.Encoder)
+		w.encoder.Reset(w.counter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// flushHeader writes the header delayed for sniffing
+func (w *compressResponseWriter) flushHeader() {
+	if w.sniff {
+		w.sniff = false
+		w.writeHeader(w.code)
+	}
+}
+
+// bodyAllowed returns true for status codes of responses with body
+func bodyAllowed(code int) bool {
+	return code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// shouldCompress returns true for responses with body worth compressing
+func shouldCompress(code int, header http.Header) bool {
+	if !bodyAllowed(code) {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if !compressible(header.Get("Content-Type")) {
+		return false
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length < compressMinLength {
+		return false
+	}
+	return true
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.sniff {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+		w.flushHeader()
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	n, err := w.encoder.Write(b)
+	w.stats.uncompressed.Add(int64(n))
+	return n, err
+}
+
+// Flush sends data compressed so far, so streamed responses are not delayed
+func (w *compressResponseWriter) Flush() {
+	w.flushHeader()
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			klog.V(4).Infof("failed to flush compressed response: %v", err)
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets upgraded connections, which are never compressed, take over
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack not supported")
+	}
+	return h.Hijack()
+}
+
+// close finishes the compressed stream
+func (w *compressResponseWriter) close() {
+	w.flushHeader()
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Close(); err != nil {
+		klog.V(4).Infof("failed to finish compressed response: %v", err)
+	}
+	w.stats.compressed.Add(w.counter.n)
+	w.encoder.Reset(nil)
+	zstdEncoders.Put(w.encoder)
+	w.encoder = nil
+}
+
+// countingWriter counts bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// acceptCompression advertises the dialer decodes compressed responses,
+// replacing header sent by the visitor
+func acceptCompression(req *http.Request) {
+	req.Header.Set(HeaderTunnelEncoding, tunnelEncodingZstd)
+}
+
+// decompressResponse replaces body of response compressed by the listener
+// with decompressed one
+func decompressResponse(resp *http.Response) error {
+	encoding := resp.Header.Get(HeaderTunnelEncoding)
+	if encoding == "" {
+		return nil
+	}
+	resp.Header.Del(HeaderTunnelEncoding)
+	if encoding != tunnelEncodingZstd {
+		return fmt.Errorf("unsupported tunnel encoding %q", encoding)
+	}
+
+	decoder, err := zstd.NewReader(resp.Body, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	resp.Body = &decompressedBody{Decoder: decoder, body: resp.Body}
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// decompressedBody closes the decoder together with the compressed body
+type decompressedBody struct {
+	*zstd.Decoder
+	body io.ReadCloser
+}
+
+func (b *decompressedBody) Close() error {
+	b.Decoder.Close()
+	return b.body.Close()
+}
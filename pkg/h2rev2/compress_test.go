@@ -0,0 +1,148 @@
+package h2rev2
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedTunnel(t *testing.T) {
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: DefaultLiveness})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Liveness: DefaultLiveness})
+	require.NoError(t, err)
+	defer l.Close()
+
+	page := strings.Repeat(`{"hello":"world"},`, 1000)
+	var tunnelEncoding []string
+	stats := &CompressionStats{}
+	downstream := &http.Server{Handler: CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tunnelEncoding = r.Header.Values(HeaderTunnelEncoding)
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(page))
+		case "/png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(page))
+		case "/html":
+			// content type is left for the server to detect
+			w.Write([]byte("<html><body>" + page + "</body></html>"))
+		case "/early-hints":
+			w.Header().Set("Link", "</style.css>; rel=preload")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(page))
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: first\n\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}), stats)}
+	go downstream.Serve(l)
+	defer downstream.Close()
+
+	require.Eventually(t, func() bool { return pool.Replicas("token") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	get := func(path string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, gateway.URL+"/"+pathRevProxy+"/token"+path, nil)
+		require.NoError(t, err)
+		// visitors can not ask for compressed body
+		req.Header.Set(HeaderTunnelEncoding, "zstd")
+		req.Header.Set("Accept-Encoding", "identity")
+		resp, err := gateway.Client().Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("should compress text responses", func(t *testing.T) {
+		resp := get("/json")
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, page, string(body))
+		// header is for the tunnel only
+		assert.Empty(t, tunnelEncoding)
+		assert.Empty(t, resp.Header.Get(HeaderTunnelEncoding))
+		uncompressed, compressed := stats.Bytes()
+		assert.Equal(t, int64(len(page)), uncompressed)
+		assert.Less(t, compressed, uncompressed/10)
+		assert.Greater(t, stats.Ratio(), 10.0)
+	})
+
+	t.Run("should not compress compressed content types", func(t *testing.T) {
+		before, _ := stats.Bytes()
+		resp := get("/png")
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, page, string(body))
+		after, _ := stats.Bytes()
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("should detect content type from uncompressed body", func(t *testing.T) {
+		before, _ := stats.Bytes()
+		resp := get("/html")
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, "<html><body>"+page+"</body></html>", string(body))
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		after, _ := stats.Bytes()
+		assert.Greater(t, after, before)
+	})
+
+	t.Run("should keep final status after informational response", func(t *testing.T) {
+		resp := get("/early-hints")
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, page, string(body))
+	})
+
+	t.Run("should flush streamed responses", func(t *testing.T) {
+		resp := get("/stream")
+		defer resp.Body.Close()
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "data: first\n", line)
+	})
+}
+
+func TestCompressible(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"":                                  true,
+		"text/html; charset=utf-8":          true,
+		"application/json":                  true,
+		"image/svg+xml":                     true,
+		"image/png":                         false,
+		"video/mp4":                         false,
+		"application/zip":                   false,
+		"APPLICATION/GZIP":                  false,
+		"font/woff2":                        false,
+		"application/x-www-form-urlencoded": true,
+	} {
+		assert.Equal(t, expected, compressible(contentType), contentType)
+	}
+}
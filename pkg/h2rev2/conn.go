@@ -17,10 +17,13 @@ type conn struct {
 	wc   io.WriteCloser
 
 	rx chan []byte // channel to read asynchronous
+	// pending is the part of received data not fitting the last Read, guarded by rdMu
+	pending []byte
 
-	once  sync.Once   // Protects closing the connection
-	timer *time.Timer // delays closing the connection too fast (give time to the writer to flush)
-	done  chan struct{}
+	once    sync.Once   // Protects closing the connection
+	timerMu sync.Mutex  // guards timer
+	timer   *time.Timer // delays closing the connection too fast (give time to the writer to flush)
+	done    chan struct{}
 
 	readDeadline  *connDeadline
 	writeDeadline *connDeadline
@@ -149,7 +152,9 @@ func (c *conn) Write(data []byte) (int, error) {
 		}
 		n, err = c.wc.Write(data)
 		if err == nil {
+			c.timerMu.Lock()
 			c.timer = time.NewTimer(time.Second)
+			c.timerMu.Unlock()
 		}
 	}()
 
@@ -169,6 +174,12 @@ func (c *conn) Read(data []byte) (int, error) {
 	c.rdMu.Lock()
 	defer c.rdMu.Unlock()
 
+	if len(c.pending) > 0 {
+		n := copy(data, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
 	select {
 	case <-c.done:
 		// TODO: TestConn/BasicIO the other end stops writing and the http connection is closed
@@ -180,8 +191,9 @@ func (c *conn) Read(data []byte) (int, error) {
 		if !ok {
 			return 0, io.EOF
 		}
-		copy(data, d)
-		return len(d), nil
+		n := copy(data, d)
+		c.pending = d[n:]
+		return n, nil
 	}
 }
 
@@ -192,8 +204,11 @@ func (c *conn) Close() error {
 }
 
 func (c *conn) close() {
-	if c.timer != nil {
-		<-c.timer.C
+	c.timerMu.Lock()
+	timer := c.timer
+	c.timerMu.Unlock()
+	if timer != nil {
+		<-timer.C
 	}
 	c.rc.Close()
 	c.wc.Close()
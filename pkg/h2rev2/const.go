@@ -1,7 +1,7 @@
 package h2rev2
 
 // define the constants used to build the URL
-// The listener connects to an user with path [host:port/base]/revdial?id=[id]
+// The listener connects to an user with path [host:port/base]/revdial?id=[id]&replica=[replica]
 // The dialer listens on the urls:
 // [host:port/base]/revdial for the reverse connections
 // [host:port/base]/proxy/[id]/[path] for the reverse proxied to [path]
@@ -9,4 +9,23 @@ const (
 	pathRevDial  = "revdial"
 	pathRevProxy = "proxy"
 	urlParamKey  = "id"
+	// urlParamReplica tells apart connectors attached to the same id
+	urlParamReplica = "replica"
+	// urlParamVersion is the highest protocol version the listener supports
+	urlParamVersion = "version"
+	// urlParamMux marks the reverse connection carrying multiplexed streams
+	urlParamMux = "mux"
+)
+
+// protocol versions negotiated in the control handshake. Dialer answers the
+// listener supporting versionMux with "hello", older peers keep versionLegacy.
+const (
+	// versionLegacy dials a reverse connection for every new connection
+	versionLegacy = 1
+	// versionMux opens new connections as streams over a single reverse connection
+	versionMux = 2
+	// versionLiveness pings the peer and closes the tunnel when pongs stop
+	versionLiveness = 3
+	// versionLatest is the highest version this package supports
+	versionLatest = versionLiveness
 )
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
 )
 
 // The Dialer can create new connections back to the origin.
@@ -28,6 +30,22 @@ type Dialer struct {
 	donec        chan struct{}
 	closeOnce    sync.Once
 	revClient    *http.Client
+	revOnce      sync.Once // proxied requests create the client concurrently
+	// onAgent is called with agent information reported by the listener
+	onAgent func(agent api.ConnectionAgent)
+	// onAction is called with action results acknowledged by the listener
+	onAction func(action api.ConnectionAction)
+	// onDraining is called once the listener stops accepting connections
+	onDraining func()
+
+	writeMu sync.Mutex // serializes control messages
+
+	muxMu sync.Mutex
+	// mux is the session new connections are opened over, if the listener
+	// supports it
+	mux session
+
+	pinger *pinger
 }
 
 // NewDialer returns the side of the connection which will initiate
@@ -41,6 +59,7 @@ func NewDialer(id string, conn net.Conn) *Dialer {
 		pickupFailed: make(chan error),
 		incomingConn: make(chan net.Conn),
 	}
+	d.pinger = newPinger(d.sendMessage)
 	go d.serve()
 	return d
 }
@@ -68,6 +87,22 @@ func (d *Dialer) serve() error {
 				return
 			}
 			switch msg.Command {
+			case "ping":
+				go d.sendMessage(controlMsg{Command: "pong", Seq: msg.Seq})
+			case "pong":
+				d.pinger.pong(msg.Seq)
+			case "agent-info":
+				if msg.Agent != nil && d.onAgent != nil {
+					go d.onAgent(*msg.Agent)
+				}
+			case "action-ack":
+				if msg.Action != nil && d.onAction != nil {
+					go d.onAction(*msg.Action)
+				}
+			case "draining":
+				if d.onDraining != nil {
+					go d.onDraining()
+				}
 			case "pickup-failed":
 				err := fmt.Errorf("revdial listener failed to pick up connection: %v", msg.Err)
 				select {
@@ -94,6 +129,9 @@ func (d *Dialer) serve() error {
 }
 
 func (d *Dialer) sendMessage(m controlMsg) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
 	j, _ := json.Marshal(m)
 	d.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 	j = append(j, '\n')
@@ -102,6 +140,26 @@ func (d *Dialer) sendMessage(m controlMsg) error {
 	return err
 }
 
+// GoAway asks the listener to re-attach to another dialer, as this one is
+// shutting down
+func (d *Dialer) GoAway() error {
+	return d.sendMessage(controlMsg{Command: "go-away"})
+}
+
+// runPinger pings the listener, closing the dialer once it stops answering
+func (d *Dialer) runPinger(liveness Liveness) {
+	d.pinger.run(liveness, d.donec, func() {
+		klog.V(2).Infof("revdial.Dialer %s missed %d pongs, closing dead tunnel", d.id, liveness.MaxMissed)
+		d.Close()
+	})
+}
+
+// RTT returns round trip time to the listener measured by the last answered
+// ping, or 0 if the listener does not answer pings.
+func (d *Dialer) RTT() time.Duration {
+	return d.pinger.lastRTT()
+}
+
 // Done returns a channel which is closed when d is closed (either by
 // this process on purpose, by a local error, or close or error from
 // the peer).
@@ -115,12 +173,35 @@ func (d *Dialer) Close() error {
 
 func (d *Dialer) close() {
 	d.conn.Close()
+	if s := d.muxSession(); s != nil {
+		s.Close()
+	}
 	close(d.donec)
 }
 
+// setMux attaches the session, replacing the previous one
+func (d *Dialer) setMux(s session) {
+	d.muxMu.Lock()
+	defer d.muxMu.Unlock()
+	if d.mux != nil {
+		d.mux.Close()
+	}
+	d.mux = s
+}
+
+// muxSession returns the session if it is attached and open
+func (d *Dialer) muxSession() session {
+	d.muxMu.Lock()
+	defer d.muxMu.Unlock()
+	if d.mux == nil || isClosedChan(d.mux.Done()) {
+		return nil
+	}
+	return d.mux
+}
+
 // reverseClient caches the reverse http client
 func (d *Dialer) reverseClient() *http.Client {
-	if d.revClient == nil {
+	d.revOnce.Do(func() {
 		// create the http.client for the reverse connections
 		tr := &http.Transport{
 			Proxy:               nil,    // no proxies
@@ -134,7 +215,7 @@ func (d *Dialer) reverseClient() *http.Client {
 			Transport: tr,
 		}
 		d.revClient = &client
-	}
+	})
 	return d.revClient
 
 }
@@ -143,6 +224,15 @@ func (d *Dialer) reverseClient() *http.Client {
 func (d *Dialer) Dial(ctx context.Context, network string, address string) (net.Conn, error) {
 	now := time.Now()
 	defer klog.V(5).Infof("dial to %s took %v", address, time.Since(now))
+
+	// multiplexed streams open without a round trip to the listener
+	if s := d.muxSession(); s != nil {
+		c, err := s.Open()
+		if err == nil {
+			return c, nil
+		}
+		klog.V(4).Infof("failed to open multiplexed stream, dialing reverse connection: %v", err)
+	}
 	// First, tell serve that we want a connection:
 	select {
 	case d.connReady <- true:
@@ -0,0 +1,94 @@
+package h2rev2
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrain(t *testing.T) {
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: DefaultLiveness})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Liveness: DefaultLiveness})
+	require.NoError(t, err)
+	defer l.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("finished"))
+	})}
+	go downstream.Serve(l)
+	defer downstream.Close()
+
+	require.Eventually(t, func() bool { return pool.Replicas("token") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// request in flight when the gateway goes down
+	type result struct {
+		body string
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		resp, err := gateway.Client().Get(gateway.URL + "/" + pathRevProxy + "/token/slow")
+		if err != nil {
+			resultCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		resultCh <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		drained <- pool.Drain(ctx)
+	}()
+
+	select {
+	case <-l.GoAway():
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener not asked to go away")
+	}
+
+	// new listeners are sent elsewhere
+	resp, err := gateway.Client().Get(gateway.URL + "/" + pathRevDial + "?id=other&replica=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// listener finishes the request in flight before closing
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- l.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("listener closed with request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	res := <-resultCh
+	require.NoError(t, res.err)
+	assert.Equal(t, "finished", res.body)
+	require.NoError(t, <-shutdown)
+	require.NoError(t, <-drained)
+	assert.Equal(t, 0, pool.Replicas("token"))
+}
@@ -0,0 +1,117 @@
+package h2rev2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// holdCheckInterval is how often held requests check if a listener attached
+	holdCheckInterval = 100 * time.Millisecond
+	// unavailableRetryAfter is when visitors should retry requests no listener
+	// was attached for
+	unavailableRetryAfter = time.Second
+)
+
+// Hold parks proxied requests of an id without attached listener, e.g. while
+// the connector reconnects, until one attaches or the timeout passes. Only
+// ids with a listener disconnected within the timeout are held.
+type Hold struct {
+	// Timeout is how long requests wait for a listener. 0 disables holding.
+	Timeout time.Duration
+	// MaxBodyBytes holds requests of any method with body up to the size,
+	// buffering it while they wait so idempotent ones can be retried. 0 holds
+	// only idempotent requests without body.
+	MaxBodyBytes int64
+}
+
+// idempotent returns true for methods safe to send again
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// holdable returns true if the request may wait for a listener. Body of the
+// request is buffered when it has one, so it can be sent again.
+func (h Hold) holdable(r *http.Request) bool {
+	if h.Timeout <= 0 {
+		return false
+	}
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return idempotent(r.Method) || h.MaxBodyBytes > 0
+	}
+	if h.MaxBodyBytes <= 0 || r.ContentLength > h.MaxBodyBytes {
+		return false
+	}
+
+	// length may be unknown, read past the limit to find out
+	body, err := io.ReadAll(io.LimitReader(r.Body, h.MaxBodyBytes+1))
+	if err != nil || int64(len(body)) > h.MaxBodyBytes {
+		// send what was read followed by the rest
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return false
+	}
+	r.Body.Close()
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return true
+}
+
+// retryable returns true if the request failed over the tunnel may be sent
+// again
+func retryable(r *http.Request) bool {
+	return idempotent(r.Method) && (r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 || r.GetBody != nil)
+}
+
+// rewind replaces consumed body of the request to send it again
+func rewind(r *http.Request) error {
+	if r.GetBody == nil {
+		return nil
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	return nil
+}
+
+// waitHold waits before looking for a listener again. It returns false
+// once the deadline passed or the request is cancelled.
+func waitHold(ctx context.Context, deadline time.Time) bool {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return false
+	}
+	if wait > holdCheckInterval {
+		wait = holdCheckInterval
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// unavailable responds the id has no listener to proxy the request to. The
+// connector is expected to reconnect shortly.
+func unavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(unavailableRetryAfter.Seconds())))
+	http.Error(w, "not reverse connections for this id available", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,192 @@
+package h2rev2
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHold(t *testing.T) {
+	pool := NewReversePool(PoolOptions{
+		Hold: func(id string) Hold {
+			switch id {
+			case "held":
+				return Hold{Timeout: 10 * time.Second, MaxBodyBytes: 1024}
+			case "short":
+				return Hold{Timeout: 200 * time.Millisecond}
+			}
+			return Hold{}
+		},
+	})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	type result struct {
+		status     int
+		body       string
+		retryAfter string
+	}
+	do := func(method, id, body string) result {
+		req, err := http.NewRequest(method, gateway.URL+"/"+pathRevProxy+"/"+id+"/app", strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := gateway.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return result{status: resp.StatusCode, body: string(b), retryAfter: resp.Header.Get("Retry-After")}
+	}
+
+	// disconnect attaches listener of the id and closes it, so requests wait
+	// for it to reconnect
+	disconnect := func(t *testing.T, id string) {
+		l, err := NewListener(gateway.Client(), gateway.URL, id, ListenerOptions{Transport: TransportHTTP2})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return pool.Replicas(id) == 1 }, 5*time.Second, 10*time.Millisecond)
+		l.Close()
+		require.Eventually(t, func() bool { return pool.Replicas(id) == 0 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	t.Run("should fail right away without hold", func(t *testing.T) {
+		res := do(http.MethodGet, "none", "")
+		assert.Equal(t, http.StatusServiceUnavailable, res.status)
+		assert.Equal(t, "1", res.retryAfter)
+	})
+
+	t.Run("should fail right away if listener did not disconnect recently", func(t *testing.T) {
+		start := time.Now()
+		res := do(http.MethodGet, "held", "")
+		assert.Equal(t, http.StatusServiceUnavailable, res.status)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("should respond unavailable after hold timeout", func(t *testing.T) {
+		disconnect(t, "short")
+		start := time.Now()
+		res := do(http.MethodGet, "short", "")
+		assert.Equal(t, http.StatusServiceUnavailable, res.status)
+		assert.Equal(t, "1", res.retryAfter)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("should not hold requests with body over the limit", func(t *testing.T) {
+		disconnect(t, "held")
+		start := time.Now()
+		res := do(http.MethodPost, "held", strings.Repeat("a", 2048))
+		assert.Equal(t, http.StatusServiceUnavailable, res.status)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("should forward held requests once listener attaches", func(t *testing.T) {
+		disconnect(t, "held")
+		results := make(chan result, 2)
+		go func() { results <- do(http.MethodGet, "held", "") }()
+		go func() { results <- do(http.MethodPost, "held", "posted") }()
+
+		// let requests park before the listener attaches
+		time.Sleep(300 * time.Millisecond)
+		l, err := NewListener(gateway.Client(), gateway.URL, "held", ListenerOptions{Transport: TransportHTTP2})
+		require.NoError(t, err)
+		defer l.Close()
+		downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + string(body)))
+		})}
+		go downstream.Serve(l)
+		defer downstream.Close()
+
+		var bodies []string
+		for i := 0; i < 2; i++ {
+			res := <-results
+			assert.Equal(t, http.StatusOK, res.status)
+			bodies = append(bodies, res.body)
+		}
+		assert.ElementsMatch(t, []string{"GET ", "POST posted"}, bodies)
+	})
+
+	t.Run("should stream body of request with attached listener", func(t *testing.T) {
+		l, err := NewListener(gateway.Client(), gateway.URL, "held", ListenerOptions{Transport: TransportHTTP2})
+		require.NoError(t, err)
+		defer l.Close()
+		downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// answer before the body is sent
+			http.NewResponseController(w).EnableFullDuplex()
+			w.WriteHeader(http.StatusOK)
+			http.NewResponseController(w).Flush()
+			io.Copy(w, r.Body)
+		})}
+		go downstream.Serve(l)
+		defer downstream.Close()
+		require.Eventually(t, func() bool { return pool.Replicas("held") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		// body under the limit would be read in full if it was buffered
+		pr, pw := io.Pipe()
+		req, err := http.NewRequest(http.MethodPut, gateway.URL+"/"+pathRevProxy+"/held/app", pr)
+		require.NoError(t, err)
+		respCh := make(chan *http.Response, 1)
+		go func() {
+			resp, err := gateway.Client().Do(req)
+			assert.NoError(t, err)
+			respCh <- resp
+		}()
+
+		var resp *http.Response
+		select {
+		case resp = <-respCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("response not streamed before the body")
+		}
+		require.NotNil(t, resp)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		pw.Write([]byte("streamed"))
+		pw.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "streamed", string(body))
+	})
+}
+
+func TestHoldable(t *testing.T) {
+	hold := Hold{Timeout: time.Second, MaxBodyBytes: 4}
+	for _, tc := range []struct {
+		name     string
+		hold     Hold
+		method   string
+		body     string
+		expected bool
+	}{
+		{"disabled", Hold{}, http.MethodGet, "", false},
+		{"idempotent", Hold{Timeout: time.Second}, http.MethodGet, "", true},
+		{"not idempotent", Hold{Timeout: time.Second}, http.MethodPost, "", false},
+		{"body without limit", Hold{Timeout: time.Second}, http.MethodPut, "body", false},
+		{"body under limit", hold, http.MethodPost, "body", true},
+		{"body over limit", hold, http.MethodPost, "bodies", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+			// unknown length is read up to the limit
+			req.ContentLength = -1
+			if tc.body == "" {
+				req.Body = http.NoBody
+			}
+			assert.Equal(t, tc.expected, tc.hold.holdable(req))
+
+			// body is kept for the request either way
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+}
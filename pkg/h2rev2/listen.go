@@ -4,6 +4,8 @@ package h2rev2
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,12 +14,15 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/google/uuid"
 	"golang.org/x/net/http2"
 	"k8s.io/klog/v2"
 )
@@ -29,17 +34,48 @@ var _ net.Listener = (*Listener)(nil)
 type Listener struct {
 	// Request for the reverse connection with format
 	// https://host:port/path/revdial?id=<id>
-	url    string
-	client *http.Client
+	url      string
+	client   *http.Client
+	liveness Liveness
+	pinger   *pinger
+	// transport carries reverse connections, resolved when control
+	// connection is created
+	transport Transport
 
 	sc     net.Conn // control plane connection
 	connc  chan net.Conn
 	donec  chan struct{}
-	writec chan<- []byte
+	writec chan []byte
+
+	writeMu sync.Mutex // serializes writes to sc
+
+	// goAway is closed once the dialer asks the listener to re-attach elsewhere
+	goAway     chan struct{}
+	goAwayOnce sync.Once
+	// drainc is closed once Shutdown stops accepting connections
+	drainc chan struct{}
+	// active counts accepted connections not closed yet
+	active sync.WaitGroup
+
+	mu       sync.Mutex // guards below, closing connc, and writing to rw
+	readErr  error
+	closed   bool
+	draining bool
+	onAction func(action api.ConnectionAction)
+	// mux is the multiplexed session once dialer agreed on versionMux, or
+	// the QUIC connection of the tunnel
+	mux session
+}
 
-	mu      sync.Mutex // guards below, closing connc, and writing to rw
-	readErr error
-	closed  bool
+// ListenerOptions configures optional behaviour of the Listener
+type ListenerOptions struct {
+	// Liveness configures how dead tunnel is detected, if the dialer supports it
+	Liveness Liveness
+	// Transport carries reverse connections. Defaults to TransportAuto.
+	Transport Transport
+	// Path is the reverse connection path element of the pool. Defaults to
+	// DefaultPaths.RevDial.
+	Path string
 }
 
 // NewListener returns a new Listener, it dials to the Dialer
@@ -47,30 +83,44 @@ type Listener struct {
 // - client: http client, required for TLS
 // - host: a URL to the base of the reverse handler on the Dialer
 // - id: identify this listener
-func NewListener(client *http.Client, host string, id string) (*Listener, error) {
+// - opts: optional behaviour of the listener
+func NewListener(client *http.Client, host string, id string, opts ListenerOptions) (*Listener, error) {
 	err := configureHTTP2Transport(client)
 	if err != nil {
 		return nil, err
 	}
 
-	url, err := serverURL(host, id)
+	// every listener is a separate replica of the connection
+	url, err := serverURL(host, opts.Path, id, uuid.New().String())
 	if err != nil {
 		return nil, err
 	}
 
 	ln := &Listener{
-		url:    url,
-		client: client,
-		connc:  make(chan net.Conn, 4), // arbitrary
-		donec:  make(chan struct{}),
+		url:       url,
+		client:    client,
+		liveness:  opts.Liveness,
+		transport: opts.Transport,
+		connc:     make(chan net.Conn, 4), // arbitrary
+		donec:     make(chan struct{}),
+		goAway:    make(chan struct{}),
+		drainc:    make(chan struct{}),
+		writec:    make(chan []byte, 8),
 	}
+	ln.pinger = newPinger(func(m controlMsg) error {
+		ln.sendMessage(m)
+		return nil
+	})
 
 	// create control plane connection
 	// poor man backoff retry
 	sleep := 1 * time.Second
 	var c net.Conn
 	for attempts := 5; attempts > 0; attempts-- {
-		c, err = ln.dial()
+		c, err = ln.dialControl()
+		if errors.Is(err, ErrUnauthorized) {
+			return nil, err
+		}
 		if err != nil {
 			klog.V(2).Infof("can not create control connection %v", err)
 			// Add some randomness to prevent creating a Thundering Herd
@@ -94,16 +144,22 @@ func NewListener(client *http.Client, host string, id string) (*Listener, error)
 func (ln *Listener) run() {
 	defer ln.Close()
 
+	// dialer opens connections as streams of the QUIC connection
+	ln.mu.Lock()
+	s := ln.mux
+	ln.mu.Unlock()
+	if s != nil {
+		go ln.acceptStreams(s)
+	}
+
 	// Write loop
-	writec := make(chan []byte, 8)
-	ln.writec = writec
 	go func() {
 		for {
 			select {
 			case <-ln.donec:
 				return
-			case msg := <-writec:
-				if _, err := ln.sc.Write(msg); err != nil {
+			case msg := <-ln.writec:
+				if err := ln.write(msg); err != nil {
 					log.Printf("revdial.Listener: error writing message to server: %v", err)
 					ln.Close()
 					return
@@ -128,8 +184,28 @@ func (ln *Listener) run() {
 		case "keep-alive":
 			// Occasional no-op message from server to keep
 			// us alive through NAT timeouts.
+		case "hello":
+			if msg.Version >= versionMux {
+				go ln.runMux()
+			}
+			if msg.Version >= versionLiveness {
+				go ln.pinger.run(ln.liveness, ln.donec, func() {
+					klog.V(2).Infof("revdial.Listener missed %d pongs, closing dead tunnel", ln.liveness.MaxMissed)
+					ln.closeWithError(errTunnelDead)
+				})
+			}
+		case "ping":
+			ln.sendMessage(controlMsg{Command: "pong", Seq: msg.Seq})
+		case "pong":
+			ln.pinger.pong(msg.Seq)
 		case "conn-ready":
 			go ln.grabConn()
+		case "go-away":
+			ln.goAwayOnce.Do(func() { close(ln.goAway) })
+		case "action":
+			if msg.Action != nil {
+				go ln.handleAction(*msg.Action)
+			}
 		default:
 			// Ignore unknown messages
 		}
@@ -139,12 +215,110 @@ func (ln *Listener) run() {
 func (ln *Listener) sendMessage(m controlMsg) {
 	j, _ := json.Marshal(m)
 	j = append(j, '\n')
-	ln.writec <- j
+	select {
+	case ln.writec <- j:
+	case <-ln.donec:
+	}
+}
+
+// GoAway returns a channel which is closed when the dialer is shutting down
+// and asks the listener to re-attach to another one. Listener keeps working
+// until the dialer closes it.
+func (ln *Listener) GoAway() <-chan struct{} { return ln.goAway }
+
+// ReportAgent sends agent information to the dialer over the control connection
+func (ln *Listener) ReportAgent(agent api.ConnectionAgent) {
+	ln.sendMessage(controlMsg{Command: "agent-info", Agent: &agent})
+}
+
+// OnAction sets the handler of remote control commands sent by the dialer.
+// Handler must acknowledge every action it receives.
+func (ln *Listener) OnAction(handler func(action api.ConnectionAction)) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	ln.onAction = handler
+}
+
+// Acknowledge sends result of the action to the dialer. Unlike other messages
+// it is written before returning, so callers can close the listener right after.
+func (ln *Listener) Acknowledge(action api.ConnectionAction) error {
+	j, _ := json.Marshal(controlMsg{Command: "action-ack", Action: &action})
+	return ln.write(append(j, '\n'))
+}
+
+func (ln *Listener) handleAction(action api.ConnectionAction) {
+	ln.mu.Lock()
+	handler := ln.onAction
+	ln.mu.Unlock()
+
+	if handler == nil {
+		action.Status = api.ConnectionActionFailed
+		action.Message = "connector does not support actions"
+		if err := ln.Acknowledge(action); err != nil {
+			klog.V(2).Infof("can not acknowledge action %s: %v", action.ID, err)
+		}
+		return
+	}
+	handler(action)
+}
+
+func (ln *Listener) write(msg []byte) error {
+	ln.writeMu.Lock()
+	defer ln.writeMu.Unlock()
+	_, err := ln.sc.Write(msg)
+	return err
+}
+
+// dialControl creates control connection, resolving TransportAuto to the
+// first transport which works
+func (ln *Listener) dialControl() (reverseConn, error) {
+	switch ln.transport {
+	case TransportHTTP2, TransportWebSocket:
+		return ln.dial()
+	case TransportQUIC:
+		c, err := ln.dialQUIC()
+		if err == nil {
+			return c, nil
+		}
+		klog.V(2).Infof("can not create QUIC connection, falling back to HTTP/2: %v", err)
+	case "", TransportAuto:
+	default:
+		return nil, fmt.Errorf("revdial: unknown transport %q", ln.transport)
+	}
+
+	c, err := ln.dialHTTP2(ln.url)
+	if err == nil {
+		ln.transport = TransportHTTP2
+		return c, nil
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		return nil, err
+	}
+	klog.V(2).Infof("can not create HTTP/2 control connection, falling back to WebSocket: %v", err)
+
+	ws, wsErr := dialWebSocket(ln.client, ln.url)
+	if wsErr != nil {
+		return nil, fmt.Errorf("%v; %w", err, wsErr)
+	}
+	ln.transport = TransportWebSocket
+	return ws, nil
+}
+
+func (ln *Listener) dial() (reverseConn, error) {
+	return ln.dialURL(ln.url)
+}
+
+// dialURL creates reverse connection over the transport of the control connection
+func (ln *Listener) dialURL(target string) (reverseConn, error) {
+	if ln.transport == TransportWebSocket {
+		return dialWebSocket(ln.client, target)
+	}
+	return ln.dialHTTP2(target)
 }
 
-func (ln *Listener) dial() (*conn, error) {
+func (ln *Listener) dialHTTP2(target string) (reverseConn, error) {
 	pr, pw := io.Pipe()
-	req, err := http.NewRequest("GET", ln.url, pr)
+	req, err := http.NewRequest("GET", target, pr)
 	if err != nil {
 		klog.V(5).Infof("Can not create request %v", err)
 		return nil, err
@@ -153,19 +327,49 @@ func (ln *Listener) dial() (*conn, error) {
 	// This helps to route connectors to the right handlers
 	req.Header.Set(api.ConnectionClientHeader, api.ConnectionClientValue)
 
-	klog.V(5).Infof("Listener creating connection to %s", ln.url)
+	// HTTP/1.1 server waits for the request body to end before answering,
+	// so abort the request as soon as the connection turns out not to be h2
+	var downgraded bool
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if tlsConn, ok := info.Conn.(*tls.Conn); ok && tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+				downgraded = true
+				pw.CloseWithError(errHTTP2Required)
+			}
+		},
+	}))
+
+	klog.V(5).Infof("Listener creating connection to %s", target)
 	res, err := ln.client.Do(req)
+	if downgraded {
+		if err == nil {
+			res.Body.Close()
+		}
+		return nil, errHTTP2Required
+	}
 	if err != nil {
 		fmt.Println(err)
-		klog.V(5).Infof("Can not connect to %s request %v, retry %d", ln.url, err)
+		klog.V(5).Infof("Can not connect to %s request %v, retry %d", target, err)
 		return nil, err
 	}
 
+	if res.StatusCode == http.StatusUnauthorized {
+		res.Body.Close()
+		pw.Close()
+		return nil, ErrUnauthorized
+	}
 	if res.StatusCode != 200 {
-		klog.V(5).Infof("Status code %d on request %v, retry %d", res.StatusCode, ln.url)
+		klog.V(5).Infof("Status code %d on request %v, retry %d", res.StatusCode, target)
 		return nil, fmt.Errorf("status code %d", res.StatusCode)
 	}
 
+	// HTTP/1.1 does not stream request and response at once reliably
+	if res.ProtoMajor < 2 {
+		res.Body.Close()
+		pw.Close()
+		return nil, errHTTP2Required
+	}
+
 	c := newConn(res.Body, pw)
 	return c, nil
 }
@@ -184,9 +388,13 @@ func (ln *Listener) grabConn() {
 	select {
 	case <-ln.donec:
 		return
+	case <-ln.drainc:
+		return
 	default:
 		select {
 		case ln.connc <- c:
+		case <-ln.drainc:
+			return
 		case <-ln.donec:
 			return
 		}
@@ -200,37 +408,179 @@ func (ln *Listener) grabConn() {
 	}
 }
 
+// runMux keeps a multiplexed session with the dialer and accepts streams it
+// opens, redialing the session until the listener is closed. Dialer falls
+// back to reverse connections while there is no session.
+func (ln *Listener) runMux() {
+	ln.mu.Lock()
+	running := ln.mux != nil
+	ln.mu.Unlock()
+	if running {
+		return
+	}
+
+	for !isClosedChan(ln.donec) {
+		c, err := ln.dialURL(ln.url + "&" + urlParamMux + "=1")
+		if err != nil {
+			klog.V(4).Infof("Can not create multiplexed connection %v", err)
+		} else {
+			session := newMuxSession(c, false)
+			ln.mu.Lock()
+			if ln.closed {
+				ln.mu.Unlock()
+				session.Close()
+				return
+			}
+			ln.mux = session
+			ln.mu.Unlock()
+
+			ln.acceptStreams(session)
+		}
+
+		select {
+		case <-ln.donec:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// acceptStreams hands streams of the session to Accept until it closes
+func (ln *Listener) acceptStreams(s session) {
+	for {
+		c, err := s.Accept()
+		if err != nil {
+			return
+		}
+		select {
+		case <-ln.donec:
+			c.Close()
+			return
+		case <-ln.drainc:
+			// refused, so the dialer fails fast
+			c.Close()
+		default:
+			select {
+			case ln.connc <- c:
+			case <-ln.drainc:
+				c.Close()
+			case <-ln.donec:
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
 // Accept blocks and returns a new connection, or an error.
 func (ln *Listener) Accept() (net.Conn, error) {
-	c, ok := <-ln.connc
+	var (
+		c  net.Conn
+		ok bool
+	)
+	select {
+	case c, ok = <-ln.connc:
+	case <-ln.drainc:
+		return nil, ErrListenerClosed
+	}
 	if !ok {
 		ln.mu.Lock()
-		err, closed := ln.readErr, ln.closed
+		err := ln.readErr
 		ln.mu.Unlock()
-		if err != nil && !closed {
+		if err != nil {
 			return nil, fmt.Errorf("revdial: Listener closed; %w", err)
 		}
 		return nil, ErrListenerClosed
 	}
+
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	if ln.draining {
+		c.Close()
+		return nil, ErrListenerClosed
+	}
+	ln.active.Add(1)
 	klog.V(5).Infof("Accept connection")
-	return c, nil
+	return &trackedConn{Conn: c, done: ln.active.Done}, nil
+}
+
+// Shutdown gracefully closes the Listener. It tells the dialer to send new
+// connections to other replicas, stops accepting them, and waits until
+// accepted connections, including hijacked ones, are closed or the context
+// is done.
+func (ln *Listener) Shutdown(ctx context.Context) error {
+	ln.sendMessage(controlMsg{Command: "draining"})
+
+	ln.mu.Lock()
+	if !ln.draining {
+		ln.draining = true
+		close(ln.drainc)
+	}
+	ln.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		ln.active.Wait()
+		close(idle)
+	}()
+
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	ln.Close()
+	return err
+}
+
+// trackedConn is a connection accepted by the Listener, Shutdown waits until
+// it is closed
+type trackedConn struct {
+	net.Conn
+	once sync.Once
+	done func()
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.done)
+	return err
 }
 
 // ErrListenerClosed is returned by Accept after Close has been called.
 var ErrListenerClosed = errors.New("revdial: Listener closed")
 
+// errTunnelDead is returned by Accept after dialer stopped answering pings
+var errTunnelDead = errors.New("dialer stopped answering pings")
+
+// RTT returns round trip time to the dialer measured by the last answered
+// ping, or 0 if the dialer does not answer pings.
+func (ln *Listener) RTT() time.Duration {
+	return ln.pinger.lastRTT()
+}
+
 // Close closes the Listener, making future Accept calls return an
 // error.
 func (ln *Listener) Close() error {
+	return ln.closeWithError(nil)
+}
+
+// closeWithError closes the Listener, making Accept return err
+func (ln *Listener) closeWithError(err error) error {
 	ln.mu.Lock()
 	defer ln.mu.Unlock()
 	if ln.closed {
 		return nil
 	}
+	ln.readErr = err
 	ln.closed = true
 	close(ln.connc)
 	close(ln.donec)
 	ln.sc.Close()
+	if ln.mux != nil {
+		ln.mux.Close()
+	}
 	return nil
 }
 
@@ -277,7 +627,7 @@ func strSliceContains(ss []string, s string) bool {
 }
 
 // serverURL builds the destination url with the query parameter
-func serverURL(host string, id string) (string, error) {
+func serverURL(host, path, id, replicaID string) (string, error) {
 	if id == "" {
 		return "", fmt.Errorf("id can't be empty")
 	}
@@ -286,5 +636,9 @@ func serverURL(host string, id string) (string, error) {
 		return "", fmt.Errorf("wrong url format, expected https://host<:port>/<path>: %w", err)
 	}
 	host = strings.Trim(host, "/")
-	return host + "/" + pathRevDial + "?" + urlParamKey + "=" + id, nil
+	query := url.Values{urlParamKey: {id}, urlParamReplica: {replicaID}, urlParamVersion: {strconv.Itoa(versionLatest)}}
+	if path == "" {
+		path = DefaultPaths.RevDial
+	}
+	return host + "/" + path + "?" + query.Encode(), nil
 }
@@ -0,0 +1,113 @@
+package h2rev2
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Liveness configures ping/pong on the control connection. Both sides ping
+// each other once the dialer agreed on versionLiveness in "hello", and close
+// the tunnel when pongs stop arriving.
+type Liveness struct {
+	// Interval is how often ping is sent. 0 disables pings.
+	Interval time.Duration
+	// MaxMissed is the number of pings in a row left without pong after which
+	// the tunnel is considered dead.
+	MaxMissed int
+}
+
+// DefaultLiveness notices dead tunnel within 45 seconds
+var DefaultLiveness = Liveness{
+	Interval:  15 * time.Second,
+	MaxMissed: 3,
+}
+
+// pinger sends pings over the control connection and measures round trip time
+// from the pongs.
+type pinger struct {
+	send func(m controlMsg) error
+
+	mu  sync.Mutex // guards below
+	seq uint64
+	// pending are the times unanswered pings were sent at
+	pending map[uint64]time.Time
+	rtt     time.Duration
+}
+
+func newPinger(send func(m controlMsg) error) *pinger {
+	return &pinger{
+		send:    send,
+		pending: map[uint64]time.Time{},
+	}
+}
+
+// run pings the peer until done is closed, calling onDead and returning once
+// MaxMissed pings in a row are left without pong
+func (p *pinger) run(liveness Liveness, done <-chan struct{}, onDead func()) {
+	if liveness.Interval <= 0 {
+		return
+	}
+	maxMissed := liveness.MaxMissed
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+	ticker := time.NewTicker(liveness.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if p.missed() >= maxMissed {
+			onDead()
+			return
+		}
+
+		p.mu.Lock()
+		p.seq++
+		seq := p.seq
+		p.pending[seq] = time.Now()
+		p.mu.Unlock()
+
+		if err := p.send(controlMsg{Command: "ping", Seq: seq}); err != nil {
+			klog.V(4).Infof("failed to send ping: %v", err)
+		}
+	}
+}
+
+// missed returns the number of pings without pong
+func (p *pinger) missed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// pong records answer to the ping. Pings sent before it are answered too,
+// since the control connection keeps the order of messages.
+func (p *pinger) pong(seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sentAt, ok := p.pending[seq]
+	if !ok {
+		return
+	}
+	p.rtt = time.Since(sentAt)
+	for s := range p.pending {
+		if s <= seq {
+			delete(p.pending, s)
+		}
+	}
+}
+
+// lastRTT returns round trip time of the last answered ping
+func (p *pinger) lastRTT() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rtt
+}
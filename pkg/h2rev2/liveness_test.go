@@ -0,0 +1,120 @@
+package h2rev2
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinger(t *testing.T) {
+	liveness := Liveness{Interval: 10 * time.Millisecond, MaxMissed: 3}
+
+	t.Run("should measure round trip time from pongs", func(t *testing.T) {
+		var p *pinger
+		p = newPinger(func(m controlMsg) error {
+			assert.Equal(t, "ping", m.Command)
+			go func() {
+				time.Sleep(time.Millisecond)
+				p.pong(m.Seq)
+			}()
+			return nil
+		})
+
+		done := make(chan struct{})
+		dead := make(chan struct{})
+		go p.run(liveness, done, func() { close(dead) })
+
+		require.Eventually(t, func() bool { return p.lastRTT() > 0 }, time.Second, time.Millisecond)
+		assert.GreaterOrEqual(t, p.lastRTT(), time.Millisecond)
+
+		// answered tunnel is never considered dead
+		time.Sleep(5 * liveness.Interval)
+		assert.False(t, isClosedChan(dead))
+		close(done)
+	})
+
+	t.Run("should report dead peer after missed pongs", func(t *testing.T) {
+		sent := 0
+		p := newPinger(func(m controlMsg) error {
+			sent++
+			return nil
+		})
+
+		dead := make(chan struct{})
+		go p.run(liveness, make(chan struct{}), func() { close(dead) })
+
+		select {
+		case <-dead:
+		case <-time.After(time.Second):
+			t.Fatal("dead peer not detected")
+		}
+		assert.Equal(t, liveness.MaxMissed, sent)
+	})
+
+	t.Run("should count earlier pings answered by later pong", func(t *testing.T) {
+		p := newPinger(nil)
+		now := time.Now()
+		p.pending = map[uint64]time.Time{1: now, 2: now, 3: now}
+		p.pong(2)
+		assert.Equal(t, 1, p.missed())
+	})
+}
+
+func TestDialerEvictsDeadTunnel(t *testing.T) {
+	gateway, connector := net.Pipe()
+	defer connector.Close()
+
+	d := NewDialer("token", gateway)
+	go d.runPinger(Liveness{Interval: 10 * time.Millisecond, MaxMissed: 2})
+
+	// connector answers the first ping and then stops
+	br := bufio.NewReader(connector)
+	line, err := br.ReadSlice('\n')
+	require.NoError(t, err)
+	var msg controlMsg
+	require.NoError(t, json.Unmarshal(line, &msg))
+	require.Equal(t, "ping", msg.Command)
+	pong, _ := json.Marshal(controlMsg{Command: "pong", Seq: msg.Seq})
+	_, err = connector.Write(append(pong, '\n'))
+	require.NoError(t, err)
+	go func() {
+		for {
+			if _, err := br.ReadSlice('\n'); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-d.Done():
+	case <-time.After(time.Second):
+		t.Fatal("dead tunnel not closed")
+	}
+	assert.Greater(t, d.RTT(), time.Duration(0))
+}
+
+func TestTunnelNegotiatesLiveness(t *testing.T) {
+	liveness := Liveness{Interval: 10 * time.Millisecond, MaxMissed: 3}
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: liveness})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Liveness: liveness})
+	require.NoError(t, err)
+	defer l.Close()
+
+	// both sides ping each other once listener advertised versionLiveness
+	require.Eventually(t, func() bool {
+		replicas := pool.replicas("token")
+		return l.RTT() > 0 && len(replicas) == 1 && replicas[0].dialer.RTT() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
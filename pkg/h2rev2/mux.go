@@ -0,0 +1,415 @@
+package h2rev2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Multiplexed mode carries many logical streams over a single reverse
+// connection, so new connections open without asking the listener to dial.
+//
+// Every frame starts with a header of frame type (1 byte), stream id
+// (4 bytes) and length (4 bytes). Data frames carry length bytes of payload,
+// window frames grant the peer length more bytes to send on the stream.
+
+const (
+	// frameOpen opens a new stream
+	frameOpen byte = iota + 1
+	// frameData carries stream data
+	frameData
+	// frameWindow grants the peer more bytes to send
+	frameWindow
+	// frameClose closes the stream, peer reads buffered data and then EOF
+	frameClose
+)
+
+const (
+	muxHeaderSize = 9
+	// muxMaxFrame is the largest payload of a data frame
+	muxMaxFrame = 16 * 1024
+	// muxWindow is the number of bytes peer may send before being granted more
+	muxWindow = 256 * 1024
+	// muxAcceptBacklog is the number of opened streams waiting for Accept
+	muxAcceptBacklog = 64
+)
+
+// ErrMuxClosed is returned by operations on a closed multiplexed session
+var ErrMuxClosed = errors.New("revdial: multiplexed session closed")
+
+// session opens and accepts streams to the peer over a single connection,
+// either a multiplexed reverse connection or a QUIC connection
+type session interface {
+	// Open opens a new stream to the peer
+	Open() (net.Conn, error)
+	// Accept returns the next stream opened by the peer
+	Accept() (net.Conn, error)
+	// Done returns a channel which is closed when the session is closed
+	Done() <-chan struct{}
+	// Close closes the session and all its streams
+	Close() error
+}
+
+var _ session = (*muxSession)(nil)
+
+// muxSession multiplexes streams over conn. Client opens odd stream ids and
+// server even ones, so both sides can open streams.
+type muxSession struct {
+	conn net.Conn
+
+	writeMu sync.Mutex // serializes frames written to conn
+
+	mu      sync.Mutex // guards below
+	streams map[uint32]*muxStream
+	nextID  uint32
+
+	accept    chan *muxStream
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newMuxSession(conn net.Conn, client bool) *muxSession {
+	s := &muxSession{
+		conn:    conn,
+		streams: map[uint32]*muxStream{},
+		nextID:  2,
+		accept:  make(chan *muxStream, muxAcceptBacklog),
+		done:    make(chan struct{}),
+	}
+	if client {
+		s.nextID = 1
+	}
+	go s.readLoop()
+	return s
+}
+
+// Open opens a new stream to the peer
+func (s *muxSession) Open() (net.Conn, error) {
+	s.mu.Lock()
+	if isClosedChan(s.done) {
+		s.mu.Unlock()
+		return nil, ErrMuxClosed
+	}
+	id := s.nextID
+	s.nextID += 2
+	st := newMuxStream(s, id)
+	s.streams[id] = st
+	s.mu.Unlock()
+
+	if err := s.writeFrame(frameOpen, id, 0, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Accept returns the next stream opened by the peer
+func (s *muxSession) Accept() (net.Conn, error) {
+	select {
+	case st := <-s.accept:
+		return st, nil
+	case <-s.done:
+		return nil, ErrMuxClosed
+	}
+}
+
+// Done returns a channel which is closed when the session is closed
+func (s *muxSession) Done() <-chan struct{} { return s.done }
+
+// Close closes the session and all its streams
+func (s *muxSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.conn.Close()
+		close(s.done)
+	})
+	return nil
+}
+
+func (s *muxSession) readLoop() {
+	defer s.Close()
+
+	header := make([]byte, muxHeaderSize)
+	for {
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			return
+		}
+		typ := header[0]
+		id := binary.BigEndian.Uint32(header[1:5])
+		length := binary.BigEndian.Uint32(header[5:9])
+
+		switch typ {
+		case frameOpen:
+			st := newMuxStream(s, id)
+			s.mu.Lock()
+			// peer opens ids of the other parity only, and must not take over
+			// streams in use
+			_, exists := s.streams[id]
+			valid := id != 0 && id%2 != s.nextID%2 && !exists
+			if valid {
+				s.streams[id] = st
+			}
+			s.mu.Unlock()
+			if !valid {
+				if err := s.writeFrame(frameClose, id, 0, nil); err != nil {
+					return
+				}
+				continue
+			}
+			select {
+			case s.accept <- st:
+			default:
+				// nobody accepts streams fast enough, refuse it
+				st.Close()
+			}
+		case frameData:
+			if length > muxMaxFrame {
+				return
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(s.conn, data); err != nil {
+				return
+			}
+			if st := s.getStream(id); st != nil {
+				if err := st.receive(data); err != nil {
+					return
+				}
+			}
+		case frameWindow:
+			if st := s.getStream(id); st != nil {
+				st.grant(length)
+			}
+		case frameClose:
+			if st := s.getStream(id); st != nil {
+				st.remoteClose()
+				s.removeStream(id)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *muxSession) getStream(id uint32) *muxStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+func (s *muxSession) removeStream(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, id)
+}
+
+// writeFrame writes header and payload at once, so frames don't interleave
+func (s *muxSession) writeFrame(typ byte, id uint32, length uint32, payload []byte) error {
+	frame := make([]byte, muxHeaderSize+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], id)
+	binary.BigEndian.PutUint32(frame[5:9], length)
+	copy(frame[muxHeaderSize:], payload)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if isClosedChan(s.done) {
+		return ErrMuxClosed
+	}
+	if _, err := s.conn.Write(frame); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+var _ net.Conn = (*muxStream)(nil)
+
+// muxStream is a logical connection inside a multiplexed session
+type muxStream struct {
+	id      uint32
+	session *muxSession
+
+	mu sync.Mutex // guards below
+	// buf holds received data not read yet
+	buf bytes.Buffer
+	// credit is the number of bytes the peer allows us to send
+	credit uint32
+	// consumed is the number of bytes read and not granted back to the peer yet
+	consumed     uint32
+	localClosed  bool
+	remoteClosed bool
+
+	// readable and writable wake up blocked Read and Write
+	readable chan struct{}
+	writable chan struct{}
+
+	readDeadline  *connDeadline
+	writeDeadline *connDeadline
+}
+
+func newMuxStream(session *muxSession, id uint32) *muxStream {
+	return &muxStream{
+		id:            id,
+		session:       session,
+		credit:        muxWindow,
+		readable:      make(chan struct{}, 1),
+		writable:      make(chan struct{}, 1),
+		readDeadline:  makeConnDeadline(),
+		writeDeadline: makeConnDeadline(),
+	}
+}
+
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// receive buffers data sent by the peer
+func (st *muxStream) receive(data []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.buf.Len()+len(data) > muxWindow {
+		return fmt.Errorf("revdial: stream %d exceeded flow control window", st.id)
+	}
+	st.buf.Write(data)
+	notify(st.readable)
+	return nil
+}
+
+// grant allows sending n more bytes to the peer
+func (st *muxStream) grant(n uint32) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.credit += n
+	notify(st.writable)
+}
+
+func (st *muxStream) remoteClose() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.remoteClosed = true
+	notify(st.readable)
+	notify(st.writable)
+}
+
+// Read reads data from the stream
+func (st *muxStream) Read(data []byte) (int, error) {
+	for {
+		st.mu.Lock()
+		switch {
+		case st.buf.Len() > 0:
+			n, _ := st.buf.Read(data)
+			st.consumed += uint32(n)
+			// grant window back in chunks, not on every small read
+			var update uint32
+			if st.consumed >= muxMaxFrame || st.buf.Len() == 0 {
+				update, st.consumed = st.consumed, 0
+			}
+			closed := st.remoteClosed
+			st.mu.Unlock()
+			if update > 0 && !closed {
+				st.session.writeFrame(frameWindow, st.id, update, nil)
+			}
+			return n, nil
+		case st.localClosed:
+			st.mu.Unlock()
+			return 0, io.ErrClosedPipe
+		case st.remoteClosed:
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+		st.mu.Unlock()
+
+		select {
+		case <-st.readable:
+		case <-st.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		case <-st.session.done:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Write writes data to the stream, blocking while the peer window is full
+func (st *muxStream) Write(data []byte) (int, error) {
+	written := 0
+	for written < len(data) {
+		st.mu.Lock()
+		if st.localClosed || st.remoteClosed {
+			st.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		if st.credit == 0 {
+			st.mu.Unlock()
+			select {
+			case <-st.writable:
+				continue
+			case <-st.writeDeadline.wait():
+				return written, os.ErrDeadlineExceeded
+			case <-st.session.done:
+				return written, io.ErrClosedPipe
+			}
+		}
+		n := len(data) - written
+		if n > muxMaxFrame {
+			n = muxMaxFrame
+		}
+		if uint32(n) > st.credit {
+			n = int(st.credit)
+		}
+		st.credit -= uint32(n)
+		st.mu.Unlock()
+
+		if err := st.session.writeFrame(frameData, st.id, uint32(n), data[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Close closes the stream on both sides
+func (st *muxStream) Close() error {
+	st.mu.Lock()
+	if st.localClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	remoteClosed := st.remoteClosed
+	notify(st.readable)
+	notify(st.writable)
+	st.mu.Unlock()
+
+	st.session.removeStream(st.id)
+	if remoteClosed {
+		return nil
+	}
+	return st.session.writeFrame(frameClose, st.id, 0, nil)
+}
+
+func (st *muxStream) LocalAddr() net.Addr  { return connAddr{} }
+func (st *muxStream) RemoteAddr() net.Addr { return connAddr{} }
+
+func (st *muxStream) SetDeadline(t time.Time) error {
+	st.readDeadline.set(t)
+	st.writeDeadline.set(t)
+	return nil
+}
+
+func (st *muxStream) SetReadDeadline(t time.Time) error {
+	st.readDeadline.set(t)
+	return nil
+}
+
+func (st *muxStream) SetWriteDeadline(t time.Time) error {
+	st.writeDeadline.set(t)
+	return nil
+}
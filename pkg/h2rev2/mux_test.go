@@ -0,0 +1,266 @@
+package h2rev2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMuxPair(t *testing.T) (*muxSession, *muxSession) {
+	c1, c2 := net.Pipe()
+	client := newMuxSession(c1, true)
+	server := newMuxSession(c2, false)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func TestMuxSession(t *testing.T) {
+	t.Run("should transfer data over the flow control window both ways", func(t *testing.T) {
+		client, server := newMuxPair(t)
+
+		payload := make([]byte, 3*muxWindow+123)
+		_, err := rand.Read(payload)
+		require.NoError(t, err)
+
+		// server echoes everything back
+		go func() {
+			c, err := server.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			io.Copy(c, c)
+		}()
+
+		c, err := client.Open()
+		require.NoError(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := c.Write(payload)
+			errCh <- err
+		}()
+
+		received := make([]byte, len(payload))
+		_, err = io.ReadFull(c, received)
+		require.NoError(t, err)
+		require.NoError(t, <-errCh)
+		assert.True(t, bytes.Equal(payload, received))
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("should read buffered data and EOF after peer closes", func(t *testing.T) {
+		client, server := newMuxPair(t)
+
+		c, err := client.Open()
+		require.NoError(t, err)
+		s, err := server.Accept()
+		require.NoError(t, err)
+
+		_, err = s.Write([]byte("bye"))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+
+		data, err := ioutil.ReadAll(c)
+		require.NoError(t, err)
+		assert.Equal(t, "bye", string(data))
+
+		_, err = c.Write([]byte("late"))
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("should keep streams independent", func(t *testing.T) {
+		client, server := newMuxPair(t)
+
+		c1, err := client.Open()
+		require.NoError(t, err)
+		c2, err := client.Open()
+		require.NoError(t, err)
+		s1, err := server.Accept()
+		require.NoError(t, err)
+		s2, err := server.Accept()
+		require.NoError(t, err)
+
+		_, err = c2.Write([]byte("two"))
+		require.NoError(t, err)
+		_, err = c1.Write([]byte("one"))
+		require.NoError(t, err)
+
+		buf := make([]byte, 3)
+		_, err = io.ReadFull(s1, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "one", string(buf))
+		_, err = io.ReadFull(s2, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "two", string(buf))
+	})
+
+	t.Run("should time out reads", func(t *testing.T) {
+		client, _ := newMuxPair(t)
+
+		c, err := client.Open()
+		require.NoError(t, err)
+		require.NoError(t, c.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err = c.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+
+	t.Run("should refuse streams peer must not open", func(t *testing.T) {
+		peer, conn := net.Pipe()
+		server := newMuxSession(conn, false)
+		t.Cleanup(func() {
+			peer.Close()
+			server.Close()
+		})
+
+		frame := func(typ byte, id uint32, payload string) []byte {
+			b := make([]byte, muxHeaderSize+len(payload))
+			b[0] = typ
+			binary.BigEndian.PutUint32(b[1:5], id)
+			binary.BigEndian.PutUint32(b[5:9], uint32(len(payload)))
+			copy(b[muxHeaderSize:], payload)
+			return b
+		}
+		expectClose := func(id uint32) {
+			header := make([]byte, muxHeaderSize)
+			_, err := io.ReadFull(peer, header)
+			require.NoError(t, err)
+			assert.Equal(t, frameClose, header[0])
+			assert.Equal(t, id, binary.BigEndian.Uint32(header[1:5]))
+		}
+
+		_, err := peer.Write(frame(frameOpen, 1, ""))
+		require.NoError(t, err)
+		s, err := server.Accept()
+		require.NoError(t, err)
+
+		// ids of the server parity and ids in use are refused
+		for _, id := range []uint32{0, 2, 1} {
+			_, err = peer.Write(frame(frameOpen, id, ""))
+			require.NoError(t, err)
+			expectClose(id)
+		}
+
+		// stream in use keeps its data, window granted back is not checked
+		go io.Copy(io.Discard, peer)
+		_, err = peer.Write(frame(frameData, 1, "data"))
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(s, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(buf))
+	})
+
+	t.Run("should fail streams once session closes", func(t *testing.T) {
+		client, server := newMuxPair(t)
+
+		c, err := client.Open()
+		require.NoError(t, err)
+		server.Close()
+
+		<-client.Done()
+		_, err = c.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		_, err = client.Open()
+		assert.ErrorIs(t, err, ErrMuxClosed)
+	})
+}
+
+func TestMultiplexedTunnel(t *testing.T) {
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: DefaultLiveness})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Liveness: DefaultLiveness})
+	require.NoError(t, err)
+	defer l.Close()
+
+	downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.URL.Path))
+	})}
+	go downstream.Serve(l)
+	defer downstream.Close()
+
+	// listener supporting versionMux attaches a multiplexed session
+	require.Eventually(t, func() bool {
+		replicas := pool.replicas("token")
+		return len(replicas) == 1 && replicas[0].dialer.muxSession() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		resp, err := gateway.Client().Get(gateway.URL + "/" + pathRevProxy + "/token/app")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hello from /app", string(body))
+	}
+}
+
+func TestWebSocketTunnel(t *testing.T) {
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: DefaultLiveness})
+
+	// gateway behind a proxy speaking only HTTP/1.1
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	for _, transport := range []Transport{TransportAuto, TransportWebSocket} {
+		t.Run(string(transport), func(t *testing.T) {
+			l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{
+				Liveness:  DefaultLiveness,
+				Transport: transport,
+			})
+			require.NoError(t, err)
+			defer l.Close()
+			assert.Equal(t, TransportWebSocket, l.transport)
+
+			downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello from " + r.URL.Path))
+			})}
+			go downstream.Serve(l)
+			defer downstream.Close()
+
+			require.Eventually(t, func() bool {
+				replicas := pool.replicas("token")
+				return len(replicas) == 1 && replicas[0].dialer.muxSession() != nil
+			}, 5*time.Second, 10*time.Millisecond)
+
+			resp, err := gateway.Client().Get(gateway.URL + "/" + pathRevProxy + "/token/app")
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, "hello from /app", string(body))
+		})
+	}
+
+	t.Run("http2", func(t *testing.T) {
+		url, err := serverURL(gateway.URL, "", "token", "replica")
+		require.NoError(t, err)
+		client := gateway.Client()
+		require.NoError(t, configureHTTP2Transport(client))
+
+		ln := &Listener{client: client, url: url, transport: TransportHTTP2}
+		_, err = ln.dialControl()
+		assert.ErrorIs(t, err, errHTTP2Required)
+	})
+}
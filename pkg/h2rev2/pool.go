@@ -3,163 +3,285 @@ package h2rev2
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/faroshq/faros-ingress/pkg/models"
-	"github.com/faroshq/faros-ingress/pkg/store"
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"golang.org/x/net/websocket"
 	"k8s.io/klog/v2"
 )
 
 type controlMsg struct {
-	Command  string `json:"command,omitempty"`  // "keep-alive", "conn-ready", "pickup-failed"
+	Command  string `json:"command,omitempty"`  // "hello", "ping", "pong", "keep-alive", "conn-ready", "pickup-failed", "agent-info", "action", "action-ack", "go-away", "draining"
 	ConnPath string `json:"connPath,omitempty"` // conn pick-up URL path for "conn-url", "pickup-failed"
 	Err      string `json:"err,omitempty"`
+	Version  int    `json:"version,omitempty"` // protocol version agreed on for "hello"
+	Seq      uint64 `json:"seq,omitempty"`     // sequence number of "ping", echoed by "pong"
+
+	Agent  *api.ConnectionAgent  `json:"agent,omitempty"`  // agent information for "agent-info"
+	Action *api.ConnectionAction `json:"action,omitempty"` // remote control command for "action", and its result for "action-ack"
 }
 
+// drainCheckInterval is how often Drain checks if listeners left
+var drainCheckInterval = 100 * time.Millisecond
+
 // ReversePool contains a pool of Dialers to create reverse connections
 // It exposes an http.Handler to handle the clients.
 //
-//	pool := h2rev2.NewReversePool()
+//	pool := h2rev2.NewReversePool(h2rev2.PoolOptions{})
 //	mux := http.NewServeMux()
 //	mux.Handle("", pool)
 type ReversePool struct {
-	mu    sync.Mutex
-	store store.Store
-	// pool of dialer
-	pool map[string]*Dialer
-	// authenticated is list of authenticated agents
-	authenticated map[string]string
+	mu            sync.Mutex
+	authenticator Authenticator
+	hooks         Hooks
+	paths         Paths
+	strategy      Strategy
+	liveness      Liveness
+	hold          func(id string) Hold
+	// pool of connector replicas per id
+	pool map[string]*replicaSet
+	// draining rejects new listeners once Drain is called
+	draining bool
 }
 
-// NewReversePool returns a ReversePool
-func NewReversePool(store store.Store) *ReversePool {
+// PoolOptions configures the ReversePool
+type PoolOptions struct {
+	// Authenticator authenticates listeners and proxied requests. Defaults
+	// to AllowAll.
+	Authenticator Authenticator
+	// Hooks are called on lifecycle events of the listeners
+	Hooks Hooks
+	// Paths of the endpoints. Defaults to DefaultPaths when empty.
+	Paths Paths
+	// Strategy spreads requests between replicas of an id. Defaults to
+	// StrategyRoundRobin.
+	Strategy Strategy
+	// Liveness detects and evicts dead tunnels
+	Liveness Liveness
+	// Hold returns how requests of the id wait for a listener to attach.
+	// Requests fail right away when nil.
+	Hold func(id string) Hold
+}
+
+// NewReversePool returns a ReversePool configured with opts
+func NewReversePool(opts PoolOptions) *ReversePool {
+	if opts.Authenticator == nil {
+		opts.Authenticator = AllowAll
+	}
+	if opts.Paths == (Paths{}) {
+		opts.Paths = DefaultPaths
+	}
+	if opts.Paths.RevDial == "" {
+		opts.Paths.RevDial = DefaultPaths.RevDial
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyRoundRobin
+	}
 	return &ReversePool{
-		pool:          map[string]*Dialer{},
-		authenticated: map[string]string{}, // objectID -> identity for tunnel
-		store:         store,
+		pool:          map[string]*replicaSet{},
+		authenticator: opts.Authenticator,
+		hooks:         opts.Hooks,
+		paths:         opts.Paths,
+		strategy:      opts.Strategy,
+		liveness:      opts.Liveness,
+		hold:          opts.Hold,
 	}
 }
 
-func (rp *ReversePool) Run(ctx context.Context) error {
-	// initial build of the connection pool
-	conns, err := rp.store.ListAllConnections(ctx)
-	if err != nil {
-		return err
+// Close the Reverse pool and all its dialers
+func (rp *ReversePool) Close() {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for _, set := range rp.pool {
+		for _, r := range set.list() {
+			r.dialer.Close()
+		}
 	}
+}
+
+// Drain asks listeners to re-attach to another dialer and waits until they
+// disconnect or the context is done, closing the rest. New listeners are
+// rejected while draining, requests are proxied to attached ones until
+// they leave.
+func (rp *ReversePool) Drain(ctx context.Context) error {
 	rp.mu.Lock()
-	for _, conn := range conns {
-		rp.authenticated[conn.ID] = conn.Token
+	rp.draining = true
+	sets := make([]*replicaSet, 0, len(rp.pool))
+	for _, set := range rp.pool {
+		sets = append(sets, set)
 	}
 	rp.mu.Unlock()
 
-	changesCh := make(chan *models.Event)
-
-	go func() {
-		klog.V(2).Info("Subscribing to changes")
-		defer klog.V(2).Info("Unsubscribing from changes")
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				err := rp.store.SubscribeChanges(ctx, func(event *models.Event) error {
-					changesCh <- event
-					return nil
-				})
-				if err != nil {
-					klog.Error(err, "failed to subscribe to changes")
-				}
-				// Retry to subscribe
-				time.Sleep(time.Second)
+	for _, set := range sets {
+		for _, r := range set.list() {
+			if err := r.dialer.GoAway(); err != nil {
+				klog.V(2).Infof("failed to send go-away to %s replica %q: %v", r.dialer.id, r.id, err)
 			}
 		}
-	}()
+	}
 
-	// Start periodically reschedule applications on all devices or individual
-	// ones if only minimal changes are required
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
 	for {
+		rp.mu.Lock()
+		remaining := 0
+		for _, set := range rp.pool {
+			if len(set.list()) != 0 {
+				remaining++
+			}
+		}
+		rp.mu.Unlock()
+		if remaining == 0 {
+			return nil
+		}
+
 		select {
 		case <-ctx.Done():
-			return nil
-		case event := <-changesCh:
-			switch event.Resource {
-			case models.EventResourceConnection:
-				switch event.Type {
-				case models.EventCreated:
-
-					klog.V(2).Info("agent connection created")
-					agent, err := rp.store.GetConnection(ctx, models.Connection{ID: event.ObjectID})
-					if err != nil {
-						klog.Error(err, "failed to get connection")
-						continue
-					}
-					rp.mu.Lock()
-					rp.authenticated[agent.ID] = agent.Token
-					rp.mu.Unlock()
-
-				case models.EventDeleted:
-					klog.V(2).Info("connection delete")
-					rp.mu.Lock()
-					delete(rp.authenticated, event.ObjectID)
-					rp.mu.Unlock()
-				case models.EventUpdated:
-					klog.V(2).Info("connection update")
-					conn, err := rp.store.GetConnection(ctx, models.Connection{ID: event.ObjectID})
-					if err != nil {
-						klog.Error(err, "failed to get connection")
-						continue
-					}
-					rp.mu.Lock()
-					rp.authenticated[conn.ID] = conn.Token
-					rp.mu.Unlock()
-				}
-			}
+			klog.V(2).Infof("closing tunnels of %d ids left after drain", remaining)
+			rp.Close()
+			return ctx.Err()
+		case <-ticker.C:
 		}
 	}
 }
 
-// Close the Reverse pool and all its dialers
-func (rp *ReversePool) Close() {
+// isDraining returns true once Drain is called
+func (rp *ReversePool) isDraining() bool {
 	rp.mu.Lock()
 	defer rp.mu.Unlock()
-	for _, v := range rp.pool {
-		v.Close()
+	return rp.draining
+}
+
+// GetDialer returns a reverse dialer of the replica for the id
+func (rp *ReversePool) GetDialer(id, replicaID string) *Dialer {
+	r := rp.getReplica(id, replicaID)
+	if r == nil {
+		return nil
 	}
+	return r.dialer
 }
 
-// GetDialer returns a reverse dialer for the id
-func (rp *ReversePool) GetDialer(id string) *Dialer {
+func (rp *ReversePool) getReplica(id, replicaID string) *replica {
 	rp.mu.Lock()
-	defer rp.mu.Unlock()
-	return rp.pool[id]
+	set := rp.pool[id]
+	rp.mu.Unlock()
+	if set == nil {
+		return nil
+	}
+	return set.get(replicaID)
 }
 
-// CreateDialer creates a reverse dialer with id
-// it's a noop if a dialer already exists
-func (rp *ReversePool) CreateDialer(id string, conn net.Conn) *Dialer {
+// Replicas returns number of connected replicas for the id
+func (rp *ReversePool) Replicas(id string) int {
+	return len(rp.replicas(id))
+}
+
+// replicas returns connected replicas for the id
+func (rp *ReversePool) replicas(id string) []*replica {
+	rp.mu.Lock()
+	set := rp.pool[id]
+	rp.mu.Unlock()
+	if set == nil {
+		return nil
+	}
+	return set.list()
+}
+
+// addReplica creates a reverse dialer for the replica with id, replacing
+// closed dialer of the same replica
+func (rp *ReversePool) addReplica(id, replicaID string, conn net.Conn) *replica {
 	rp.mu.Lock()
 	defer rp.mu.Unlock()
-	if d, ok := rp.pool[id]; ok {
-		return d
+
+	set, ok := rp.pool[id]
+	if !ok {
+		set = &replicaSet{}
+		rp.pool[id] = set
 	}
-	d := NewDialer(id, conn)
-	rp.pool[id] = d
-	return d
 
+	r := &replica{
+		id:     replicaID,
+		dialer: NewDialer(id, conn),
+	}
+	r.dialer.onAgent = func(agent api.ConnectionAgent) {
+		set.setHealthy(r, downstreamHealthy(agent))
+		if rp.hooks.OnAgent != nil {
+			rp.hooks.OnAgent(id, replicaID, agent)
+		}
+	}
+	if rp.hooks.OnActionResult != nil {
+		r.dialer.onAction = func(action api.ConnectionAction) {
+			rp.hooks.OnActionResult(id, action)
+		}
+	}
+	r.dialer.onDraining = func() {
+		klog.V(2).Infof("%s replica %q is draining", id, replicaID)
+		set.setDraining(r)
+	}
+	set.add(r)
+	return r
 }
 
-// DeleteDialer delete the reverse dialer for the id
-func (rp *ReversePool) DeleteDialer(id string) {
+// removeReplica removes the replica, dropping the id once it has no replicas.
+// Id with requests held is dropped only once they stop waiting for the
+// replica to reconnect.
+func (rp *ReversePool) removeReplica(id string, r *replica) {
+	var hold Hold
+	if rp.hold != nil {
+		hold = rp.hold(id)
+	}
+
 	rp.mu.Lock()
 	defer rp.mu.Unlock()
-	delete(rp.pool, id)
+
+	set, ok := rp.pool[id]
+	if !ok {
+		return
+	}
+	set.remove(r, time.Now())
+	if len(set.list()) != 0 {
+		return
+	}
+	if hold.Timeout <= 0 {
+		delete(rp.pool, id)
+		return
+	}
+	time.AfterFunc(hold.Timeout, func() {
+		rp.mu.Lock()
+		defer rp.mu.Unlock()
+		if rp.pool[id] == set && len(set.list()) == 0 {
+			delete(rp.pool, id)
+		}
+	})
+}
+
+// reconnecting returns true if a replica of the id disconnected within the
+// timeout, so it is expected to reconnect shortly
+func (rp *ReversePool) reconnecting(id string, timeout time.Duration) bool {
+	rp.mu.Lock()
+	set := rp.pool[id]
+	rp.mu.Unlock()
+	return set != nil && set.disconnectedWithin(timeout, time.Now())
+}
+
+// downstreamHealthy returns false only if agent reports its downstream is
+// not healthy, so replicas not reporting health are used as usual
+func downstreamHealthy(agent api.ConnectionAgent) bool {
+	for _, condition := range agent.Conditions {
+		if condition.Type == api.ConditionDownstreamHealthy {
+			return condition.Status != api.ConditionFalse
+		}
+	}
+	return true
 }
 
 // HTTP Handler that handles reverse connections and reverse proxy requests using 2 different paths:
@@ -193,8 +315,8 @@ func (rp *ReversePool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	pos := -1
 	for i := len(path) - 1; i >= 0; i-- {
 		p := path[i]
-		// pathRevDial comes with a param
-		if p == pathRevDial {
+		// revdial path comes with a param
+		if p == rp.paths.RevDial {
 			if i != len(path)-1 {
 				http.Error(w, "revdial: only last element on path allowed", http.StatusInternalServerError)
 				return
@@ -202,8 +324,8 @@ func (rp *ReversePool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			pos = i
 			break
 		}
-		// pathRevProxy requires at least the id subpath
-		if p == pathRevProxy {
+		// proxy path requires at least the id subpath
+		if rp.paths.Proxy != "" && p == rp.paths.Proxy {
 			if i == len(path)-1 {
 				http.Error(w, "proxy: reverse path id required", http.StatusInternalServerError)
 				return
@@ -217,122 +339,250 @@ func (rp *ReversePool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Forward proxy /base/proxy/id/..proxied path...
-	if path[pos] == pathRevProxy {
+	if path[pos] != rp.paths.RevDial {
+		rp.Proxy(w, r, path[pos+1], strings.Join(path[pos+2:], "/"))
+		return
+	}
 
-		// authenticate the request
-		if !rp.isAuthenticated(path[pos+1]) {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+	// The caller identify itself by the value of the keu, and replicas
+	// of the same connection by the replica key
+	// https://server/revdial?id=dialerUniq&replica=replicaID
+	dialerUniq := r.URL.Query().Get(urlParamKey)
+	if len(dialerUniq) == 0 {
+		http.Error(w, "only reverse connections with id supported", http.StatusInternalServerError)
+		return
+	}
+	if err := rp.authenticator.AuthenticateListener(r, dialerUniq); err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	replicaID := r.URL.Query().Get(urlParamReplica)
+
+	rep := rp.getReplica(dialerUniq, replicaID)
+	isMux := r.URL.Query().Get(urlParamMux) != ""
+	if isMux && (rep == nil || isClosedChan(rep.dialer.Done())) {
+		http.Error(w, "multiplexed connection requires control connection", http.StatusNotFound)
+		return
+	}
+	// listeners attached before draining keep opening connections
+	if (rep == nil || isClosedChan(rep.dialer.Done())) && rp.isDraining() {
+		http.Error(w, "revdial: draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	// connectors behind proxies downgrading HTTP/2 tunnel over WebSocket
+	isWebSocket := isWebSocketRequest(r)
+	if !isWebSocket && r.ProtoMajor < 2 {
+		http.Error(w, "reverse connections require HTTP/2 or WebSocket", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if isWebSocket {
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+			rp.serveReverseConn(r, rep, isMux, newDoneConn(ws))
+		}}.ServeHTTP(w, r)
+		return
+	}
+
+	// First flush response headers
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	rp.serveReverseConn(r, rep, isMux, newConn(r.Body, flushWriter{w}))
+}
+
+// Proxy proxies the request to the path of a listener of the id, picked by
+// the load balancing strategy
+func (rp *ReversePool) Proxy(w http.ResponseWriter, r *http.Request, id, path string) {
+	// authenticate the request
+	if err := rp.authenticator.AuthenticateProxy(r, id); err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, err := url.Parse("http://" + id)
+	if err != nil {
+		http.Error(w, "wrong url", http.StatusInternalServerError)
+		return
+	}
+
+	var hold Hold
+	if rp.hold != nil {
+		hold = rp.hold(id)
+	}
+	deadline := time.Now().Add(hold.Timeout)
+	// body is buffered only once the request has to wait for a listener,
+	// which is expected only if one disconnected recently
+	held, checked := false, false
+	for {
+		set, picked := rp.pick(id)
+		if picked == nil {
+			if !checked {
+				held, checked = rp.reconnecting(id, hold.Timeout) && hold.holdable(r), true
+			}
+			if held && waitHold(r.Context(), deadline) {
+				continue
+			}
+			unavailable(w)
 			return
 		}
 
-		id := path[pos+1]
-		target, err := url.Parse("http://" + id)
-		if err != nil {
-			http.Error(w, "wrong url", http.StatusInternalServerError)
+		// requests with body are sent again only if it was buffered
+		retry := hold.Timeout > 0 && retryable(r)
+		if !rp.proxyTo(w, r, target, path, set, picked, retry) {
 			return
 		}
-		d := rp.GetDialer(id)
-		if d == nil {
-			http.Error(w, "not reverse connections for this id available", http.StatusInternalServerError)
+		klog.V(2).Infof("retrying request to %s held for %s", target.Host, hold.Timeout)
+		if err := rewind(r); err != nil || !waitHold(r.Context(), deadline) {
+			unavailable(w)
 			return
 		}
-		transport := d.reverseClient().Transport
-		proxy := httputil.NewSingleHostReverseProxy(target)
-		originalDirector := proxy.Director
-		proxy.Transport = transport
-		proxy.Director = func(req *http.Request) {
-			req.Host = target.Host
-			req.URL.Path = strings.Join(path[pos+2:], "/")
-			originalDirector(req)
+	}
+}
+
+// pick returns replica of the id picked by the load balancing strategy, nil
+// if none is available
+func (rp *ReversePool) pick(id string) (*replicaSet, *replica) {
+	rp.mu.Lock()
+	set := rp.pool[id]
+	rp.mu.Unlock()
+	if set == nil {
+		return nil, nil
+	}
+	return set, set.pick(rp.strategy, time.Now())
+}
+
+// proxyTo proxies the request to the replica. If retry is set, failure to
+// reach the replica is not written, and true is returned to send the
+// request again.
+func (rp *ReversePool) proxyTo(w http.ResponseWriter, r *http.Request, target *url.URL, path string, set *replicaSet, picked *replica, retry bool) bool {
+	failed, retried := false, false
+	defer func() {
+		set.done(picked, failed, time.Now())
+	}()
+
+	transport := picked.dialer.reverseClient().Transport
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	originalDirector := proxy.Director
+	proxy.Transport = transport
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		if r.Context().Err() != nil || errors.Is(err, context.Canceled) {
+			// visitor went away, replica is not to blame
+			klog.V(4).Infof("proxy to replica %q of %s canceled: %v", picked.id, target.Host, err)
+			w.WriteHeader(http.StatusBadGateway)
+			return
 		}
-		proxy.FlushInterval = -1
-
-		proxy.ServeHTTP(w, r)
-		klog.V(5).Infof("proxy server closed %v ", err)
-	} else {
-		// The caller identify itself by the value of the keu
-		// https://server/revdial?id=dialerUniq
-		dialerUniq := r.URL.Query().Get(urlParamKey)
-		if len(dialerUniq) == 0 {
-			http.Error(w, "only reverse connections with id supported", http.StatusInternalServerError)
+		klog.V(2).Infof("proxy to replica %q of %s failed: %v", picked.id, target.Host, err)
+		failed = true
+		if retry {
+			retried = true
 			return
 		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	proxy.Director = func(req *http.Request) {
+		req.Host = target.Host
+		req.URL.Path = path
+		originalDirector(req)
+		acceptCompression(req)
+	}
+	proxy.ModifyResponse = decompressResponse
+	proxy.FlushInterval = -1
 
-		d := rp.GetDialer(dialerUniq)
-		// First flush response headers
-		if f, ok := w.(http.Flusher); ok {
-			f.Flush()
-		}
-		// first connection to register the dialer and start the control loop
-		if d == nil || isClosedChan(d.Done()) {
-			conn := newConn(r.Body, flushWriter{w})
-			rp.DeleteDialer(dialerUniq)
-			d = rp.CreateDialer(dialerUniq, conn)
-			// start control loop
-			go rp.startConnectionHealthPing(r.Context(), dialerUniq)
-
-			<-conn.Done()
-			klog.V(5).Infof("stopped dialer %s control connection ", dialerUniq)
-			return
+	proxy.ServeHTTP(w, r)
+	return retried
+}
 
-		}
+// serveReverseConn serves the reverse connection of the listener until it is
+// closed. First connection of the replica is its control connection, others
+// are multiplexed or single data connections.
+func (rp *ReversePool) serveReverseConn(r *http.Request, rep *replica, isMux bool, conn reverseConn) {
+	dialerUniq := r.URL.Query().Get(urlParamKey)
+	replicaID := r.URL.Query().Get(urlParamReplica)
+
+	// first connection of the replica registers the dialer and starts the control loop
+	if rep == nil || isClosedChan(rep.dialer.Done()) {
+		rp.serveControlConn(r, conn, nil)
+		return
+	}
+	d := rep.dialer
 
-		// create a reverse connection
-		klog.V(5).Infof("created reverse connection to %s %s id %s", r.RequestURI, r.RemoteAddr, dialerUniq)
-		conn := newConn(r.Body, flushWriter{w})
+	if isMux {
+		// new connections of the dialer are opened as streams of this connection
+		session := newMuxSession(conn, true)
+		d.setMux(session)
+		klog.V(5).Infof("multiplexed connection to %s id %s replica %q", r.RemoteAddr, dialerUniq, replicaID)
 		select {
-		case d.incomingConn <- conn:
+		case <-session.Done():
 		case <-d.Done():
-			http.Error(w, "Reverse dialer closed", http.StatusInternalServerError)
-			return
+			session.Close()
 		}
+		return
+	}
 
-		// keep the handler alive until the connection is closed
-		<-conn.Done()
-
-		klog.V(4).Infof("Connection from %s done", r.RemoteAddr)
+	// create a reverse connection
+	klog.V(5).Infof("created reverse connection to %s %s id %s", r.RequestURI, r.RemoteAddr, dialerUniq)
+	select {
+	case d.incomingConn <- conn:
+	case <-d.Done():
+		klog.V(5).Infof("reverse dialer %s closed", dialerUniq)
+		conn.Close()
+		return
 	}
+
+	// keep the handler alive until the connection is closed
+	<-conn.Done()
+
+	klog.V(4).Infof("Connection from %s done", r.RemoteAddr)
 }
 
-var healthPingInterval = time.Minute
+// serveControlConn registers the replica of the listener and runs its control
+// loop until the control connection is closed. Connections are opened over
+// the session, if the transport provides one.
+func (rp *ReversePool) serveControlConn(r *http.Request, conn reverseConn, s session) {
+	dialerUniq := r.URL.Query().Get(urlParamKey)
+	replicaID := r.URL.Query().Get(urlParamReplica)
 
-// startConnectionHealthPing updates the last seen time of the connection
-// TODO: move to more scalable solution
-func (rp *ReversePool) startConnectionHealthPing(ctx context.Context, id string) {
-	err := rp.store.UpdateConnectionLastSeen(ctx, models.Connection{Token: id}, models.StateConnected)
-	if err != nil {
-		klog.Errorf("failed to update connection last seen: %v", err)
+	rep := rp.addReplica(dialerUniq, replicaID, conn)
+	if s != nil {
+		rep.dialer.setMux(s)
+	}
+	if rp.hooks.OnConnect != nil {
+		rp.hooks.OnConnect(dialerUniq, replicaID)
 	}
 
-	for {
-		select {
-		case <-time.After(healthPingInterval):
-			err := rp.store.UpdateConnectionLastSeen(ctx, models.Connection{Token: id}, models.StateConnected)
-			if err != nil {
-				klog.Errorf("failed to update connection last seen: %v", err)
-			}
-		case <-ctx.Done():
-			err := rp.store.UpdateConnectionLastSeen(context.Background(), models.Connection{Token: id}, models.StateDisconnected)
-			if err != nil {
-				klog.Errorf("failed to update connection last seen: %v", err)
-			}
-			return
+	version, _ := strconv.Atoi(r.URL.Query().Get(urlParamVersion))
+	if version > versionLatest {
+		version = versionLatest
+	}
+	if version >= versionMux {
+		if err := rep.dialer.sendMessage(controlMsg{Command: "hello", Version: version}); err != nil {
+			klog.V(2).Infof("failed to negotiate protocol with dialer %s replica %q: %v", dialerUniq, replicaID, err)
 		}
 	}
+	if version >= versionLiveness {
+		go rep.dialer.runPinger(rp.liveness)
+	}
 
+	<-conn.Done()
+	rp.removeReplica(dialerUniq, rep)
+	if rp.hooks.OnDisconnect != nil {
+		rp.hooks.OnDisconnect(dialerUniq, replicaID)
+	}
+	klog.V(5).Infof("stopped dialer %s replica %q control connection ", dialerUniq, replicaID)
 }
 
-// isAuthenticated checks if the request is authenticated
-func (rp *ReversePool) isAuthenticated(dialerUniq string) bool {
-	rp.mu.Lock()
-	defer rp.mu.Unlock()
-	for _, identity := range rp.authenticated {
-		if identity == dialerUniq {
-			return true
+// SendAction sends the action to every replica of the id, returning errors
+// of replicas it failed to send to
+func (rp *ReversePool) SendAction(id string, action api.ConnectionAction) error {
+	var errs []error
+	for _, r := range rp.replicas(id) {
+		klog.V(2).Infof("sending action %s %s to %s replica %q", action.ID, action.Type, id, r.id)
+		if err := r.dialer.sendMessage(controlMsg{Command: "action", Action: &action}); err != nil {
+			errs = append(errs, fmt.Errorf("replica %q: %w", r.id, err))
 		}
 	}
-
-	return false
+	return errors.Join(errs...)
 }
 
 type flushWriter struct {
@@ -0,0 +1,154 @@
+package h2rev2
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerAuthenticator allows listeners with id "allowed" and proxied
+// requests carrying the secret header
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) AuthenticateListener(r *http.Request, id string) error {
+	if id != "allowed" {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (headerAuthenticator) AuthenticateProxy(r *http.Request, id string) error {
+	if r.Header.Get("X-Secret") != "secret" {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func TestReversePoolOptions(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	recorded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, events...)
+	}
+
+	pool := NewReversePool(PoolOptions{
+		Authenticator: headerAuthenticator{},
+		Hooks: Hooks{
+			OnConnect:    func(id, replicaID string) { record("connect " + id) },
+			OnDisconnect: func(id, replicaID string) { record("disconnect " + id) },
+		},
+		Paths: Paths{RevDial: "tunnel", Proxy: "via"},
+	})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	get := func(path string, header http.Header) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, gateway.URL+path, nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := gateway.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	_, err := NewListener(gateway.Client(), gateway.URL, "denied", ListenerOptions{Transport: TransportHTTP2, Path: "tunnel"})
+	assert.Error(t, err)
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "allowed", ListenerOptions{Transport: TransportHTTP2, Path: "tunnel"})
+	require.NoError(t, err)
+	downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.URL.Path))
+	})}
+	go downstream.Serve(l)
+	defer downstream.Close()
+
+	require.Eventually(t, func() bool { return pool.Replicas("allowed") == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"connect allowed"}, recorded())
+
+	status, _ := get("/via/allowed/app", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, body := get("/via/allowed/app", http.Header{"X-Secret": {"secret"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello from /app", body)
+	// default proxy path is not served
+	status, _ = get("/proxy/allowed/app", http.Header{"X-Secret": {"secret"}})
+	assert.Equal(t, http.StatusNotFound, status)
+
+	l.Close()
+	require.Eventually(t, func() bool {
+		return len(recorded()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "disconnect allowed", recorded()[1])
+}
+
+func TestProxyCanceled(t *testing.T) {
+	pool := NewReversePool(PoolOptions{})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := NewListener(gateway.Client(), gateway.URL, "slow", ListenerOptions{Transport: TransportHTTP2})
+	require.NoError(t, err)
+	defer l.Close()
+	started := make(chan struct{})
+	downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})}
+	go downstream.Serve(l)
+	defer downstream.Close()
+	require.Eventually(t, func() bool { return pool.Replicas("slow") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	set, r := pool.pick("slow")
+	require.NotNil(t, r)
+	set.done(r, false, time.Now())
+	// one more failure would eject the replica
+	set.mu.Lock()
+	r.failures = ejectAfterFailures - 1
+	set.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gateway.URL+"/"+pathRevProxy+"/slow/app", nil)
+	require.NoError(t, err)
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = gateway.Client().Do(req)
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		set.mu.Lock()
+		defer set.mu.Unlock()
+		return r.inFlight == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	assert.True(t, r.ejectedUntil.IsZero())
+}
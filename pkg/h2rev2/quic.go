@@ -0,0 +1,396 @@
+package h2rev2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"k8s.io/klog/v2"
+)
+
+// QUIC transport carries the whole tunnel over a single QUIC connection.
+// Listener opens the first stream as the control connection and writes the
+// revdial request URI on it, terminated by a newline. Every connection the
+// dialer opens afterwards is a native QUIC stream, so streams do not block
+// each other on packet loss.
+
+const (
+	// quicALPN is the application protocol of tunnels over QUIC
+	quicALPN = "faros-revdial"
+	// quicMaxRequestLine bounds the request URI sent on the control stream
+	quicMaxRequestLine = 4096
+	// quicControlTimeout bounds waiting for the control stream of a new connection
+	quicControlTimeout = 10 * time.Second
+	// quicMigrateTimeout bounds validation of a new network path
+	quicMigrateTimeout = 5 * time.Second
+)
+
+var (
+	// quicHandshakeTimeout is how long the listener waits for the gateway
+	// before considering UDP blocked
+	quicHandshakeTimeout = 5 * time.Second
+	// quicNetworkCheckInterval is how often the listener checks if the
+	// system routes the gateway through another local address
+	quicNetworkCheckInterval = 5 * time.Second
+)
+
+// errQUICBadRequest is returned when the control stream does not start with
+// a revdial request
+var errQUICBadRequest = errors.New("revdial: invalid QUIC control stream request")
+
+func quicConfig() *quic.Config {
+	return &quic.Config{
+		HandshakeIdleTimeout: quicHandshakeTimeout,
+		MaxIdleTimeout:       30 * time.Second,
+		KeepAlivePeriod:      10 * time.Second,
+		MaxIncomingStreams:   1000,
+	}
+}
+
+// ListenQUIC listens for tunnels over QUIC on the UDP addr. Connections are
+// served with ReversePool.ServeQUIC.
+func ListenQUIC(addr string, tlsConfig *tls.Config) (*quic.Listener, error) {
+	config := tlsConfig.Clone()
+	config.NextProtos = []string{quicALPN}
+	return quic.ListenAddr(addr, config, quicConfig())
+}
+
+// quicSession opens and accepts streams of the QUIC connection
+type quicSession struct {
+	conn *quic.Conn
+	// addresses of the connection when it was established. quic.Conn
+	// addresses are not safe to read while the connection migrates.
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
+	mu sync.Mutex // guards below
+	// transports are the UDP sockets of network paths listener used, closed
+	// together with the session
+	transports []*quic.Transport
+	localIP    net.IP
+}
+
+var _ session = (*quicSession)(nil)
+
+func newQUICSession(conn *quic.Conn, transport *quic.Transport) *quicSession {
+	s := &quicSession{
+		conn:       conn,
+		localAddr:  conn.LocalAddr(),
+		remoteAddr: conn.RemoteAddr(),
+	}
+	if transport != nil {
+		s.transports = []*quic.Transport{transport}
+		if addr, ok := transport.Conn.LocalAddr().(*net.UDPAddr); ok {
+			s.localIP = addr.IP
+		}
+	}
+	return s
+}
+
+// Open opens a new stream to the peer
+func (s *quicSession) Open() (net.Conn, error) {
+	st, err := s.conn.OpenStreamSync(s.conn.Context())
+	if err != nil {
+		return nil, err
+	}
+	return s.stream(st), nil
+}
+
+// Accept returns the next stream opened by the peer
+func (s *quicSession) Accept() (net.Conn, error) {
+	st, err := s.conn.AcceptStream(s.conn.Context())
+	if err != nil {
+		return nil, err
+	}
+	return s.stream(st), nil
+}
+
+func (s *quicSession) stream(st *quic.Stream) *quicStream {
+	return &quicStream{Stream: st, session: s}
+}
+
+// Done returns a channel which is closed when the connection is closed
+func (s *quicSession) Done() <-chan struct{} { return s.conn.Context().Done() }
+
+// Close closes the connection and all its streams
+func (s *quicSession) Close() error {
+	err := s.conn.CloseWithError(0, "")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.transports {
+		t.Close()
+	}
+	s.transports = nil
+	return err
+}
+
+// control returns the control connection over the stream. Closing it closes
+// the session, and it is closed once the session is.
+func (s *quicSession) control(st net.Conn) *doneConn {
+	c := newDoneConn(st)
+	go func() {
+		select {
+		case <-s.Done():
+			c.Close()
+		case <-c.Done():
+			s.Close()
+		}
+	}()
+	return c
+}
+
+// followNetwork migrates the connection to the local address the system
+// routes the peer through, e.g. after the laptop switched from Wi-Fi to a
+// mobile network, until the session is closed
+func (s *quicSession) followNetwork() {
+	ticker := time.NewTicker(quicNetworkCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ip, err := routeIP(s.remoteAddr)
+		if err != nil {
+			klog.V(4).Infof("can not find route to %s: %v", s.remoteAddr, err)
+			continue
+		}
+		s.mu.Lock()
+		current := s.localIP
+		s.mu.Unlock()
+		if current == nil || current.Equal(ip) {
+			continue
+		}
+
+		if err := s.migrate(ip); err != nil {
+			klog.V(2).Infof("can not migrate QUIC connection from %s to %s: %v", current, ip, err)
+			continue
+		}
+		klog.V(2).Infof("migrated QUIC connection from %s to %s", current, ip)
+	}
+}
+
+// migrate moves the connection to a new network path from the local ip
+func (s *quicSession) migrate(ip net.IP) error {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip})
+	if err != nil {
+		return err
+	}
+	transport := &quic.Transport{Conn: udp}
+	path, err := s.conn.AddPath(transport)
+	if err != nil {
+		transport.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(s.conn.Context(), quicMigrateTimeout)
+	defer cancel()
+	if err := path.Probe(ctx); err != nil {
+		path.Close()
+		transport.Close()
+		return err
+	}
+	if err := path.Switch(); err != nil {
+		path.Close()
+		transport.Close()
+		return err
+	}
+
+	// sockets of previous paths are closed with the session, closing
+	// them earlier terminates the connection
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transports = append(s.transports, transport)
+	s.localIP = ip
+	return nil
+}
+
+// routeIP returns local address the system routes packets to remote from.
+// Connecting UDP socket sends no packets.
+func routeIP(remote net.Addr) (net.IP, error) {
+	udpAddr, ok := remote.(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("unexpected address %v", remote)
+	}
+	c, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+var _ net.Conn = (*quicStream)(nil)
+
+// quicStream is a QUIC stream of the tunnel
+type quicStream struct {
+	*quic.Stream
+	session *quicSession
+}
+
+func (st *quicStream) LocalAddr() net.Addr  { return st.session.localAddr }
+func (st *quicStream) RemoteAddr() net.Addr { return st.session.remoteAddr }
+
+// Close closes both directions of the stream, peer reads buffered data and
+// then EOF
+func (st *quicStream) Close() error {
+	st.Stream.CancelRead(0)
+	return st.Stream.Close()
+}
+
+// dialQUIC creates the QUIC connection of the tunnel and returns its control
+// stream. Other streams are accepted once the listener runs.
+func (ln *Listener) dialQUIC() (reverseConn, error) {
+	u, err := url.Parse(ln.url)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := tlsClientConfig(ln.client)
+	config.NextProtos = []string{quicALPN}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+
+	// own socket per connection, so it can be closed with the session
+	udp, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	transport := &quic.Transport{Conn: udp}
+
+	ctx, cancel := context.WithTimeout(context.Background(), quicHandshakeTimeout+quicControlTimeout)
+	defer cancel()
+	conn, err := transport.Dial(ctx, raddr, config, quicConfig())
+	if err != nil {
+		transport.Close()
+		return nil, fmt.Errorf("revdial: QUIC dial failed: %w", err)
+	}
+	s := newQUICSession(conn, transport)
+	// local address of the socket is unspecified, use the routed one
+	if ip, err := routeIP(raddr); err == nil {
+		s.localIP = ip
+	}
+
+	st, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("revdial: QUIC control stream failed: %w", err)
+	}
+	control := s.stream(st)
+	if _, err := control.Write([]byte(u.RequestURI() + "\n")); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	ln.mu.Lock()
+	ln.mux = s
+	ln.mu.Unlock()
+	go s.followNetwork()
+	return s.control(control), nil
+}
+
+// ServeQUIC serves tunnels connecting over QUIC until the context is done
+func (rp *ReversePool) ServeQUIC(ctx context.Context, l *quic.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go rp.serveQUICConn(conn)
+	}
+}
+
+// serveQUICConn serves the tunnel of the QUIC connection until it is closed
+func (rp *ReversePool) serveQUICConn(conn *quic.Conn) {
+	s := newQUICSession(conn, nil)
+
+	ctx, cancel := context.WithTimeout(conn.Context(), quicControlTimeout)
+	defer cancel()
+	st, err := conn.AcceptStream(ctx)
+	if err != nil {
+		klog.V(4).Infof("no control stream from %s: %v", s.remoteAddr, err)
+		s.Close()
+		return
+	}
+	control := s.stream(st)
+
+	r, err := readQUICRequest(ctx, control, rp.paths.RevDial)
+	if err != nil {
+		klog.V(4).Infof("invalid control stream from %s: %v", s.remoteAddr, err)
+		conn.CloseWithError(1, err.Error())
+		return
+	}
+	if err := rp.authenticator.AuthenticateListener(r, r.URL.Query().Get(urlParamKey)); err != nil {
+		klog.V(4).Infof("unauthorized QUIC connection from %s: %v", s.remoteAddr, err)
+		conn.CloseWithError(1, "unauthorized")
+		return
+	}
+	if rp.isDraining() {
+		conn.CloseWithError(1, "draining")
+		return
+	}
+
+	klog.V(5).Infof("QUIC connection from %s id %s", r.RemoteAddr, r.URL.Query().Get(urlParamKey))
+	rp.serveControlConn(r, s.control(control), s)
+}
+
+// readQUICRequest reads request for the revdial path from the control stream
+func readQUICRequest(ctx context.Context, control *quicStream, revDial string) (*http.Request, error) {
+	deadline, _ := ctx.Deadline()
+	control.SetReadDeadline(deadline)
+	defer control.SetReadDeadline(time.Time{})
+
+	// read byte by byte, control messages may follow right away
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := control.Read(b); err != nil {
+			return nil, err
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+		if len(line) > quicMaxRequestLine {
+			return nil, errQUICBadRequest
+		}
+	}
+
+	r, err := http.NewRequestWithContext(control.session.conn.Context(), http.MethodGet, string(line), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errQUICBadRequest, err)
+	}
+	if !strings.HasSuffix(r.URL.Path, "/"+revDial) || r.URL.Query().Get(urlParamKey) == "" {
+		return nil, errQUICBadRequest
+	}
+	r.RemoteAddr = control.RemoteAddr().String()
+	return r, nil
+}
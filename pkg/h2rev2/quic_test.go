@@ -0,0 +1,112 @@
+package h2rev2
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQUICTunnel(t *testing.T) {
+	pool := NewReversePool(PoolOptions{Strategy: StrategyRoundRobin, Liveness: DefaultLiveness})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+	gatewayURL, err := url.Parse(gateway.URL)
+	require.NoError(t, err)
+
+	get := func(t *testing.T) {
+		resp, err := gateway.Client().Get(gateway.URL + "/" + pathRevProxy + "/token/app")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "hello from /app", string(body))
+	}
+
+	serve := func(t *testing.T, l *Listener) {
+		downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello from " + r.URL.Path))
+		})}
+		go downstream.Serve(l)
+		t.Cleanup(func() { downstream.Close() })
+	}
+
+	t.Run("should fall back to HTTP/2 when UDP is blocked", func(t *testing.T) {
+		timeout := quicHandshakeTimeout
+		quicHandshakeTimeout = 100 * time.Millisecond
+		defer func() { quicHandshakeTimeout = timeout }()
+
+		l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Transport: TransportQUIC})
+		require.NoError(t, err)
+		defer l.Close()
+		assert.Equal(t, TransportHTTP2, l.transport)
+
+		serve(t, l)
+		get(t)
+	})
+
+	// gateway accepts QUIC on the UDP port of its URL
+	ql, err := ListenQUIC(gatewayURL.Host, gateway.TLS)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pool.ServeQUIC(ctx, ql)
+
+	t.Run("should open connections as QUIC streams", func(t *testing.T) {
+		l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{
+			Liveness:  DefaultLiveness,
+			Transport: TransportQUIC,
+		})
+		require.NoError(t, err)
+		defer l.Close()
+		assert.Equal(t, TransportQUIC, l.transport)
+
+		serve(t, l)
+
+		require.Eventually(t, func() bool {
+			replicas := pool.replicas("token")
+			if len(replicas) != 1 {
+				return false
+			}
+			_, ok := replicas[0].dialer.muxSession().(*quicSession)
+			return ok
+		}, 5*time.Second, 10*time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			get(t)
+		}
+
+		// closing the listener removes the replica
+		l.Close()
+		require.Eventually(t, func() bool {
+			return len(pool.replicas("token")) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("should migrate connection to new local address", func(t *testing.T) {
+		l, err := NewListener(gateway.Client(), gateway.URL, "token", ListenerOptions{Transport: TransportQUIC})
+		require.NoError(t, err)
+		defer l.Close()
+		serve(t, l)
+
+		l.mu.Lock()
+		session := l.mux.(*quicSession)
+		l.mu.Unlock()
+
+		// 127.0.0.2 is routed over loopback as well
+		require.NoError(t, session.migrate(net.ParseIP("127.0.0.2")))
+		assert.Equal(t, "127.0.0.2", session.localIP.String())
+		get(t)
+	})
+
+}
@@ -0,0 +1,164 @@
+package h2rev2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/websocket"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+)
+
+// Transport is the protocol carrying reverse connections of the tunnel.
+// Control and data protocol is the same over all transports.
+type Transport string
+
+const (
+	// TransportAuto uses HTTP/2 and falls back to WebSocket when HTTP/2 is
+	// not available end to end
+	TransportAuto Transport = "auto"
+	// TransportHTTP2 streams reverse connections as full-duplex HTTP/2 requests
+	TransportHTTP2 Transport = "http2"
+	// TransportWebSocket carries reverse connections as WebSocket over HTTP/1.1
+	TransportWebSocket Transport = "websocket"
+	// TransportQUIC carries the tunnel over QUIC and falls back like
+	// TransportAuto when UDP is blocked
+	TransportQUIC Transport = "quic"
+)
+
+// errHTTP2Required is returned when HTTP/2 request was downgraded on the way
+var errHTTP2Required = errors.New("revdial: HTTP/2 is not available end to end")
+
+// reverseConn is a reverse connection of any transport
+type reverseConn interface {
+	net.Conn
+	// Done returns a channel which is closed when the connection is closed
+	Done() <-chan struct{}
+}
+
+var _ reverseConn = (*conn)(nil)
+var _ reverseConn = (*doneConn)(nil)
+
+// doneConn signals when the wrapped connection is closed
+type doneConn struct {
+	net.Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newDoneConn(c net.Conn) *doneConn {
+	return &doneConn{Conn: c, done: make(chan struct{})}
+}
+
+func (c *doneConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		err = c.Conn.Close()
+		close(c.done)
+	})
+	return err
+}
+
+func (c *doneConn) Done() <-chan struct{} { return c.done }
+
+// isWebSocketRequest returns true if the request asks to upgrade to WebSocket
+func isWebSocketRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// dialWebSocket opens reverse connection to https target over WebSocket,
+// using TLS configuration of the client
+func dialWebSocket(client *http.Client, target string) (*doneConn, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host}
+	u.Scheme = "wss"
+
+	config, err := websocket.NewConfig(u.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	config.TlsConfig = tlsClientConfig(client)
+	// This helps to route connectors to the right handlers
+	config.Header.Set(api.ConnectionClientHeader, api.ConnectionClientValue)
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	// dial the same way as the client does, e.g. through a proxy
+	rwc, err := dialTLS(client)(context.Background(), "tcp", addr, config.TlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("revdial: websocket dial failed: %w", err)
+	}
+	ws, err := websocket.NewClient(config, rwc)
+	if err != nil {
+		rwc.Close()
+		return nil, fmt.Errorf("revdial: websocket dial failed: %w", err)
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return newDoneConn(ws), nil
+}
+
+type dialTLSFunc func(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error)
+
+// dialTLS returns TLS dial function of the client transport
+func dialTLS(client *http.Client) dialTLSFunc {
+	switch t := client.Transport.(type) {
+	case *http2.Transport:
+		if t.DialTLSContext != nil {
+			return t.DialTLSContext
+		}
+	case *http.Transport:
+		if t.DialContext != nil {
+			return func(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+				conn, err := t.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				if config.ServerName == "" {
+					config = config.Clone()
+					config.ServerName, _, _ = net.SplitHostPort(addr)
+				}
+				tlsConn := tls.Client(conn, config)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
+			}
+		}
+	}
+	return func(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+		return (&tls.Dialer{Config: config}).DialContext(ctx, network, addr)
+	}
+}
+
+// tlsClientConfig returns TLS configuration of the client transport for
+// HTTP/1.1 connections
+func tlsClientConfig(client *http.Client) *tls.Config {
+	var config *tls.Config
+	switch t := client.Transport.(type) {
+	case *http.Transport:
+		config = t.TLSClientConfig
+	case *http2.Transport:
+		config = t.TLSClientConfig
+	}
+	if config == nil {
+		return &tls.Config{}
+	}
+	config = config.Clone()
+	config.NextProtos = []string{"http/1.1"}
+	return config
+}
@@ -12,8 +12,9 @@ const (
 type EventResource string
 
 const (
-	EventResourceUser       EventResource = "user"
-	EventResourceConnection EventResource = "connection"
+	EventResourceUser             EventResource = "user"
+	EventResourceConnection       EventResource = "connection"
+	EventResourceConnectionAction EventResource = "connection-action"
 )
 
 // Event is used to send notifications to k8s layer for reconciliation.
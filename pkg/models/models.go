@@ -26,6 +26,8 @@ type Connection struct {
 	LastUsedAt time.Time       `json:"lastUsedAt" yaml:"lastUsedAt" grom:"index"`
 	TTL        time.Duration   `json:"ttl,omitempty" yaml:"ttl,omitempty"`
 	State      ConnectionState `json:"state,omitempty" yaml:"state,omitempty"`
+	// Replicas is the number of connectors attached to the connection
+	Replicas int `json:"replicas" yaml:"replicas"`
 
 	// UserID is the ID of the user that owns the remote connection
 	UserID string `json:"userId" yaml:"userId" gorm:"index"`
@@ -41,8 +43,231 @@ type Connection struct {
 	// Secure is the flag for the stating if we should use basic auth
 	Secure bool `json:"secure" yaml:"secure"`
 	// BasicAuthHash is the authentication hash of the remote connection
+	// Deprecated: kept for connections created before Credentials were introduced.
 	BasicAuthHash []byte `json:"basicAuthHash" yaml:"basicAuthHash"`
+	// Credentials are the named credentials allowed to access the secure connection
+	Credentials []ConnectionCredential `json:"credentials,omitempty" yaml:"credentials,omitempty" gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE"`
+	// Domains are the custom domains attached to the connection
+	Domains []ConnectionDomain `json:"domains,omitempty" yaml:"domains,omitempty" gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE"`
+	// Tunnels are the gateways holding tunnels of the connection
+	Tunnels []ConnectionTunnel `json:"tunnels,omitempty" yaml:"tunnels,omitempty" gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE"`
+	// Actions are the remote control commands sent to the connector
+	Actions []ConnectionAction `json:"actions,omitempty" yaml:"actions,omitempty" gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE"`
 
 	// GatewayURL is the URL of the remote connection to be used for remote dialing
 	GatewayURL string `json:"gatewayUrl" yaml:"gatewayUrl"`
+
+	// AuthFailures is the number of failed credential checks against the connection
+	AuthFailures int64 `json:"authFailures" yaml:"authFailures"`
+	// AuthLockouts is the number of lockouts caused by failed credential checks
+	AuthLockouts int64 `json:"authLockouts" yaml:"authLockouts"`
+	// AuthLockedUntil is the time until the connection hostname is locked out
+	AuthLockedUntil time.Time `json:"authLockedUntil" yaml:"authLockedUntil"`
+
+	// RateLimit is the number of requests per second allowed. 0 means server default, negative means unlimited.
+	RateLimit float64 `json:"rateLimit" yaml:"rateLimit"`
+	// RateBurst is the number of requests allowed to burst over RateLimit. 0 means server default.
+	RateBurst int `json:"rateBurst" yaml:"rateBurst"`
+	// MaxInFlight is the number of concurrent requests allowed. 0 means server default, negative means unlimited.
+	MaxInFlight int `json:"maxInFlight" yaml:"maxInFlight"`
+	// HoldTimeout is how long requests wait for the connector to reconnect. 0 means server default, negative disables holding.
+	HoldTimeout time.Duration `json:"holdTimeout" yaml:"holdTimeout"`
+	// HoldMaxBodyBytes is the size up to which requests with body are held too. 0 means server default, negative holds only idempotent requests.
+	HoldMaxBodyBytes int64 `json:"holdMaxBodyBytes" yaml:"holdMaxBodyBytes"`
+
+	// Agent is the last reported information about the connector serving the connection
+	Agent ConnectionAgent `json:"agent" yaml:"agent" gorm:"serializer:json"`
+}
+
+// ConnectionAgent is the information connector reports about itself over the
+// tunnel control channel.
+type ConnectionAgent struct {
+	Version       string    `json:"version,omitempty" yaml:"version,omitempty"`
+	Hostname      string    `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	OS            string    `json:"os,omitempty" yaml:"os,omitempty"`
+	StartedAt     time.Time `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	DownstreamURL string    `json:"downstreamUrl,omitempty" yaml:"downstreamUrl,omitempty"`
+	// ReportedAt is the time gateway received the report
+	ReportedAt time.Time `json:"reportedAt,omitempty" yaml:"reportedAt,omitempty"`
+
+	// Gateways are the gateways connector chose and latency it measured to them
+	Gateways   []ConnectionAgentGateway `json:"gateways,omitempty" yaml:"gateways,omitempty"`
+	Conditions []ConnectionCondition    `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Compression is the size of responses connector compressed over tunnels
+	Compression *ConnectionAgentCompression `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+// ConnectionAgentCompression is the size of compressed responses before and after compression
+type ConnectionAgentCompression struct {
+	UncompressedBytes int64   `json:"uncompressedBytes,omitempty" yaml:"uncompressedBytes,omitempty"`
+	CompressedBytes   int64   `json:"compressedBytes,omitempty" yaml:"compressedBytes,omitempty"`
+	Ratio             float64 `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+}
+
+// ConnectionAgentGateway is the gateway connector holds tunnel to
+type ConnectionAgentGateway struct {
+	Hostname string        `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Latency  time.Duration `json:"latency,omitempty" yaml:"latency,omitempty"`
+}
+
+type ConditionStatus string
+
+var (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// ConditionDownstreamHealthy reports if downstream answers health probes of the connector
+const ConditionDownstreamHealthy = "DownstreamHealthy"
+
+type ConnectionCondition struct {
+	Type               string          `json:"type" yaml:"type"`
+	Status             ConditionStatus `json:"status" yaml:"status"`
+	Reason             string          `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message            string          `json:"message,omitempty" yaml:"message,omitempty"`
+	LastTransitionTime time.Time       `json:"lastTransitionTime,omitempty" yaml:"lastTransitionTime,omitempty"`
+}
+
+type CredentialType string
+
+var (
+	CredentialTypeBasicAuth CredentialType = "basic-auth"
+	CredentialTypeAPIKey    CredentialType = "api-key"
+)
+
+// ConnectionCredential is a model for the named credential database model allowing access to a secure connection.
+type ConnectionCredential struct {
+	ID         string    `json:"id" yaml:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" yaml:"updatedAt"`
+	LastUsedAt time.Time `json:"lastUsedAt" yaml:"lastUsedAt"`
+	// ExpiresAt is the time after which the credential is no longer accepted. Zero means never.
+	ExpiresAt time.Time `json:"expiresAt" yaml:"expiresAt"`
+
+	// ConnectionID is the ID of the connection the credential belongs to
+	ConnectionID string `json:"connectionId" yaml:"connectionId" gorm:"uniqueIndex:idx_connection_credential_name"`
+	// Name is user facing name of the credential. Must be unique per connection.
+	Name string `json:"name" yaml:"name" gorm:"uniqueIndex:idx_connection_credential_name"`
+	// Type is the type of the credential
+	Type CredentialType `json:"type" yaml:"type"`
+
+	// Username is the basic auth username. Only used for basic-auth credentials.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	// Header is the request header carrying the key. Only used for api-key credentials.
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Hash is the hash of the credential secret
+	Hash []byte `json:"hash" yaml:"hash"`
+}
+
+// Expired returns true if the credential is past its expiry time.
+func (c ConnectionCredential) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt)
+}
+
+// ConnectionDomain is a model for the custom domain database model attached
+// to a connection. Domains are routed only once DNS ownership is verified.
+type ConnectionDomain struct {
+	ID            string    `json:"id" yaml:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt" yaml:"updatedAt"`
+	LastCheckedAt time.Time `json:"lastCheckedAt" yaml:"lastCheckedAt"`
+	VerifiedAt    time.Time `json:"verifiedAt" yaml:"verifiedAt"`
+
+	// ConnectionID is the ID of the connection the domain belongs to
+	ConnectionID string `json:"connectionId" yaml:"connectionId" gorm:"index"`
+	// Hostname is the custom domain name. Must be unique.
+	Hostname string `json:"hostname" yaml:"hostname" gorm:"uniqueIndex"`
+	// Token is the verification token expected in the TXT challenge record
+	Token string `json:"token" yaml:"token"`
+	// Verified is set once domain ownership was verified
+	Verified bool `json:"verified" yaml:"verified" gorm:"index"`
+	// Message is the reason of the last failed verification
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+type ConnectionActionType string
+
+var (
+	ConnectionActionDisconnect    ConnectionActionType = "disconnect"
+	ConnectionActionReconnect     ConnectionActionType = "reconnect"
+	ConnectionActionSetDownstream ConnectionActionType = "set-downstream"
+	ConnectionActionReloadRoutes  ConnectionActionType = "reload-routes"
+	ConnectionActionDiagnostics   ConnectionActionType = "diagnostics"
+)
+
+type ConnectionActionStatus string
+
+var (
+	ConnectionActionPending   ConnectionActionStatus = "Pending"
+	ConnectionActionSucceeded ConnectionActionStatus = "Succeeded"
+	ConnectionActionFailed    ConnectionActionStatus = "Failed"
+)
+
+// ConnectionAction is a model for the remote control command database model.
+// Gateway owning the tunnel of the connection sends it to the connector and
+// stores its acknowledgement.
+type ConnectionAction struct {
+	ID        string    `json:"id" yaml:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" yaml:"updatedAt"`
+
+	// ConnectionID is the ID of the connection the action is sent to
+	ConnectionID string `json:"connectionId" yaml:"connectionId" gorm:"index"`
+	// Type is the command connector executes
+	Type ConnectionActionType `json:"type" yaml:"type"`
+	// GatewayURL is the gateway to reconnect to. Only used for reconnect actions.
+	GatewayURL string `json:"gatewayUrl,omitempty" yaml:"gatewayUrl,omitempty"`
+	// DownstreamURL is the new default downstream. Only used for set-downstream actions.
+	DownstreamURL string `json:"downstreamUrl,omitempty" yaml:"downstreamUrl,omitempty"`
+
+	// Status is the result of the action as acknowledged by the connector
+	Status ConnectionActionStatus `json:"status" yaml:"status"`
+	// Message is the reason of the failed action
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Diagnostics are collected by the connector for diagnostics actions
+	Diagnostics map[string]string `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty" gorm:"serializer:json"`
+}
+
+// Gateway is a model for the gateway replica database model. Gateways
+// heartbeat into it so replicas can learn about each other.
+type Gateway struct {
+	ID         string    `json:"id" yaml:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" yaml:"updatedAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" yaml:"lastSeenAt" gorm:"index"`
+
+	// URL is the URL the gateway is externally accessible at
+	URL string `json:"url" yaml:"url"`
+	// Region is the region gateway runs in, used to rank gateways for connectors
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+}
+
+// ConnectionTunnel is a model for the tunnels of a connection held by a
+// single gateway replica. Gateways use it to forward visitors to a replica
+// holding the tunnel when they hold none themselves.
+type ConnectionTunnel struct {
+	ConnectionID string    `json:"connectionId" yaml:"connectionId" gorm:"primaryKey"`
+	GatewayID    string    `json:"gatewayId" yaml:"gatewayId" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" yaml:"updatedAt"`
+	LastSeenAt   time.Time `json:"lastSeenAt" yaml:"lastSeenAt"`
+
+	// GatewayURL is the URL the gateway is directly accessible at
+	GatewayURL string `json:"gatewayUrl" yaml:"gatewayUrl"`
+	// Replicas is the number of connectors attached to the gateway
+	Replicas int `json:"replicas" yaml:"replicas"`
+}
+
+// ConnectionUsage is a model for the hourly connection usage database model.
+type ConnectionUsage struct {
+	ConnectionID string `json:"connectionId" yaml:"connectionId" gorm:"primaryKey"`
+	// Hour is the start of the hour usage was recorded in, in UTC
+	Hour time.Time `json:"hour" yaml:"hour" gorm:"primaryKey"`
+	// UserID is the ID of the user that owns the connection
+	UserID string `json:"userId" yaml:"userId" gorm:"index"`
+
+	Requests int64 `json:"requests" yaml:"requests"`
+	BytesIn  int64 `json:"bytesIn" yaml:"bytesIn"`
+	BytesOut int64 `json:"bytesOut" yaml:"bytesOut"`
 }
@@ -0,0 +1,381 @@
+// Package sdk exposes Go programs to the internet through faros ingress
+// without a separate connector process. Visitor connections are accepted
+// straight from the tunnel, so there is no downstream hop.
+package sdk
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/client"
+	connectorclient "github.com/faroshq/faros-ingress/pkg/connector/client"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+	utilproxy "github.com/faroshq/faros-ingress/pkg/util/proxy"
+	utilstrings "github.com/faroshq/faros-ingress/pkg/util/strings"
+	utiltunnel "github.com/faroshq/faros-ingress/pkg/util/tunnel"
+)
+
+// Options configures the connection the program is exposed through
+type Options struct {
+	// ControllerURL is the URL of the API server
+	ControllerURL string
+	// AccessKey authenticates with the API server
+	AccessKey string
+	// Name of the connection. Connection with the name is reused when it
+	// exists, otherwise it is created. Defaults to a random name.
+	Name string
+	// Secure protects created connection with Basic Auth
+	Secure bool
+	// TTL of created connection. 0 uses the API server default.
+	TTL time.Duration
+	// TLSConfig verifies the API server and gateways. nil uses the system
+	// roots.
+	TLSConfig *tls.Config
+	// ProxyURL is the outbound proxy to reach API and gateways through, with
+	// http, https or socks5 scheme. When empty, HTTPS_PROXY, HTTP_PROXY and
+	// NO_PROXY environment variables are used.
+	ProxyURL string
+	// NoProxy lists hosts reached directly when ProxyURL is set
+	NoProxy string
+	// ProxyAuthorization is sent as Proxy-Authorization header to HTTP proxies
+	ProxyAuthorization string
+	// Transport carries the tunnel. Defaults to h2rev2.TransportAuto.
+	Transport h2rev2.Transport
+	// Liveness configures how dead tunnel is detected
+	Liveness h2rev2.Liveness
+	// DrainTimeout bounds waiting for accepted connections to close when
+	// the tunnel is closed or moved away from a gateway going down. Defaults
+	// to 30 seconds.
+	DrainTimeout time.Duration
+	// AllowReconnect lets reconnect actions move the tunnel to the gateway
+	// URL they carry. Disconnect actions are always executed.
+	AllowReconnect bool
+	// DisableCompression sends responses of Serve over the tunnel
+	// uncompressed. Listen callers compress with h2rev2.CompressHandler.
+	DisableCompression bool
+}
+
+// Listen returns listener accepting visitor connections of the connection
+// and its public URL. Tunnel is reconnected, to another gateway if needed,
+// until the listener is closed or the context is done.
+func Listen(ctx context.Context, opts Options) (net.Listener, *url.URL, error) {
+	controllerURL, err := url.Parse(opts.ControllerURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = utilstrings.GetRandomName()
+	}
+	if opts.DrainTimeout == 0 {
+		opts.DrainTimeout = 30 * time.Second
+	}
+
+	dialer, err := utilproxy.NewDialer(utilproxy.Config{
+		URL:           opts.ProxyURL,
+		NoProxy:       opts.NoProxy,
+		Authorization: opts.ProxyAuthorization,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	httpClient := utiltunnel.APIHTTPClient(tlsConfig(opts.TLSConfig), dialer)
+
+	conn, err := connection(ctx, client.NewClient(controllerURL, opts.AccessKey, httpClient), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicURL, err := url.Parse(conn.Hostname)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	l := &listener{
+		opts: opts,
+		conn: conn,
+		addr: addr(publicURL.String()),
+		// connector client authenticates with the connection token
+		gatewayClient: connectorclient.NewClient(controllerURL, conn.Token, httpClient),
+		tunnelClient:  utiltunnel.Client(tlsConfig(opts.TLSConfig), dialer),
+		connc:         make(chan net.Conn),
+		done:          ctx.Done(),
+		cancel:        cancel,
+	}
+
+	// first tunnel is connected right away to report configuration errors
+	tl, err := l.connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	go l.run(ctx, tl)
+
+	return l, publicURL, nil
+}
+
+// Serve serves the handler on the connection until the context is done, then
+// waits up to DrainTimeout for requests in flight. Use Listen to learn the
+// public URL of the connection.
+func Serve(ctx context.Context, handler http.Handler, opts Options) error {
+	l, publicURL, err := Listen(ctx, opts)
+	if err != nil {
+		return err
+	}
+	klog.V(2).Infof("serving on %s", publicURL)
+
+	if !opts.DisableCompression {
+		handler = h2rev2.CompressHandler(handler, nil)
+	}
+	server := &http.Server{Handler: handler}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(l)
+	}()
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+	}
+	// listener closes together with ctx, so server may stop serving first.
+	// Requests in flight still finish before the tunnel closes.
+	if ctx.Err() != nil {
+		drainCtx, cancel := context.WithTimeout(context.Background(), l.(*listener).opts.DrainTimeout)
+		defer cancel()
+		if err = server.Shutdown(drainCtx); err != nil {
+			klog.V(2).Infof("closing %s with requests in flight: %v", publicURL, err)
+			err = server.Close()
+		}
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// connection returns the connection with the name, creating it if missing
+func connection(ctx context.Context, c client.Client, opts Options) (*api.Connection, error) {
+	conns, err := c.ListConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range conns.Items {
+		if conn.Name == opts.Name {
+			return &conn, nil
+		}
+	}
+
+	klog.V(2).Infof("creating connection %s", opts.Name)
+	conn, err := c.CreateConnection(ctx, api.Connection{
+		Name:   opts.Name,
+		Secure: opts.Secure,
+		TTL:    opts.TTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if conn.Token == "" {
+		return nil, fmt.Errorf("failed to create connection %s", opts.Name)
+	}
+	return conn, nil
+}
+
+var _ net.Listener = (*listener)(nil)
+
+// listener accepts visitor connections of the tunnel connected at the moment
+type listener struct {
+	opts          Options
+	conn          *api.Connection
+	addr          addr
+	gatewayClient connectorclient.Client
+	tunnelClient  *http.Client
+
+	connc chan net.Conn
+	// done is closed once the listener is closed
+	done   <-chan struct{}
+	cancel context.CancelFunc
+
+	mu sync.Mutex // guards below
+	// nextGatewayURL is used instead of asking API on the next reconnect
+	nextGatewayURL string
+	// previous is the tunnel the gateway asked to leave, drained once its
+	// replacement is attached
+	previous *h2rev2.Listener
+}
+
+// run serves the tunnel and reconnects it with backoff until the context is
+// done
+func (l *listener) run(ctx context.Context, tl *h2rev2.Listener) {
+	utiltunnel.Until(ctx, func() {
+		if tl == nil {
+			var err error
+			tl, err = l.connect(ctx)
+			if err != nil {
+				klog.Errorf("failed to connect tunnel of connection %s: %v", l.conn.Name, err)
+				return
+			}
+		}
+		l.serve(ctx, tl)
+		tl = nil
+	})
+
+	if previous := l.takePrevious(); previous != nil {
+		l.drain(previous)
+	}
+}
+
+// connect creates the tunnel to the gateway API assigned to the connection
+func (l *listener) connect(ctx context.Context) (*h2rev2.Listener, error) {
+	l.mu.Lock()
+	gatewayURL := l.nextGatewayURL
+	l.nextGatewayURL = ""
+	l.mu.Unlock()
+
+	if gatewayURL == "" {
+		gateway, err := l.gatewayClient.GetConnectionGateway(ctx, api.Connection{ID: l.conn.ID})
+		if err != nil {
+			return nil, err
+		}
+		gatewayURL = gateway.Hostname
+		if gatewayURL == "" && len(gateway.Candidates) > 0 {
+			gatewayURL = gateway.Candidates[0].Hostname
+		}
+		if gatewayURL == "" {
+			return nil, fmt.Errorf("no gateway available for connection %s", l.conn.Name)
+		}
+	}
+
+	klog.V(4).Infof("connecting tunnel of connection %s to %s", l.conn.Name, gatewayURL)
+	tl, err := h2rev2.NewListener(l.tunnelClient, gatewayURL+utiltunnel.GatewayPath, l.conn.Token, h2rev2.ListenerOptions{
+		Liveness:  l.opts.Liveness,
+		Transport: l.opts.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tl.OnAction(func(action api.ConnectionAction) {
+		l.handleAction(tl, action)
+	})
+	if previous := l.takePrevious(); previous != nil {
+		go l.drain(previous)
+	}
+	return tl, nil
+}
+
+// serve passes connections of the tunnel to Accept until the tunnel is
+// closed, the context is done or the gateway goes away
+func (l *listener) serve(ctx context.Context, tl *h2rev2.Listener) {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		// connections are passed also while the tunnel drains
+		for {
+			c, err := tl.Accept()
+			if err != nil {
+				klog.V(2).Infof("tunnel of connection %s closed: %v", l.conn.Name, err)
+				return
+			}
+			select {
+			case l.connc <- c:
+			case <-l.done:
+				c.Close()
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		l.drain(tl)
+	case <-tl.GoAway():
+		// tunnel keeps serving until the next one is attached to another gateway
+		klog.V(2).Infof("gateway of connection %s is going away, moving tunnel", l.conn.Name)
+		l.mu.Lock()
+		l.previous = tl
+		l.mu.Unlock()
+	}
+}
+
+// drain closes the tunnel once connections it passed are closed, or the
+// drain timeout passes
+func (l *listener) drain(tl *h2rev2.Listener) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.DrainTimeout)
+	defer cancel()
+	if err := tl.Shutdown(ctx); err != nil {
+		klog.V(2).Infof("closing tunnel of connection %s with connections open: %v", l.conn.Name, err)
+	}
+}
+
+// takePrevious returns the tunnel the gateway asked to leave, if any
+func (l *listener) takePrevious() *h2rev2.Listener {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := l.previous
+	l.previous = nil
+	return previous
+}
+
+// handleAction executes remote control command sent by the gateway and
+// acknowledges it. Only actions relevant to the tunnel are supported.
+func (l *listener) handleAction(tl *h2rev2.Listener, action api.ConnectionAction) {
+	logger := klog.Background().WithValues("connection", l.conn.Name)
+
+	utiltunnel.HandleAction(logger, tl, action, func(action api.ConnectionAction, result *api.ConnectionAction) (func(), error) {
+		switch action.Type {
+		case api.ConnectionActionDisconnect:
+			return func() { l.Close() }, nil
+		case api.ConnectionActionReconnect:
+			if !l.opts.AllowReconnect {
+				return nil, fmt.Errorf("reconnect actions are not allowed")
+			}
+			l.mu.Lock()
+			l.nextGatewayURL = action.GatewayURL
+			l.mu.Unlock()
+			return func() { tl.Close() }, nil
+		default:
+			return nil, utiltunnel.NotSupportedError(action.Type)
+		}
+	})
+}
+
+// Accept waits for the next visitor connection
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.connc:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the tunnel and stops reconnecting it
+func (l *listener) Close() error {
+	l.cancel()
+	return nil
+}
+
+// Addr returns the public URL of the connection
+func (l *listener) Addr() net.Addr { return l.addr }
+
+// addr is the public URL of the connection
+type addr string
+
+func (a addr) Network() string { return "faros" }
+func (a addr) String() string  { return string(a) }
+
+func tlsConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		return &tls.Config{}
+	}
+	return config.Clone()
+}
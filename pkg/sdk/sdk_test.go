@@ -0,0 +1,162 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+)
+
+// fakeController serves connections API and the gateway of the connections
+type fakeController struct {
+	*httptest.Server
+	pool *h2rev2.ReversePool
+
+	mu    sync.Mutex
+	conns []api.Connection
+}
+
+func newFakeController(t *testing.T) *fakeController {
+	c := &fakeController{
+		pool: h2rev2.NewReversePool(h2rev2.PoolOptions{}),
+	}
+	c.Server = httptest.NewUnstartedServer(http.HandlerFunc(c.serveHTTP))
+	c.EnableHTTP2 = true
+	c.StartTLS()
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *fakeController) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/v1alpha1/proxy/") {
+		c.pool.ServeHTTP(w, r)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/v1alpha1/connections" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(api.ConnectionList{Items: c.conns})
+	case r.URL.Path == "/api/v1alpha1/connections" && r.Method == http.MethodPost:
+		var conn api.Connection
+		json.NewDecoder(r.Body).Decode(&conn)
+		conn.ID = "id-" + conn.Name
+		conn.Token = "token-" + conn.Name
+		conn.Hostname = "https://" + conn.Name + ".faros.sh"
+		c.conns = append(c.conns, conn)
+		json.NewEncoder(w).Encode(conn)
+	case strings.HasPrefix(r.URL.Path, "/api/v1alpha1/connection-gateways/"):
+		json.NewEncoder(w).Encode(api.ConnectionGateway{Hostname: c.URL})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *fakeController) options(name string) Options {
+	return Options{
+		ControllerURL: c.URL,
+		AccessKey:     "access-key",
+		Name:          name,
+		TLSConfig:     c.Client().Transport.(*http.Transport).TLSClientConfig.Clone(),
+		Transport:     h2rev2.TransportHTTP2,
+	}
+}
+
+func (c *fakeController) get(t *testing.T, path string) string {
+	resp, err := c.Client().Get(c.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestListen(t *testing.T) {
+	controller := newFakeController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := controller.options("app")
+	opts.AllowReconnect = true
+	l, publicURL, err := Listen(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.faros.sh", publicURL.String())
+	assert.Equal(t, "https://app.faros.sh", l.Addr().String())
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.URL.Path))
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	assert.Equal(t, "hello from /app", controller.get(t, "/api/v1alpha1/proxy/token-app/app"))
+
+	t.Run("should reuse connection with the name", func(t *testing.T) {
+		l, _, err := Listen(ctx, opts)
+		require.NoError(t, err)
+		defer l.Close()
+
+		controller.mu.Lock()
+		defer controller.mu.Unlock()
+		assert.Len(t, controller.conns, 1)
+	})
+
+	t.Run("should reconnect closed tunnel", func(t *testing.T) {
+		require.NoError(t, controller.pool.SendAction("token-app", api.ConnectionAction{
+			ID:   "action",
+			Type: api.ConnectionActionReconnect,
+		}))
+		assert.Eventually(t, func() bool {
+			return controller.pool.Replicas("token-app") == 1 &&
+				controller.get(t, "/api/v1alpha1/proxy/token-app/again") == "hello from /again"
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("should stop accepting once closed", func(t *testing.T) {
+		require.NoError(t, l.Close())
+		_, err := l.Accept()
+		assert.ErrorIs(t, err, net.ErrClosed)
+	})
+}
+
+func TestServe(t *testing.T) {
+	controller := newFakeController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("finished"))
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, handler, controller.options("app")) }()
+	require.Eventually(t, func() bool {
+		return controller.pool.Replicas("token-app") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	body := make(chan string, 1)
+	go func() { body <- controller.get(t, "/api/v1alpha1/proxy/token-app/slow") }()
+	<-started
+
+	// request in flight finishes once serving stops
+	cancel()
+	assert.Equal(t, "finished", <-body)
+	assert.NoError(t, <-served)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+)
+
+// createConnectionAction stores the action for the gateway owning the tunnel
+// to send it to the connector. Connector acknowledges it asynchronously, so
+// callers poll the action for its status.
+func (s *Service) createConnectionAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	request := &api.ConnectionAction{}
+	err = utilhttp.Read(r, request)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     vars["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	action := models.ConnectionAction{
+		ConnectionID: connectionRef.ID,
+		Type:         models.ConnectionActionType(vars["action"]),
+	}
+	switch action.Type {
+	case models.ConnectionActionDisconnect, models.ConnectionActionReloadRoutes, models.ConnectionActionDiagnostics:
+	case models.ConnectionActionReconnect:
+		if request.GatewayURL != "" {
+			if err := validateActionURL(request.GatewayURL); err != nil {
+				utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("gateway url: %w", err), nil)
+				return
+			}
+		}
+		action.GatewayURL = request.GatewayURL
+	case models.ConnectionActionSetDownstream:
+		if err := validateActionURL(request.DownstreamURL); err != nil {
+			utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("downstream url: %w", err), nil)
+			return
+		}
+		action.DownstreamURL = request.DownstreamURL
+	default:
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("action '%s' is not supported", vars["action"]), nil)
+		return
+	}
+
+	// nobody would pick the action up
+	if connectionRef.State != models.StateConnected {
+		utilhttp.WriteErrorConflictWithReason(w, fmt.Errorf("connection is not connected"), nil)
+		return
+	}
+
+	actionCreated, err := s.store.CreateConnectionAction(ctx, action)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	utilhttp.Respond(w, actionToAPI(*actionCreated))
+}
+
+func (s *Service) getConnectionAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	vars := mux.Vars(r)
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     vars["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	action, err := s.store.GetConnectionAction(ctx, models.ConnectionAction{
+		ID:           vars["action"],
+		ConnectionID: connectionRef.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("action not found"), store.ErrRecordNotFound)
+		return
+	}
+
+	utilhttp.Respond(w, actionToAPI(*action))
+}
+
+func validateActionURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("'%s' must be absolute URL", value)
+	}
+	return nil
+}
+
+func actionToAPI(action models.ConnectionAction) api.ConnectionAction {
+	return api.ConnectionAction{
+		ID:            action.ID,
+		Type:          api.ConnectionActionType(action.Type),
+		CreatedAt:     action.CreatedAt,
+		UpdatedAt:     action.UpdatedAt,
+		GatewayURL:    action.GatewayURL,
+		DownstreamURL: action.DownstreamURL,
+		Status:        api.ConnectionActionStatus(action.Status),
+		Message:       action.Message,
+		Diagnostics:   action.Diagnostics,
+	}
+}
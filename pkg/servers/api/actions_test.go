@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/servers/api/auth"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// fakeAuthenticator authenticates every request as the user
+type fakeAuthenticator struct {
+	auth.Authenticator
+	user *models.User
+}
+
+func (a *fakeAuthenticator) Authenticate(r *http.Request) (bool, *models.User, error) {
+	return true, a.user, nil
+}
+
+func TestCreateConnectionAction(t *testing.T) {
+	user := &models.User{ID: "user1"}
+	connected := &models.Connection{ID: "conn1", UserID: "user1", State: models.StateConnected}
+
+	for _, tc := range []struct {
+		name           string
+		action         string
+		body           string
+		conn           *models.Connection
+		expectedStatus int
+		expected       *models.ConnectionAction
+	}{
+		{
+			name:           "reconnect",
+			action:         "reconnect",
+			body:           `{"gatewayUrl":"https://eu.faros.sh"}`,
+			conn:           connected,
+			expectedStatus: http.StatusOK,
+			expected:       &models.ConnectionAction{ConnectionID: "conn1", Type: models.ConnectionActionReconnect, GatewayURL: "https://eu.faros.sh"},
+		},
+		{
+			name:           "set downstream",
+			action:         "set-downstream",
+			body:           `{"downstreamUrl":"http://localhost:8080"}`,
+			conn:           connected,
+			expectedStatus: http.StatusOK,
+			expected:       &models.ConnectionAction{ConnectionID: "conn1", Type: models.ConnectionActionSetDownstream, DownstreamURL: "http://localhost:8080"},
+		},
+		{
+			name:           "set downstream without url",
+			action:         "set-downstream",
+			body:           `{}`,
+			conn:           connected,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "reconnect to relative url",
+			action:         "reconnect",
+			body:           `{"gatewayUrl":"eu.faros.sh"}`,
+			conn:           connected,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported",
+			action:         "restart",
+			body:           `{}`,
+			conn:           connected,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "not connected",
+			action:         "disconnect",
+			body:           `{}`,
+			conn:           &models.Connection{ID: "conn1", UserID: "user1"},
+			expectedStatus: http.StatusConflict,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			st := store.NewMockStore(ctrl)
+			st.EXPECT().GetConnection(gomock.Any(), models.Connection{ID: "conn1", UserID: "user1"}).Return(tc.conn, nil)
+			if tc.expected != nil {
+				st.EXPECT().CreateConnectionAction(gomock.Any(), *tc.expected).DoAndReturn(
+					func(ctx context.Context, action models.ConnectionAction) (*models.ConnectionAction, error) {
+						action.ID = "action1"
+						action.Status = models.ConnectionActionPending
+						return &action, nil
+					})
+			}
+			s := &Service{authenticator: &fakeAuthenticator{user: user}, store: st}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1alpha1/connections/conn1/actions/"+tc.action, strings.NewReader(tc.body))
+			r = mux.SetURLVars(r, map[string]string{"connection": "conn1", "action": tc.action})
+			w := httptest.NewRecorder()
+			s.createConnectionAction(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expected == nil {
+				return
+			}
+			var action api.ConnectionAction
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&action))
+			assert.Equal(t, "action1", action.ID)
+			assert.Equal(t, api.ConnectionActionPending, action.Status)
+		})
+	}
+}
+
+func TestGetConnectionAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := store.NewMockStore(ctrl)
+	st.EXPECT().GetConnection(gomock.Any(), models.Connection{ID: "conn1", UserID: "user1"}).Return(&models.Connection{ID: "conn1"}, nil).Times(2)
+	st.EXPECT().GetConnectionAction(gomock.Any(), models.ConnectionAction{ID: "action1", ConnectionID: "conn1"}).Return(&models.ConnectionAction{
+		ID:           "action1",
+		ConnectionID: "conn1",
+		Type:         models.ConnectionActionDiagnostics,
+		Status:       models.ConnectionActionSucceeded,
+		Diagnostics:  map[string]string{"rtt": "10ms"},
+	}, nil)
+	st.EXPECT().GetConnectionAction(gomock.Any(), models.ConnectionAction{ID: "missing", ConnectionID: "conn1"}).Return(nil, store.ErrRecordNotFound)
+	s := &Service{authenticator: &fakeAuthenticator{user: &models.User{ID: "user1"}}, store: st}
+
+	get := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/connections/conn1/actions/"+id, nil)
+		r = mux.SetURLVars(r, map[string]string{"connection": "conn1", "action": id})
+		w := httptest.NewRecorder()
+		s.getConnectionAction(w, r)
+		return w
+	}
+
+	w := get("action1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var action api.ConnectionAction
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&action))
+	assert.Equal(t, api.ConnectionAction{
+		ID:          "action1",
+		Type:        api.ConnectionActionDiagnostics,
+		Status:      api.ConnectionActionSucceeded,
+		Diagnostics: map[string]string{"rtt": "10ms"},
+	}, action)
+
+	w = get("missing")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
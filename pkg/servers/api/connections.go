@@ -3,8 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"net/url"
-	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -12,9 +10,7 @@ import (
 
 	"github.com/faroshq/faros-ingress/pkg/api"
 	"github.com/faroshq/faros-ingress/pkg/models"
-	utilhash "github.com/faroshq/faros-ingress/pkg/util/hash"
 	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
-	utilpassword "github.com/faroshq/faros-ingress/pkg/util/password"
 )
 
 func (s *Service) getConnection(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +44,27 @@ func (s *Service) getConnection(w http.ResponseWriter, r *http.Request) {
 		Hostname: connectionRef.Hostname,
 		Secure:   connectionRef.Secure,
 		LastUsed: connectionRef.LastUsedAt,
+		State:    api.ConnectionState(connectionRef.State),
+		Replicas: connectionRef.Replicas,
+
+		AuthFailures:    connectionRef.AuthFailures,
+		AuthLockouts:    connectionRef.AuthLockouts,
+		AuthLockedUntil: connectionRef.AuthLockedUntil,
+
+		RateLimit:   connectionRef.RateLimit,
+		RateBurst:   connectionRef.RateBurst,
+		MaxInFlight: connectionRef.MaxInFlight,
+
+		HoldTimeout:      connectionRef.HoldTimeout,
+		HoldMaxBodyBytes: connectionRef.HoldMaxBodyBytes,
+
+		Agent: agentToAPI(connectionRef.Agent),
+	}
+	for _, credential := range connectionRef.Credentials {
+		result.Credentials = append(result.Credentials, credentialToAPI(credential))
+	}
+	for _, domain := range connectionRef.Domains {
+		result.Domains = append(result.Domains, domainToAPI(domain, connectionRef))
 	}
 
 	utilhttp.Respond(w, result)
@@ -69,8 +86,9 @@ func (s *Service) listConnections(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result := api.ConnectionList{}
-	for _, connectionRef := range connectionsRef {
-		result.Items = append(result.Items, api.Connection{
+	for i := range connectionsRef {
+		connectionRef := &connectionsRef[i]
+		item := api.Connection{
 			ID:       connectionRef.ID,
 			Name:     connectionRef.Name,
 			LastUsed: connectionRef.LastUsedAt,
@@ -79,7 +97,25 @@ func (s *Service) listConnections(w http.ResponseWriter, r *http.Request) {
 			Hostname: connectionRef.Hostname,
 			Secure:   connectionRef.Secure,
 			State:    api.ConnectionState(connectionRef.State),
-		})
+			Replicas: connectionRef.Replicas,
+
+			AuthFailures:    connectionRef.AuthFailures,
+			AuthLockouts:    connectionRef.AuthLockouts,
+			AuthLockedUntil: connectionRef.AuthLockedUntil,
+
+			RateLimit:   connectionRef.RateLimit,
+			RateBurst:   connectionRef.RateBurst,
+			MaxInFlight: connectionRef.MaxInFlight,
+
+			HoldTimeout:      connectionRef.HoldTimeout,
+			HoldMaxBodyBytes: connectionRef.HoldMaxBodyBytes,
+
+			Agent: agentToAPI(connectionRef.Agent),
+		}
+		for _, domain := range connectionRef.Domains {
+			item.Domains = append(item.Domains, domainToAPI(domain, connectionRef))
+		}
+		result.Items = append(result.Items, item)
 	}
 
 	utilhttp.Respond(w, result)
@@ -129,58 +165,48 @@ func (s *Service) createConnection(w http.ResponseWriter, r *http.Request) {
 		Secure:     request.Secure,
 		LastUsedAt: s.clock.Now(),
 		State:      models.StateDisconnected,
-	}
 
-	// clean up hostname
-	request.Hostname = strings.Replace(request.Hostname, "https://", "", 1)
-	request.Hostname = strings.Replace(request.Hostname, "http://", "", 1)
-	request.Hostname = strings.Replace(request.Hostname, s.config.HostnameSuffix, "", 1)
+		RateLimit:   request.RateLimit,
+		RateBurst:   request.RateBurst,
+		MaxInFlight: request.MaxInFlight,
 
-	// if hostname is not provided, generate one
-	if request.Hostname == "" {
-		request.Hostname = fmt.Sprintf("https://%s.%s", utilhash.GetHash(uuid.New().String()), s.config.HostnameSuffix)
-	} else {
-		request.Hostname = fmt.Sprintf("https://%s.%s", request.Hostname, s.config.HostnameSuffix)
-		_, err := url.Parse(request.Hostname)
-		if err != nil {
-			utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("hostname is not valid"), err)
-			return
-		}
+		HoldTimeout:      request.HoldTimeout,
+		HoldMaxBodyBytes: request.HoldMaxBodyBytes,
 	}
 
-	if !strings.HasSuffix(request.Hostname, s.config.HostnameSuffix) {
-		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("hostname '%s' must end with '%s'", request.Hostname, s.config.HostnameSuffix), nil)
+	hostname, err := s.normalizeHostname(request.Hostname)
+	if err != nil {
+		utilhttp.WriteErrorBadRequestWithReason(w, err, nil)
 		return
 	}
 
-	_, err = s.store.GetConnection(ctx, models.Connection{
-		Hostname: connection.Hostname,
-	})
-	if err == nil {
+	taken, err := s.hostnameTaken(ctx, user.ID, "", hostname)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+	if taken {
 		utilhttp.WriteErrorConflictWithReason(w, fmt.Errorf("hostname already taken"), nil)
 		return
 	}
 
 	var username, password string
 	if request.Secure {
-		if request.Username == "" {
-			username = "faros"
-		} else {
-			username = request.Username
-		}
-
-		if request.Password == "" {
-			password = uuid.New().String()
-		} else {
-			password = request.Password
-		}
-
-		hashedPassword, err := utilpassword.GeneratePasswordHash([]byte(username + ":" + password))
+		credential, secrets, err := newCredential(&api.ConnectionCredential{
+			Name:     defaultCredentialName,
+			Type:     api.CredentialTypeBasicAuth,
+			Username: request.Username,
+			Password: request.Password,
+		})
 		if err != nil {
 			utilhttp.WriteErrorInternalServerError(w, err)
 			return
 		}
-		connection.BasicAuthHash = hashedPassword
+		username = credential.Username
+		password = secrets.Password
+
+		connection.Credentials = []models.ConnectionCredential{*credential}
+		connection.BasicAuthHash = []byte{}
 		connection.Secure = true
 	} else {
 		connection.BasicAuthHash = []byte{}
@@ -189,7 +215,7 @@ func (s *Service) createConnection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	connection.GatewayURL = s.config.DefaultGateway
-	connection.Hostname = request.Hostname
+	connection.Hostname = hostname
 	connection.TTL = request.TTL
 
 	connectionCreated, err := s.store.CreateConnection(ctx, connection)
@@ -243,29 +269,66 @@ func (s *Service) updateConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var username, password string
-	var hashedPassword []byte
-
-	if request.Username != "" {
-		username = request.Username
-	}
-	if request.Password != "" {
-		password = request.Password
-	}
 	if request.Hostname != "" {
-		current.Hostname = request.Hostname
+		hostname, err := s.normalizeHostname(request.Hostname)
+		if err != nil {
+			utilhttp.WriteErrorBadRequestWithReason(w, err, nil)
+			return
+		}
+
+		if hostname != current.Hostname {
+			taken, err := s.hostnameTaken(ctx, user.ID, current.ID, hostname)
+			if err != nil {
+				utilhttp.WriteErrorInternalServerError(w, err)
+				return
+			}
+			if taken {
+				utilhttp.WriteErrorConflictWithReason(w, fmt.Errorf("hostname already taken"), nil)
+				return
+			}
+			current.Hostname = hostname
+		}
 	}
 	if request.Secure != current.Secure {
 		current.Secure = request.Secure
 	}
+	if request.RateLimit != 0 {
+		current.RateLimit = request.RateLimit
+	}
+	if request.RateBurst != 0 {
+		current.RateBurst = request.RateBurst
+	}
+	if request.MaxInFlight != 0 {
+		current.MaxInFlight = request.MaxInFlight
+	}
+	if request.HoldTimeout != 0 {
+		current.HoldTimeout = request.HoldTimeout
+	}
+	if request.HoldMaxBodyBytes != 0 {
+		current.HoldMaxBodyBytes = request.HoldMaxBodyBytes
+	}
 
+	// username and password replace the default credential, other named
+	// credentials are managed via the credentials endpoints
 	if request.Username != "" && request.Password != "" {
-		hashedPassword, err = utilpassword.GeneratePasswordHash([]byte(username + ":" + password))
+		credential, _, err := newCredential(&api.ConnectionCredential{
+			Name:     defaultCredentialName,
+			Type:     api.CredentialTypeBasicAuth,
+			Username: request.Username,
+			Password: request.Password,
+		})
+		if err != nil {
+			utilhttp.WriteErrorInternalServerError(w, err)
+			return
+		}
+		credential.ConnectionID = current.ID
+
+		_, err = s.store.ReplaceConnectionCredential(ctx, *credential)
 		if err != nil {
 			utilhttp.WriteErrorInternalServerError(w, err)
 			return
 		}
-		current.BasicAuthHash = hashedPassword
+		current.BasicAuthHash = []byte{}
 		current.Secure = true
 	}
 
@@ -283,6 +346,13 @@ func (s *Service) updateConnection(w http.ResponseWriter, r *http.Request) {
 		Password: request.Password,
 		Secure:   connectionUpdated.Secure,
 		State:    api.ConnectionState(connectionUpdated.State),
+
+		RateLimit:   connectionUpdated.RateLimit,
+		RateBurst:   connectionUpdated.RateBurst,
+		MaxInFlight: connectionUpdated.MaxInFlight,
+
+		HoldTimeout:      connectionUpdated.HoldTimeout,
+		HoldMaxBodyBytes: connectionUpdated.HoldMaxBodyBytes,
 	})
 }
 
@@ -318,3 +388,42 @@ func (s *Service) deleteConnection(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// agentToAPI converts reported agent information, returning nil if connector never reported
+func agentToAPI(agent models.ConnectionAgent) *api.ConnectionAgent {
+	if agent.ReportedAt.IsZero() {
+		return nil
+	}
+
+	result := &api.ConnectionAgent{
+		Version:       agent.Version,
+		Hostname:      agent.Hostname,
+		OS:            agent.OS,
+		StartedAt:     agent.StartedAt,
+		DownstreamURL: agent.DownstreamURL,
+		ReportedAt:    agent.ReportedAt,
+	}
+	for _, gateway := range agent.Gateways {
+		result.Gateways = append(result.Gateways, api.ConnectionAgentGateway{
+			Hostname: gateway.Hostname,
+			Latency:  gateway.Latency,
+		})
+	}
+	for _, condition := range agent.Conditions {
+		result.Conditions = append(result.Conditions, api.ConnectionCondition{
+			Type:               condition.Type,
+			Status:             api.ConditionStatus(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime,
+		})
+	}
+	if agent.Compression != nil {
+		result.Compression = &api.ConnectionAgentCompression{
+			UncompressedBytes: agent.Compression.UncompressedBytes,
+			CompressedBytes:   agent.Compression.CompressedBytes,
+			Ratio:             agent.Compression.Ratio,
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestConnectionDomains(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := models.Connection{
+		ID:       "conn1",
+		UserID:   "user1",
+		Hostname: "app.apps.faros.sh",
+		Domains: []models.ConnectionDomain{
+			{ID: "domain1", Hostname: "app.example.com", Verified: true},
+			{ID: "domain2", Hostname: "pending.example.com", Token: "token"},
+		},
+	}
+	expected := []api.ConnectionDomain{
+		{ID: "domain1", Hostname: "app.example.com", Verified: true},
+		{ID: "domain2", Hostname: "pending.example.com", Challenge: api.DomainChallenge{
+			TXTName:     api.DomainChallengeRecordPrefix + "pending.example.com",
+			TXTValue:    "token",
+			CNAMETarget: "app.apps.faros.sh",
+		}},
+	}
+
+	st := store.NewMockStore(ctrl)
+	s := &Service{authenticator: &fakeAuthenticator{user: &models.User{ID: "user1"}}, store: st}
+
+	t.Run("should get connection with domains", func(t *testing.T) {
+		st.EXPECT().GetConnection(gomock.Any(), models.Connection{ID: "conn1", UserID: "user1"}).Return(&conn, nil)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/connections/conn1", nil)
+		r = mux.SetURLVars(r, map[string]string{"connection": "conn1"})
+		w := httptest.NewRecorder()
+		s.getConnection(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result api.Connection
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		assert.Equal(t, expected, result.Domains)
+	})
+
+	t.Run("should list connections with domains", func(t *testing.T) {
+		st.EXPECT().ListConnections(gomock.Any(), models.Connection{UserID: "user1"}).Return([]models.Connection{conn}, nil)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/connections", nil)
+		w := httptest.NewRecorder()
+		s.listConnections(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result api.ConnectionList
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		require.Len(t, result.Items, 1)
+		assert.Equal(t, expected, result.Items[0].Domains)
+	})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+	utilpassword "github.com/faroshq/faros-ingress/pkg/util/password"
+)
+
+const defaultCredentialName = "default"
+
+func (s *Service) listConnectionCredentials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     mux.Vars(r)["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	result := api.ConnectionCredentialList{}
+	for _, credential := range connectionRef.Credentials {
+		result.Items = append(result.Items, credentialToAPI(credential))
+	}
+
+	utilhttp.Respond(w, result)
+}
+
+func (s *Service) createConnectionCredential(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	request := &api.ConnectionCredential{}
+	err = utilhttp.Read(r, request)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     mux.Vars(r)["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	if request.Name == "" {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("credential name is required"), nil)
+		return
+	}
+
+	// name is unique per connection
+	for _, credential := range connectionRef.Credentials {
+		if credential.Name == request.Name {
+			utilhttp.WriteErrorConflictWithReason(w, fmt.Errorf("credential already exists"), nil)
+			return
+		}
+	}
+
+	credential, result, err := newCredential(request)
+	if err != nil {
+		utilhttp.WriteErrorBadRequestWithReason(w, err, nil)
+		return
+	}
+	credential.ConnectionID = connectionRef.ID
+
+	credentialCreated, err := s.store.CreateConnectionCredential(ctx, *credential)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	// credentials only make sense on secure connections
+	if !connectionRef.Secure {
+		connectionRef.Secure = true
+		_, err = s.store.UpdateConnection(ctx, *connectionRef)
+		if err != nil {
+			utilhttp.WriteErrorInternalServerError(w, err)
+			return
+		}
+	}
+
+	created := credentialToAPI(*credentialCreated)
+	created.Password = result.Password
+	created.APIKey = result.APIKey
+
+	utilhttp.Respond(w, created)
+}
+
+func (s *Service) deleteConnectionCredential(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	vars := mux.Vars(r)
+	credentialID := vars["credential"]
+	if credentialID == "" {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("credential id is required"), fmt.Errorf("credential id is required"))
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     vars["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	var found bool
+	for _, credential := range connectionRef.Credentials {
+		if credential.ID == credentialID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("credential not found"), store.ErrRecordNotFound)
+		return
+	}
+
+	if err := s.store.DeleteConnectionCredential(ctx, models.ConnectionCredential{ID: credentialID}); err != nil {
+		klog.Error(err)
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// newCredential builds the credential model from the request, generating
+// secrets which were not provided. Returned api object carries the plain
+// secrets so they can be shown to the user once.
+func newCredential(request *api.ConnectionCredential) (*models.ConnectionCredential, *api.ConnectionCredential, error) {
+	credential := &models.ConnectionCredential{
+		Name:      request.Name,
+		ExpiresAt: request.ExpiresAt,
+	}
+	result := &api.ConnectionCredential{}
+
+	switch request.Type {
+	case api.CredentialTypeBasicAuth, "":
+		credential.Type = models.CredentialTypeBasicAuth
+		credential.Username = request.Username
+		if credential.Username == "" {
+			credential.Username = "faros"
+		}
+
+		result.Password = request.Password
+		if result.Password == "" {
+			result.Password = uuid.New().String()
+		}
+
+		hash, err := utilpassword.GeneratePasswordHash([]byte(credential.Username + ":" + result.Password))
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Hash = hash
+	case api.CredentialTypeAPIKey:
+		credential.Type = models.CredentialTypeAPIKey
+		credential.Header = request.Header
+		if credential.Header == "" {
+			credential.Header = api.DefaultAPIKeyHeader
+		}
+
+		result.APIKey = request.APIKey
+		if result.APIKey == "" {
+			result.APIKey = uuid.New().String()
+		}
+		credential.Hash = utilpassword.GenerateKeyHash([]byte(result.APIKey))
+	default:
+		return nil, nil, fmt.Errorf("credential type '%s' is not supported", request.Type)
+	}
+
+	return credential, result, nil
+}
+
+func credentialToAPI(credential models.ConnectionCredential) api.ConnectionCredential {
+	return api.ConnectionCredential{
+		ID:        credential.ID,
+		Name:      credential.Name,
+		Type:      api.CredentialType(credential.Type),
+		CreatedAt: credential.CreatedAt,
+		LastUsed:  credential.LastUsedAt,
+		ExpiresAt: credential.ExpiresAt,
+		Username:  credential.Username,
+		Header:    credential.Header,
+	}
+}
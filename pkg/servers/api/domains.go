@@ -0,0 +1,203 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+)
+
+func (s *Service) listConnectionDomains(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     mux.Vars(r)["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	result := api.ConnectionDomainList{}
+	for _, domain := range connectionRef.Domains {
+		result.Items = append(result.Items, domainToAPI(domain, connectionRef))
+	}
+
+	utilhttp.Respond(w, result)
+}
+
+func (s *Service) createConnectionDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	request := &api.ConnectionDomain{}
+	err = utilhttp.Read(r, request)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     mux.Vars(r)["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	// clean up hostname
+	hostname := strings.ToLower(request.Hostname)
+	hostname = strings.Replace(hostname, "https://", "", 1)
+	hostname = strings.Replace(hostname, "http://", "", 1)
+	hostname = strings.TrimSuffix(hostname, ".")
+
+	if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 || !strings.Contains(hostname, ".") {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("domain '%s' is not valid", request.Hostname), nil)
+		return
+	}
+
+	// our own domains are managed by hostnames of connections
+	if strings.HasSuffix(hostname, s.config.HostnameSuffix) {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("domain '%s' must not end with '%s'", hostname, s.config.HostnameSuffix), nil)
+		return
+	}
+
+	existing, err := s.store.GetConnectionDomain(ctx, models.ConnectionDomain{
+		Hostname: hostname,
+	})
+	if err == nil {
+		// claims never verified do not hold the domain forever
+		if !s.domainVerifier.expired(*existing) {
+			utilhttp.WriteErrorConflictWithReason(w, fmt.Errorf("domain already taken"), nil)
+			return
+		}
+		if err := s.store.DeleteConnectionDomain(ctx, *existing); err != nil {
+			utilhttp.WriteErrorInternalServerError(w, err)
+			return
+		}
+	}
+
+	domainCreated, err := s.store.CreateConnectionDomain(ctx, models.ConnectionDomain{
+		ConnectionID: connectionRef.ID,
+		Hostname:     hostname,
+		Token:        uuid.New().String(),
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	utilhttp.Respond(w, domainToAPI(*domainCreated, connectionRef))
+}
+
+// verifyConnectionDomain checks domain challenge right away instead of
+// waiting for the background verifier.
+func (s *Service) verifyConnectionDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	vars := mux.Vars(r)
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     vars["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	domain, ok := findDomain(connectionRef, vars["domain"])
+	if !ok {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("domain not found"), store.ErrRecordNotFound)
+		return
+	}
+
+	if !domain.Verified {
+		domain, err = s.domainVerifier.verify(ctx, *domain, connectionRef)
+		if err != nil {
+			utilhttp.WriteErrorInternalServerError(w, err)
+			return
+		}
+	}
+
+	utilhttp.Respond(w, domainToAPI(*domain, connectionRef))
+}
+
+func (s *Service) deleteConnectionDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	vars := mux.Vars(r)
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     vars["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	domain, ok := findDomain(connectionRef, vars["domain"])
+	if !ok {
+		utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("domain not found"), store.ErrRecordNotFound)
+		return
+	}
+
+	if err := s.store.DeleteConnectionDomain(ctx, models.ConnectionDomain{ID: domain.ID}); err != nil {
+		klog.Error(err)
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// findDomain finds connection domain by ID or hostname
+func findDomain(connection *models.Connection, ref string) (*models.ConnectionDomain, bool) {
+	for _, domain := range connection.Domains {
+		if domain.ID == ref || domain.Hostname == ref {
+			return &domain, true
+		}
+	}
+	return nil, false
+}
+
+func domainToAPI(domain models.ConnectionDomain, connection *models.Connection) api.ConnectionDomain {
+	result := api.ConnectionDomain{
+		ID:            domain.ID,
+		Hostname:      domain.Hostname,
+		CreatedAt:     domain.CreatedAt,
+		Verified:      domain.Verified,
+		VerifiedAt:    domain.VerifiedAt,
+		LastCheckedAt: domain.LastCheckedAt,
+		Message:       domain.Message,
+	}
+	if !domain.Verified {
+		result.Challenge = domainChallenge(domain, connection)
+	}
+	return result
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -34,5 +36,62 @@ func (s *Service) getConnectionGateway(w http.ResponseWriter, r *http.Request) {
 		Hostname: connectionRef.GatewayURL,
 	}
 
+	gateways, err := s.store.ListGateways(ctx)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+	result.Candidates = gatewayCandidates(gateways, connectionRef.GatewayURL, time.Now())
+
 	utilhttp.Respond(w, result)
 }
+
+// gatewayLiveTimeout is how long gateway replica is considered live after
+// its last heartbeat. Gateways heartbeat every 10 seconds.
+var gatewayLiveTimeout = 30 * time.Second
+
+// gatewayCandidates returns distinct URLs of live gateway replicas ranked
+// for the connection: the assigned gateway first, then gateways in its
+// region, then the rest.
+func gatewayCandidates(gateways []models.Gateway, assigned string, now time.Time) []api.ConnectionGatewayCandidate {
+	var region string
+	for _, gateway := range gateways {
+		if gateway.URL == assigned && gateway.Region != "" {
+			region = gateway.Region
+			break
+		}
+	}
+
+	rank := func(gateway models.Gateway) int {
+		switch {
+		case gateway.URL == assigned:
+			return 0
+		case region != "" && gateway.Region == region:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	seen := map[string]bool{}
+	live := []models.Gateway{}
+	for _, gateway := range gateways {
+		if gateway.URL == "" || seen[gateway.URL] || gateway.LastSeenAt.Before(now.Add(-gatewayLiveTimeout)) {
+			continue
+		}
+		seen[gateway.URL] = true
+		live = append(live, gateway)
+	}
+	sort.SliceStable(live, func(i, j int) bool {
+		return rank(live[i]) < rank(live[j])
+	})
+
+	result := make([]api.ConnectionGatewayCandidate, 0, len(live))
+	for _, gateway := range live {
+		result = append(result, api.ConnectionGatewayCandidate{
+			Hostname: gateway.URL,
+			Region:   gateway.Region,
+		})
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+)
+
+func TestGatewayCandidates(t *testing.T) {
+	now := time.Now()
+	gateways := []models.Gateway{
+		{ID: "1", URL: "https://us.example.com", Region: "us", LastSeenAt: now},
+		{ID: "2", URL: "https://eu-2.example.com", Region: "eu", LastSeenAt: now},
+		{ID: "3", URL: "https://eu-1.example.com", Region: "eu", LastSeenAt: now},
+		{ID: "4", URL: "https://eu-1.example.com", Region: "eu", LastSeenAt: now},
+		{ID: "5", URL: "https://gone.example.com", Region: "eu", LastSeenAt: now.Add(-time.Minute)},
+	}
+
+	assert.Equal(t, []api.ConnectionGatewayCandidate{
+		{Hostname: "https://eu-1.example.com", Region: "eu"},
+		{Hostname: "https://eu-2.example.com", Region: "eu"},
+		{Hostname: "https://us.example.com", Region: "us"},
+	}, gatewayCandidates(gateways, "https://eu-1.example.com", now))
+}
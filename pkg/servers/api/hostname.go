@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+	utilhash "github.com/faroshq/faros-ingress/pkg/util/hash"
+)
+
+// normalizeHostname turns user provided hostname into full connection
+// hostname under HostnameSuffix, generating one if empty. Leftmost label may
+// be a wildcard, routing all subdomains to the connection.
+func (s *Service) normalizeHostname(hostname string) (string, error) {
+	hostname = strings.ToLower(hostname)
+	hostname = strings.TrimPrefix(hostname, "https://")
+	hostname = strings.TrimPrefix(hostname, "http://")
+	hostname = strings.TrimSuffix(hostname, s.config.HostnameSuffix)
+	hostname = strings.TrimSuffix(hostname, ".")
+
+	if hostname == "" {
+		hostname = utilhash.GetHash(uuid.New().String())
+	}
+
+	// wildcard must be followed by at least one label of our own, so it never
+	// covers hostnames of other connections directly under the suffix
+	name := strings.TrimPrefix(hostname, "*.")
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("hostname '%s' is not valid: %s", hostname, strings.Join(errs, ", "))
+	}
+
+	return fmt.Sprintf("https://%s.%s", hostname, s.config.HostnameSuffix), nil
+}
+
+// hostnameTaken checks if hostname is used by other connection, or overlaps
+// with wildcard hostname of other user. Overlapping hostnames of the same
+// user are allowed, gateways route them by longest match.
+func (s *Service) hostnameTaken(ctx context.Context, userID, connectionID, hostname string) (bool, error) {
+	other := func(conn models.Connection) bool {
+		return conn.ID != connectionID && conn.UserID != userID
+	}
+
+	existing, err := s.store.GetConnection(ctx, models.Connection{Hostname: hostname})
+	switch {
+	case err == nil && existing.ID != connectionID:
+		return true, nil
+	case err != nil && !errors.Is(err, store.ErrRecordNotFound):
+		return false, err
+	}
+
+	// wildcard hostnames of other users covering the hostname
+	suffix := "." + s.config.HostnameSuffix
+	name := strings.TrimPrefix(hostname, "https://")
+	for domain := name; ; {
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+		if !strings.HasSuffix(domain, suffix) {
+			break
+		}
+
+		wildcard := "https://*." + domain
+		if wildcard == hostname {
+			continue
+		}
+		existing, err := s.store.GetConnection(ctx, models.Connection{Hostname: wildcard})
+		switch {
+		case err == nil && other(*existing):
+			return true, nil
+		case err != nil && !errors.Is(err, store.ErrRecordNotFound):
+			return false, err
+		}
+	}
+
+	// hostnames of other users covered by the wildcard
+	if strings.HasPrefix(name, "*.") {
+		covered, err := s.store.ListConnectionsByHostnameSuffix(ctx, strings.TrimPrefix(name, "*"))
+		if err != nil {
+			return false, err
+		}
+		for _, conn := range covered {
+			if other(conn) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestNormalizeHostname(t *testing.T) {
+	s := &Service{config: &config.Config{HostnameSuffix: "apps.faros.sh"}}
+
+	for hostname, expected := range map[string]string{
+		"myapp":                       "https://myapp.apps.faros.sh",
+		"https://MyApp.apps.faros.sh": "https://myapp.apps.faros.sh",
+		"*.myapp":                     "https://*.myapp.apps.faros.sh",
+		"*.myapp.apps.faros.sh":       "https://*.myapp.apps.faros.sh",
+		"*":                           "",
+		"*.":                          "",
+		"a.*.myapp":                   "",
+		"my_app":                      "",
+	} {
+		result, err := s.normalizeHostname(hostname)
+		if expected == "" {
+			assert.Error(t, err, hostname)
+			continue
+		}
+		require.NoError(t, err, hostname)
+		assert.Equal(t, expected, result, hostname)
+	}
+}
+
+func TestHostnameTaken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	connections := []models.Connection{
+		{ID: "wildcard", UserID: "alice", Hostname: "https://*.myapp.apps.faros.sh"},
+		{ID: "exact", UserID: "bob", Hostname: "https://api.bobapp.apps.faros.sh"},
+	}
+
+	m := store.NewMockStore(ctrl)
+	m.EXPECT().GetConnection(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, p models.Connection) (*models.Connection, error) {
+			for _, conn := range connections {
+				if conn.Hostname == p.Hostname {
+					return &conn, nil
+				}
+			}
+			return nil, store.ErrRecordNotFound
+		}).AnyTimes()
+	m.EXPECT().ListConnectionsByHostnameSuffix(gomock.Any(), ".bobapp.apps.faros.sh").Return(connections[1:], nil).AnyTimes()
+	m.EXPECT().ListConnectionsByHostnameSuffix(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	s := &Service{config: &config.Config{HostnameSuffix: "apps.faros.sh"}, store: m}
+
+	for _, tt := range []struct {
+		user     string
+		hostname string
+		taken    bool
+	}{
+		{user: "bob", hostname: "https://*.myapp.apps.faros.sh", taken: true},
+		{user: "bob", hostname: "https://tenant.myapp.apps.faros.sh", taken: true},
+		{user: "alice", hostname: "https://tenant.myapp.apps.faros.sh", taken: false},
+		{user: "alice", hostname: "https://*.bobapp.apps.faros.sh", taken: true},
+		{user: "bob", hostname: "https://*.bobapp.apps.faros.sh", taken: false},
+		{user: "bob", hostname: "https://api.bobapp.apps.faros.sh", taken: true},
+		{user: "bob", hostname: "https://other.apps.faros.sh", taken: false},
+	} {
+		taken, err := s.hostnameTaken(context.Background(), tt.user, "", tt.hostname)
+		require.NoError(t, err)
+		assert.Equal(t, tt.taken, taken, "%s %s", tt.user, tt.hostname)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -38,6 +39,8 @@ type Service struct {
 	health        *health.Health
 	store         store.Store
 	clock         clock.Clock
+
+	domainVerifier *domainVerifier
 }
 
 func New(ctx context.Context, config *config.Config) (*Service, error) {
@@ -61,6 +64,8 @@ func New(ctx context.Context, config *config.Config) (*Service, error) {
 		store:         store,
 		authenticator: authenticator,
 		clock:         clock.RealClock{},
+
+		domainVerifier: newDomainVerifier(store, net.DefaultResolver, config.DomainVerificationInterval, config.DomainClaimTTL),
 	}
 
 	s.router = setupRouter()
@@ -79,6 +84,17 @@ func New(ctx context.Context, config *config.Config) (*Service, error) {
 	agentsRouter.HandleFunc("", s.createConnection).Methods(http.MethodPost)                // /api/v1alpha1/connection
 	agentsRouter.HandleFunc("/{connection}", s.updateConnection).Methods(http.MethodPut)    // /api/v1alpha1/connection/{connection}
 
+	agentsRouter.HandleFunc("/{connection}/credentials", s.listConnectionCredentials).Methods(http.MethodGet)                  // /api/v1alpha1/connection/{connection}/credentials
+	agentsRouter.HandleFunc("/{connection}/credentials", s.createConnectionCredential).Methods(http.MethodPost)                // /api/v1alpha1/connection/{connection}/credentials
+	agentsRouter.HandleFunc("/{connection}/credentials/{credential}", s.deleteConnectionCredential).Methods(http.MethodDelete) // /api/v1alpha1/connection/{connection}/credentials/{credential}
+	agentsRouter.HandleFunc("/{connection}/domains", s.listConnectionDomains).Methods(http.MethodGet)                          // /api/v1alpha1/connection/{connection}/domains
+	agentsRouter.HandleFunc("/{connection}/domains", s.createConnectionDomain).Methods(http.MethodPost)                        // /api/v1alpha1/connection/{connection}/domains
+	agentsRouter.HandleFunc("/{connection}/domains/{domain}", s.deleteConnectionDomain).Methods(http.MethodDelete)             // /api/v1alpha1/connection/{connection}/domains/{domain}
+	agentsRouter.HandleFunc("/{connection}/domains/{domain}/verify", s.verifyConnectionDomain).Methods(http.MethodPost)        // /api/v1alpha1/connection/{connection}/domains/{domain}/verify
+	agentsRouter.HandleFunc("/{connection}/usage", s.getConnectionUsage).Methods(http.MethodGet)                               // /api/v1alpha1/connection/{connection}/usage?from&to
+	agentsRouter.HandleFunc("/{connection}/actions/{action}", s.createConnectionAction).Methods(http.MethodPost)               // /api/v1alpha1/connection/{connection}/actions/{action}
+	agentsRouter.HandleFunc("/{connection}/actions/{action}", s.getConnectionAction).Methods(http.MethodGet)                   // /api/v1alpha1/connection/{connection}/actions/{action}
+
 	agentGateway := apiRouter.PathPrefix("/connection-gateways").Subrouter()                 // /api/v1alpha1/connection-gateway
 	agentGateway.HandleFunc("/{connection}", s.getConnectionGateway).Methods(http.MethodGet) // /api/v1alpha1/connection-gateway/{connection}
 
@@ -120,6 +136,8 @@ func (s *Service) Run(ctx context.Context) error {
 		klog.Info("Stopped API Service")
 	}()
 
+	go s.domainVerifier.run(ctx)
+
 	if s.config.AutoCertEnabled() {
 		klog.V(2).InfoS("Server will now listen with certMagic", "url", s.config.APIAddr)
 		cache := certmagic.NewCache(certmagic.CacheOptions{
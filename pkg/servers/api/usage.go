@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+)
+
+// getConnectionUsage returns hourly usage of the connection in [from, to)
+// range. Range defaults to the current month.
+func (s *Service) getConnectionUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticated, user, err := s.authenticate(w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	connectionRef, err := s.store.GetConnection(ctx, models.Connection{
+		ID:     mux.Vars(r)["connection"],
+		UserID: user.ID,
+	})
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	now := s.clock.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now.Truncate(time.Hour).Add(time.Hour)
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("from must be RFC3339 time"), err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			utilhttp.WriteErrorBadRequestWithReason(w, fmt.Errorf("to must be RFC3339 time"), err)
+			return
+		}
+	}
+
+	usages, err := s.store.ListConnectionUsage(ctx, models.ConnectionUsage{ConnectionID: connectionRef.ID}, from, to)
+	if err != nil {
+		utilhttp.WriteErrorInternalServerError(w, err)
+		return
+	}
+
+	result := api.ConnectionUsageList{
+		From: from,
+		To:   to,
+	}
+	for _, usage := range usages {
+		result.Items = append(result.Items, api.ConnectionUsage{
+			Hour:     usage.Hour,
+			Requests: usage.Requests,
+			BytesIn:  usage.BytesIn,
+			BytesOut: usage.BytesOut,
+		})
+		result.Total.Requests += usage.Requests
+		result.Total.BytesIn += usage.BytesIn
+		result.Total.BytesOut += usage.BytesOut
+	}
+
+	utilhttp.Respond(w, result)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// Resolver looks up DNS records for domain verification. net.DefaultResolver
+// satisfies it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// domainVerifier periodically checks DNS challenges of pending custom domains
+// and marks them verified, so gateways start routing and issuing certificates
+// for them.
+type domainVerifier struct {
+	store    store.Store
+	resolver Resolver
+	clock    clock.Clock
+	interval time.Duration
+	// claimTTL is how long domains stay claimed without being verified
+	claimTTL time.Duration
+}
+
+func newDomainVerifier(store store.Store, resolver Resolver, interval, claimTTL time.Duration) *domainVerifier {
+	return &domainVerifier{
+		store:    store,
+		resolver: resolver,
+		clock:    clock.RealClock{},
+		interval: interval,
+		claimTTL: claimTTL,
+	}
+}
+
+func (v *domainVerifier) run(ctx context.Context) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.verifyPending(ctx); err != nil {
+				klog.Errorf("failed to verify pending domains: %s", err)
+			}
+		}
+	}
+}
+
+func (v *domainVerifier) verifyPending(ctx context.Context) error {
+	domains, err := v.store.ListPendingConnectionDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range domains {
+		if v.expired(domain) {
+			klog.V(2).InfoS("releasing unverified domain claim", "domain", domain.Hostname, "connection", domain.ConnectionID)
+			if err := v.store.DeleteConnectionDomain(ctx, domain); err != nil {
+				klog.Errorf("failed to delete expired domain %s: %s", domain.Hostname, err)
+			}
+			continue
+		}
+
+		connection, err := v.store.GetConnection(ctx, models.Connection{ID: domain.ConnectionID})
+		if err != nil {
+			klog.Errorf("failed to get connection of domain %s: %s", domain.Hostname, err)
+			continue
+		}
+
+		_, err = v.verify(ctx, domain, connection)
+		if err != nil {
+			klog.Errorf("failed to update domain %s: %s", domain.Hostname, err)
+		}
+	}
+
+	return nil
+}
+
+// expired returns true if domain was not verified within claim TTL
+func (v *domainVerifier) expired(domain models.ConnectionDomain) bool {
+	return !domain.Verified && v.claimTTL > 0 && v.clock.Since(domain.CreatedAt) > v.claimTTL
+}
+
+// verify checks the domain challenge and stores the result
+func (v *domainVerifier) verify(ctx context.Context, domain models.ConnectionDomain, connection *models.Connection) (*models.ConnectionDomain, error) {
+	now := v.clock.Now()
+	domain.LastCheckedAt = now
+
+	err := v.check(ctx, domain, connection)
+	if err != nil {
+		domain.Message = err.Error()
+	} else {
+		klog.V(2).InfoS("domain verified", "domain", domain.Hostname, "connection", connection.ID)
+		domain.Verified = true
+		domain.VerifiedAt = now
+		domain.Message = ""
+	}
+
+	return v.store.UpdateConnectionDomain(ctx, domain)
+}
+
+// check succeeds if either TXT challenge record carries the domain token, or
+// the domain is CNAME of the connection hostname.
+func (v *domainVerifier) check(ctx context.Context, domain models.ConnectionDomain, connection *models.Connection) error {
+	challenge := domainChallenge(domain, connection)
+
+	records, err := v.resolver.LookupTXT(ctx, challenge.TXTName)
+	if err == nil {
+		for _, record := range records {
+			if record == challenge.TXTValue {
+				return nil
+			}
+		}
+	}
+
+	cname, err := v.resolver.LookupCNAME(ctx, domain.Hostname)
+	if err == nil && strings.EqualFold(strings.TrimSuffix(cname, "."), challenge.CNAMETarget) {
+		return nil
+	}
+
+	return fmt.Errorf("neither TXT record '%s' with value '%s' nor CNAME record pointing to '%s' found",
+		challenge.TXTName, challenge.TXTValue, challenge.CNAMETarget)
+}
+
+func domainChallenge(domain models.ConnectionDomain, connection *models.Connection) api.DomainChallenge {
+	return api.DomainChallenge{
+		TXTName:     api.DomainChallengeRecordPrefix + domain.Hostname,
+		TXTValue:    domain.Token,
+		CNAMETarget: strings.TrimPrefix(connection.Hostname, "https://"),
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+type fakeResolver struct {
+	txt   map[string][]string
+	cname map[string]string
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r.txt[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func (r *fakeResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	cname, ok := r.cname[host]
+	if !ok {
+		return "", errors.New("no such host")
+	}
+	return cname, nil
+}
+
+func TestVerifyPendingDomains(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	conn := &models.Connection{
+		ID:       "conn1",
+		Hostname: "https://conn1.apps.faros.sh",
+	}
+	domains := []models.ConnectionDomain{
+		{ID: "txt", ConnectionID: "conn1", Hostname: "txt.ourcompany.com", Token: "token", CreatedAt: now},
+		{ID: "cname", ConnectionID: "conn1", Hostname: "cname.ourcompany.com", Token: "token", CreatedAt: now},
+		{ID: "wrong-token", ConnectionID: "conn1", Hostname: "wrong.ourcompany.com", Token: "token", CreatedAt: now},
+		{ID: "missing", ConnectionID: "conn1", Hostname: "missing.ourcompany.com", Token: "token", CreatedAt: now},
+	}
+	// claims not verified within the TTL are released
+	expired := models.ConnectionDomain{ID: "expired", ConnectionID: "conn1", Hostname: "expired.ourcompany.com", Token: "token", CreatedAt: now.Add(-2 * time.Hour)}
+
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"_faros-challenge.txt.ourcompany.com":   {"unrelated", "token"},
+			"_faros-challenge.wrong.ourcompany.com": {"other"},
+		},
+		cname: map[string]string{
+			"cname.ourcompany.com": "conn1.apps.faros.sh.",
+			"wrong.ourcompany.com": "conn2.apps.faros.sh.",
+		},
+	}
+
+	updated := map[string]models.ConnectionDomain{}
+	s := store.NewMockStore(ctrl)
+	s.EXPECT().ListPendingConnectionDomains(gomock.Any()).Return(append(domains, expired), nil)
+	s.EXPECT().DeleteConnectionDomain(gomock.Any(), expired).Return(nil)
+	s.EXPECT().GetConnection(gomock.Any(), models.Connection{ID: "conn1"}).Return(conn, nil).Times(len(domains))
+	s.EXPECT().UpdateConnectionDomain(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, domain models.ConnectionDomain) (*models.ConnectionDomain, error) {
+			updated[domain.ID] = domain
+			return &domain, nil
+		}).Times(len(domains))
+
+	v := newDomainVerifier(s, resolver, time.Minute, time.Hour)
+	v.clock = clock.NewFakeClock(now)
+	require.NoError(t, v.verifyPending(context.Background()))
+
+	for id, verified := range map[string]bool{
+		"txt":         true,
+		"cname":       true,
+		"wrong-token": false,
+		"missing":     false,
+	} {
+		domain := updated[id]
+		assert.Equal(t, verified, domain.Verified, id)
+		assert.Equal(t, now, domain.LastCheckedAt, id)
+		if verified {
+			assert.Equal(t, now, domain.VerifiedAt, id)
+			assert.Empty(t, domain.Message, id)
+		} else {
+			assert.True(t, domain.VerifiedAt.IsZero(), id)
+			assert.NotEmpty(t, domain.Message, id)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package gateway
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -11,18 +13,34 @@ import (
 	"github.com/faroshq/faros-ingress/pkg/store"
 	utilpassword "github.com/faroshq/faros-ingress/pkg/util/password"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 )
 
 type auth struct {
-	store              store.Store
-	mu                 sync.Mutex
+	store store.Store
+	// onDelete is called with ID of deleted connections
+	onDelete func(id string)
+	// onHostnames is called with hostnames a connection was indexed by
+	// before and after it changed
+	onHostnames        func(previous, current []string)
+	clock              clock.Clock
+	mu                 sync.RWMutex
 	existingConnection map[string]models.Connection // hostname -> agent
+
+	// verified caches successful credential checks so we don't run bcrypt
+	// on every proxied request. Entries are dropped when connection changes.
+	cacheTTL   time.Duration
+	verifiedMu sync.Mutex
+	verified   map[string]map[[sha256.Size]byte]time.Time // hostname -> credential digest -> expiry
 }
 
-func newAuthenticator(store store.Store) *auth {
+func newAuthenticator(store store.Store, cacheTTL time.Duration) *auth {
 	return &auth{
 		store:              store,
+		clock:              clock.RealClock{},
 		existingConnection: map[string]models.Connection{},
+		cacheTTL:           cacheTTL,
+		verified:           map[string]map[[sha256.Size]byte]time.Time{},
 	}
 }
 
@@ -32,12 +50,9 @@ func (a *auth) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	a.mu.Lock()
 	for _, conn := range conns {
-		hostname := strings.TrimPrefix(conn.Hostname, "https://")
-		a.existingConnection[hostname] = conn
+		a.setConnection(conn)
 	}
-	a.mu.Unlock()
 
 	changesCh := make(chan *models.Event)
 
@@ -64,10 +79,15 @@ func (a *auth) run(ctx context.Context) error {
 
 	// Start periodically reschedule applications on all devices or individual
 	// ones if only minimal changes are required
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-ticker.C:
+			a.expireVerified()
 		case event := <-changesCh:
 			switch event.Resource {
 			case models.EventResourceConnection:
@@ -80,16 +100,16 @@ func (a *auth) run(ctx context.Context) error {
 						klog.Error(err, "failed to get connection")
 						continue
 					}
-					hostname := strings.TrimPrefix(conn.Hostname, "https://")
-					a.mu.Lock()
-					a.existingConnection[hostname] = *conn
-					a.mu.Unlock()
+					a.setConnection(*conn)
 
 				case models.EventDeleted:
 					klog.V(2).Info("connection delete")
-					a.mu.Lock()
-					delete(a.existingConnection, event.ObjectID)
-					a.mu.Unlock()
+					for _, hostname := range a.deleteConnection(event.ObjectID) {
+						a.invalidate(hostname)
+					}
+					if a.onDelete != nil {
+						a.onDelete(event.ObjectID)
+					}
 				case models.EventUpdated:
 					klog.V(2).Info("connection update")
 					conn, err := a.store.GetConnection(ctx, models.Connection{ID: event.ObjectID})
@@ -97,41 +117,287 @@ func (a *auth) run(ctx context.Context) error {
 						klog.Error(err, "failed to get connection")
 						continue
 					}
-					hostname := strings.TrimPrefix(conn.Hostname, "https://")
-					a.mu.Lock()
-					a.existingConnection[hostname] = *conn
-					a.mu.Unlock()
+					for _, hostname := range a.setConnection(*conn) {
+						a.invalidate(hostname)
+					}
 				}
 			}
 		}
 	}
 }
 
-func (a *auth) authenticate(hostname string, username, password string) (bool, *models.Connection, error) {
+// setConnection indexes connection by its hostname and verified custom
+// domains, replacing hostnames it was previously indexed by. Returns both
+// previous and current hostnames of the connection.
+func (a *auth) setConnection(conn models.Connection) []string {
+	hostnames := []string{strings.TrimPrefix(conn.Hostname, "https://")}
+	for _, domain := range conn.Domains {
+		if domain.Verified {
+			hostnames = append(hostnames, domain.Hostname)
+		}
+	}
+
 	a.mu.Lock()
-	defer a.mu.Unlock()
+	previous := a.removeLocked(conn.ID)
+	for _, hostname := range hostnames {
+		a.existingConnection[hostname] = conn
+	}
+	a.mu.Unlock()
+
+	if a.onHostnames != nil {
+		a.onHostnames(previous, hostnames)
+	}
+	return append(previous, hostnames...)
+}
+
+// deleteConnection drops connection from the index. Returns hostnames it was
+// indexed by.
+func (a *auth) deleteConnection(id string) []string {
+	a.mu.Lock()
+	previous := a.removeLocked(id)
+	a.mu.Unlock()
+
+	if a.onHostnames != nil {
+		a.onHostnames(previous, nil)
+	}
+	return previous
+}
 
-	connection, ok := a.existingConnection[hostname]
+func (a *auth) removeLocked(id string) []string {
+	var hostnames []string
+	for hostname, conn := range a.existingConnection {
+		if conn.ID == id {
+			delete(a.existingConnection, hostname)
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
+// allowCertificate is certmagic on-demand decision function. Certificates are
+// issued for exact connection hostnames and verified custom domains, which
+// are the hostnames connections are indexed by. Subdomains of wildcard
+// connections are covered by wildcard certificates instead, so visitors can
+// not make us request a certificate for every name they make up.
+func (a *auth) allowCertificate(name string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if _, ok := a.existingConnection[name]; ok && !strings.HasPrefix(name, "*.") {
+		return nil
+	}
+	return fmt.Errorf("domain %q is not a connection hostname", name)
+}
+
+// authenticate checks request credentials against any valid credential of
+// the connection: basic auth users and api keys in their configured headers.
+// Hash comparison runs outside of the lock and successful checks are cached
+// for cacheTTL.
+func (a *auth) authenticate(host string, r *http.Request) (bool, *models.Connection, error) {
+	a.mu.RLock()
+	hostname, connection, ok := a.lookupLocked(host)
+	a.mu.RUnlock()
 	if !ok {
 		return false, nil, nil
 	}
 
-	err := utilpassword.ComparePasswordHash([]byte(username+":"+password), connection.BasicAuthHash)
-	if err != nil {
-		return false, nil, err
+	now := a.clock.Now()
+	username, password, hasBasicAuth := r.BasicAuth()
+
+	var basicDigest [sha256.Size]byte
+	if hasBasicAuth {
+		basicDigest = credentialDigest("basic", username, password)
+		if a.isVerified(hostname, basicDigest, now) {
+			return true, &connection, nil
+		}
+	}
+
+	// legacy single credential connections
+	if hasBasicAuth && len(connection.BasicAuthHash) > 0 {
+		if utilpassword.ComparePasswordHash([]byte(username+":"+password), connection.BasicAuthHash) == nil {
+			a.setVerified(hostname, basicDigest, now.Add(a.cacheTTL))
+			return true, &connection, nil
+		}
 	}
 
-	return true, &connection, nil
+	for _, credential := range connection.Credentials {
+		if credential.Expired(now) {
+			continue
+		}
+
+		var digest [sha256.Size]byte
+		var err error
+		switch credential.Type {
+		case models.CredentialTypeBasicAuth:
+			if !hasBasicAuth || credential.Username != username {
+				continue
+			}
+			digest = basicDigest
+			err = utilpassword.ComparePasswordHash([]byte(username+":"+password), credential.Hash)
+		case models.CredentialTypeAPIKey:
+			key := r.Header.Get(credential.Header)
+			if key == "" {
+				continue
+			}
+			digest = credentialDigest("api-key", credential.Header, key)
+			if a.isVerified(hostname, digest, now) {
+				return true, &connection, nil
+			}
+			err = utilpassword.CompareKeyHash([]byte(key), credential.Hash)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		expiry := now.Add(a.cacheTTL)
+		if !credential.ExpiresAt.IsZero() && credential.ExpiresAt.Before(expiry) {
+			expiry = credential.ExpiresAt
+		}
+		a.setVerified(hostname, digest, expiry)
+
+		// last used is bumped only on cache misses, so it is accurate to cacheTTL
+		go func(credential models.ConnectionCredential) {
+			err := a.store.UpdateConnectionCredentialLastUsed(context.Background(), credential)
+			if err != nil {
+				klog.Errorf("failed to update credential last used: %s", err)
+			}
+		}(credential)
+
+		return true, &connection, nil
+	}
+
+	return false, nil, nil
 }
 
-func (a *auth) getConnection(hostname string) (*models.Connection, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// cached returns true if credentials of the request were verified recently.
+// No hash is compared, so it is cheap enough to let valid credentials
+// through lockouts.
+func (a *auth) cached(host string, r *http.Request) bool {
+	a.mu.RLock()
+	hostname, connection, ok := a.lookupLocked(host)
+	a.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	now := a.clock.Now()
+	if username, password, ok := r.BasicAuth(); ok && a.isVerified(hostname, credentialDigest("basic", username, password), now) {
+		return true
+	}
+	for _, credential := range connection.Credentials {
+		if credential.Type != models.CredentialTypeAPIKey {
+			continue
+		}
+		key := r.Header.Get(credential.Header)
+		if key != "" && a.isVerified(hostname, credentialDigest("api-key", credential.Header, key), now) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialDigest returns the cache key of the presented credential so
+// plain secrets are never kept in memory.
+func credentialDigest(parts ...string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+}
+
+func (a *auth) isVerified(hostname string, digest [sha256.Size]byte, now time.Time) bool {
+	a.verifiedMu.Lock()
+	defer a.verifiedMu.Unlock()
+
+	expiry, ok := a.verified[hostname][digest]
+	return ok && now.Before(expiry)
+}
+
+func (a *auth) setVerified(hostname string, digest [sha256.Size]byte, expiry time.Time) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+
+	a.verifiedMu.Lock()
+	defer a.verifiedMu.Unlock()
+
+	if _, ok := a.verified[hostname]; !ok {
+		a.verified[hostname] = map[[sha256.Size]byte]time.Time{}
+	}
+	a.verified[hostname][digest] = expiry
+}
+
+// invalidate drops cached checks of the hostname, so revoked credentials
+// stop working as soon as the change event arrives.
+func (a *auth) invalidate(hostname string) {
+	a.verifiedMu.Lock()
+	defer a.verifiedMu.Unlock()
+
+	delete(a.verified, hostname)
+}
+
+// expireVerified drops expired cached checks
+func (a *auth) expireVerified() {
+	now := a.clock.Now()
+
+	a.verifiedMu.Lock()
+	defer a.verifiedMu.Unlock()
+
+	for hostname, digests := range a.verified {
+		for digest, expiry := range digests {
+			if !now.Before(expiry) {
+				delete(digests, digest)
+			}
+		}
+		if len(digests) == 0 {
+			delete(a.verified, hostname)
+		}
+	}
+}
 
-	connection, ok := a.existingConnection[hostname]
+func (a *auth) getConnection(host string) (*models.Connection, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	_, connection, ok := a.lookupLocked(host)
 	if !ok {
 		return nil, fmt.Errorf("unauthenticated connection")
 	}
 
 	return &connection, nil
 }
+
+// match returns hostname of the connection serving the host, which is the
+// wildcard hostname for its subdomains.
+func (a *auth) match(host string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	hostname, _, ok := a.lookupLocked(host)
+	if !ok {
+		return host
+	}
+	return hostname
+}
+
+// lookupLocked finds connection serving the host: exact hostname first, then
+// the longest matching wildcard hostname.
+func (a *auth) lookupLocked(host string) (string, models.Connection, bool) {
+	if connection, ok := a.existingConnection[host]; ok {
+		return host, connection, true
+	}
+
+	for domain := host; ; {
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+
+		hostname := "*." + domain
+		if connection, ok := a.existingConnection[hostname]; ok {
+			return hostname, connection, true
+		}
+	}
+
+	return "", models.Connection{}, false
+}
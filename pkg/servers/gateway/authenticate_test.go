@@ -0,0 +1,304 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+	utilpassword "github.com/faroshq/faros-ingress/pkg/util/password"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	partnerHash, err := utilpassword.GeneratePasswordHash([]byte("partner:secret"))
+	require.NoError(t, err)
+
+	conn := models.Connection{
+		ID:       "conn1",
+		Hostname: "conn1.apps.faros.sh",
+		Secure:   true,
+		Credentials: []models.ConnectionCredential{
+			{
+				ID:       "partner",
+				Type:     models.CredentialTypeBasicAuth,
+				Username: "partner",
+				Hash:     partnerHash,
+			},
+			{
+				ID:     "ci",
+				Type:   models.CredentialTypeAPIKey,
+				Header: "X-Api-Key",
+				Hash:   utilpassword.GenerateKeyHash([]byte("key")),
+			},
+			{
+				ID:        "expired",
+				Type:      models.CredentialTypeAPIKey,
+				Header:    "X-Old-Key",
+				Hash:      utilpassword.GenerateKeyHash([]byte("key")),
+				ExpiresAt: now.Add(-time.Minute),
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		name          string
+		request       func(r *http.Request)
+		authenticated bool
+	}{
+		{
+			name: "valid basic auth",
+			request: func(r *http.Request) {
+				r.SetBasicAuth("partner", "secret")
+			},
+			authenticated: true,
+		},
+		{
+			name: "wrong password",
+			request: func(r *http.Request) {
+				r.SetBasicAuth("partner", "wrong")
+			},
+		},
+		{
+			name: "valid api key",
+			request: func(r *http.Request) {
+				r.Header.Set("X-Api-Key", "key")
+			},
+			authenticated: true,
+		},
+		{
+			name: "api key in wrong header",
+			request: func(r *http.Request) {
+				r.Header.Set("X-Other-Key", "key")
+			},
+		},
+		{
+			name: "expired api key",
+			request: func(r *http.Request) {
+				r.Header.Set("X-Old-Key", "key")
+			},
+		},
+		{
+			name:    "no credentials",
+			request: func(r *http.Request) {},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := store.NewMockStore(ctrl)
+			store.EXPECT().UpdateConnectionCredentialLastUsed(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			a := newAuthenticator(store, time.Minute)
+			a.clock = clock.NewFakeClock(now)
+			a.existingConnection[conn.Hostname] = conn
+
+			r, err := http.NewRequest(http.MethodGet, "https://"+conn.Hostname, nil)
+			require.NoError(t, err)
+			tt.request(r)
+
+			authenticated, _, err := a.authenticate(conn.Hostname, r)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.authenticated, authenticated)
+		})
+	}
+}
+
+func TestAuthenticateCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hash, err := utilpassword.GeneratePasswordHash([]byte("partner:secret"))
+	require.NoError(t, err)
+
+	conn := models.Connection{
+		Hostname: "conn1.apps.faros.sh",
+		Secure:   true,
+		Credentials: []models.ConnectionCredential{
+			{ID: "partner", Type: models.CredentialTypeBasicAuth, Username: "partner", Hash: hash},
+		},
+	}
+
+	store := store.NewMockStore(ctrl)
+	// last used is only bumped when the cache is missed
+	var lastUsedUpdates atomic.Int32
+	store.EXPECT().UpdateConnectionCredentialLastUsed(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, models.ConnectionCredential) error {
+			lastUsedUpdates.Add(1)
+			return nil
+		}).Times(2)
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	a := newAuthenticator(store, time.Minute)
+	a.clock = fakeClock
+	a.existingConnection[conn.Hostname] = conn
+
+	r, err := http.NewRequest(http.MethodGet, "https://"+conn.Hostname, nil)
+	require.NoError(t, err)
+	r.SetBasicAuth("partner", "secret")
+
+	for i := 0; i < 3; i++ {
+		authenticated, _, err := a.authenticate(conn.Hostname, r)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+	}
+
+	// verified credentials pass lockouts, others do not
+	assert.True(t, a.cached(conn.Hostname, r))
+	wrong, err := http.NewRequest(http.MethodGet, "https://"+conn.Hostname, nil)
+	require.NoError(t, err)
+	wrong.SetBasicAuth("partner", "guess")
+	assert.False(t, a.cached(conn.Hostname, wrong))
+
+	// revoked credential must stop working once connection is updated
+	conn.Credentials = nil
+	a.existingConnection[conn.Hostname] = conn
+	a.invalidate(conn.Hostname)
+
+	authenticated, _, err := a.authenticate(conn.Hostname, r)
+	require.NoError(t, err)
+	require.False(t, authenticated)
+
+	// expired cache entries are checked again
+	conn.Credentials = []models.ConnectionCredential{
+		{ID: "partner", Type: models.CredentialTypeBasicAuth, Username: "partner", Hash: hash},
+	}
+	a.existingConnection[conn.Hostname] = conn
+	authenticated, _, err = a.authenticate(conn.Hostname, r)
+	require.NoError(t, err)
+	require.True(t, authenticated)
+
+	fakeClock.Step(2 * time.Minute)
+	a.expireVerified()
+	assert.Empty(t, a.verified)
+
+	// last used is updated asynchronously
+	require.Eventually(t, func() bool { return lastUsedUpdates.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSetConnectionDomains(t *testing.T) {
+	a := newAuthenticator(nil, time.Minute)
+
+	conn := models.Connection{
+		ID:       "conn1",
+		Hostname: "https://conn1.apps.faros.sh",
+		Domains: []models.ConnectionDomain{
+			{ID: "verified", Hostname: "preview.ourcompany.com", Verified: true},
+			{ID: "pending", Hostname: "staging.ourcompany.com"},
+		},
+	}
+	a.setConnection(conn)
+
+	for _, hostname := range []string{"conn1.apps.faros.sh", "preview.ourcompany.com"} {
+		found, err := a.getConnection(hostname)
+		require.NoError(t, err)
+		assert.Equal(t, "conn1", found.ID)
+	}
+	_, err := a.getConnection("staging.ourcompany.com")
+	assert.Error(t, err)
+
+	// certificates are issued on demand only for connection hostnames and
+	// verified custom domains, subdomains of wildcard connections are
+	// covered by wildcard certificates
+	a.setConnection(models.Connection{ID: "wildcard", Hostname: "https://*.myapp.apps.faros.sh"})
+	assert.NoError(t, a.allowCertificate("preview.ourcompany.com"))
+	assert.NoError(t, a.allowCertificate("conn1.apps.faros.sh"))
+	assert.Error(t, a.allowCertificate("api.myapp.apps.faros.sh"))
+	assert.Error(t, a.allowCertificate("*.myapp.apps.faros.sh"))
+	assert.Error(t, a.allowCertificate("staging.ourcompany.com"))
+	assert.Error(t, a.allowCertificate("unknown.com"))
+	a.deleteConnection("wildcard")
+
+	// detached domains stop being routed
+	conn.Domains = nil
+	hostnames := a.setConnection(conn)
+	assert.ElementsMatch(t, []string{"conn1.apps.faros.sh", "preview.ourcompany.com", "conn1.apps.faros.sh"}, hostnames)
+	_, err = a.getConnection("preview.ourcompany.com")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"conn1.apps.faros.sh"}, a.deleteConnection("conn1"))
+	assert.Empty(t, a.existingConnection)
+}
+
+func TestWildcardLookup(t *testing.T) {
+	a := newAuthenticator(nil, time.Minute)
+	a.setConnection(models.Connection{ID: "wildcard", Hostname: "https://*.myapp.apps.faros.sh"})
+	a.setConnection(models.Connection{ID: "nested", Hostname: "https://*.eu.myapp.apps.faros.sh"})
+	a.setConnection(models.Connection{ID: "exact", Hostname: "https://admin.myapp.apps.faros.sh"})
+
+	for host, expected := range map[string]string{
+		"tenant1.myapp.apps.faros.sh":    "wildcard",
+		"a.b.myapp.apps.faros.sh":        "wildcard",
+		"tenant1.eu.myapp.apps.faros.sh": "nested",
+		"admin.myapp.apps.faros.sh":      "exact",
+		"myapp.apps.faros.sh":            "",
+		"other.apps.faros.sh":            "",
+	} {
+		conn, err := a.getConnection(host)
+		if expected == "" {
+			assert.Error(t, err, host)
+			continue
+		}
+		require.NoError(t, err, host)
+		assert.Equal(t, expected, conn.ID, host)
+	}
+
+	assert.Equal(t, "*.myapp.apps.faros.sh", a.match("tenant1.myapp.apps.faros.sh"))
+	assert.Equal(t, "admin.myapp.apps.faros.sh", a.match("admin.myapp.apps.faros.sh"))
+}
+
+// BenchmarkAuthenticate shows throughput of secure connections with and
+// without the verified credential cache.
+func BenchmarkAuthenticate(b *testing.B) {
+	hash, err := utilpassword.GeneratePasswordHash([]byte("partner:secret"))
+	require.NoError(b, err)
+
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		b.Run("cacheTTL="+ttl.String(), func(b *testing.B) {
+			ctrl := gomock.NewController(b)
+			defer ctrl.Finish()
+
+			store := store.NewMockStore(ctrl)
+			store.EXPECT().UpdateConnectionCredentialLastUsed(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			a := newAuthenticator(store, ttl)
+			for _, hostname := range []string{"conn1.apps.faros.sh", "conn2.apps.faros.sh"} {
+				a.existingConnection[hostname] = models.Connection{
+					Hostname: hostname,
+					Secure:   true,
+					Credentials: []models.ConnectionCredential{
+						{ID: hostname, Type: models.CredentialTypeBasicAuth, Username: "partner", Hash: hash},
+					},
+				}
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r, _ := http.NewRequest(http.MethodGet, "https://conn1.apps.faros.sh", nil)
+				r.SetBasicAuth("partner", "secret")
+				i := 0
+				for pb.Next() {
+					hostname := "conn1.apps.faros.sh"
+					if i%2 == 0 {
+						hostname = "conn2.apps.faros.sh"
+					}
+					i++
+					authenticated, _, _ := a.authenticate(hostname, r)
+					if !authenticated {
+						b.Fatal("not authenticated")
+					}
+				}
+			})
+		})
+	}
+}
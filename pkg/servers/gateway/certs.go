@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/certmagic"
+	"k8s.io/klog/v2"
+)
+
+// wildcardCerts manages DNS-01 wildcard certificates of wildcard connections,
+// so their subdomains are not issued certificates on demand one by one.
+// Hostnames are collected until certificate management starts.
+type wildcardCerts struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	magic   *certmagic.Config
+	managed map[string]bool
+}
+
+func newWildcardCerts() *wildcardCerts {
+	return &wildcardCerts{
+		managed: map[string]bool{},
+	}
+}
+
+// start manages certificates of collected and later added hostnames with
+// the config
+func (c *wildcardCerts) start(ctx context.Context, magic *certmagic.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ctx, c.magic = ctx, magic
+	for hostname := range c.managed {
+		c.manageLocked(hostname)
+	}
+}
+
+// update manages certificates of wildcard hostnames a connection is indexed
+// by and stops managing ones it is not indexed by anymore
+func (c *wildcardCerts) update(previous, current []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keep := map[string]bool{}
+	for _, hostname := range current {
+		if !strings.HasPrefix(hostname, "*.") {
+			continue
+		}
+		keep[hostname] = true
+		if !c.managed[hostname] {
+			c.managed[hostname] = true
+			c.manageLocked(hostname)
+		}
+	}
+	for _, hostname := range previous {
+		if !c.managed[hostname] || keep[hostname] {
+			continue
+		}
+		delete(c.managed, hostname)
+		if c.magic != nil {
+			c.magic.Unmanage([]string{hostname})
+		}
+	}
+}
+
+func (c *wildcardCerts) manageLocked(hostname string) {
+	if c.magic == nil {
+		return
+	}
+	if err := c.magic.ManageAsync(c.ctx, []string{hostname}); err != nil {
+		klog.Errorf("failed to manage wildcard certificate of %s: %v", hostname, err)
+	}
+}
@@ -0,0 +1,30 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+)
+
+func TestWildcardCerts(t *testing.T) {
+	certs := newWildcardCerts()
+	a := newAuthenticator(nil, time.Minute)
+	a.onHostnames = certs.update
+
+	a.setConnection(models.Connection{ID: "wildcard", Hostname: "https://*.myapp.apps.faros.sh"})
+	a.setConnection(models.Connection{ID: "exact", Hostname: "https://conn1.apps.faros.sh"})
+	assert.Equal(t, map[string]bool{"*.myapp.apps.faros.sh": true}, certs.managed)
+
+	// update keeps the certificate of unchanged hostname
+	a.setConnection(models.Connection{ID: "wildcard", Hostname: "https://*.myapp.apps.faros.sh"})
+	assert.Equal(t, map[string]bool{"*.myapp.apps.faros.sh": true}, certs.managed)
+
+	a.setConnection(models.Connection{ID: "wildcard", Hostname: "https://*.other.apps.faros.sh"})
+	assert.Equal(t, map[string]bool{"*.other.apps.faros.sh": true}, certs.managed)
+
+	a.deleteConnection("wildcard")
+	assert.Empty(t, certs.managed)
+}
@@ -2,10 +2,11 @@ package gateway
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/faroshq/faros-ingress/pkg/models"
-	"k8s.io/klog/v2"
 )
 
 func (s *Service) serveIngestor(w http.ResponseWriter, r *http.Request) {
@@ -25,24 +26,22 @@ func (s *Service) serveIngestor(w http.ResponseWriter, r *http.Request) {
 
 		// If connection was found, bump last used time for housekeeping
 		// We gonna clean up connections that are not used for a while
-		go func(conn *models.Connection) {
-			conn.LastUsedAt = s.clock.Now()
-			_, err := s.store.UpdateConnection(context.Background(), *conn)
-			if err != nil {
-				klog.Errorf("failed to update connection: %s", err)
-			}
-		}(conn)
+		s.lastUsed.touch(conn.ID)
 
 		var authenticated bool
 		if conn.Secure {
-			username, password, ok := r.BasicAuth()
-			if !ok {
-				w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			// subdomains of wildcard connections share single lockout
+			hostname := s.authenticator.match(host)
+			ip := s.lockout.clientIP(r)
+			// lockouts throttle guessing, recently verified credentials still pass
+			if retryAfter, locked := s.lockout.locked(hostname, ip); locked && !s.authenticator.cached(host, r) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
 
-			authenticated, conn, err = s.authenticator.authenticate(host, username, password)
+			current := conn
+			authenticated, conn, err = s.authenticator.authenticate(host, r)
 			if err != nil {
 				w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -50,15 +49,48 @@ func (s *Service) serveIngestor(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if !authenticated {
+				if hasCredentials(current, r) {
+					s.lockout.failure(current, hostname, ip)
+				}
 				w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+			s.lockout.success(hostname, ip)
+		}
+
+		if retryAfter, over := s.meter.overQuota(conn.UserID); over {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Monthly bandwidth quota exceeded", http.StatusTooManyRequests)
+			return
 		}
 
+		release, retryAfter, ok := s.limiter.acquire(conn)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+
 		// Set conn into context
 		*r = *r.WithContext(context.WithValue(r.Context(), contextKeyConnection, conn))
 
-		s.reverseProxy.ServeHTTP(w, r)
+		body := &countingReadCloser{ReadCloser: r.Body}
+		r.Body = body
+		// upgraded connections, like websockets, do not hold in-flight slot
+		// for their whole lifetime
+		cw := &countingResponseWriter{ResponseWriter: w, onHijack: release}
+
+		s.reverseProxy.ServeHTTP(cw, r)
+
+		s.meter.record(conn, 1, body.bytes, cw.bytesOut)
+		if cw.hijacked != nil {
+			// upgraded connections are metered once they are closed
+			go func(conn *models.Connection, hijacked *countingConn) {
+				<-hijacked.done
+				s.meter.record(conn, 0, hijacked.bytesIn, hijacked.bytesOut)
+			}(conn, cw.hijacked)
+		}
 	}()
 }
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+var lastUsedFlushInterval = 10 * time.Second
+
+// lastUsedTracker coalesces connection touches in memory and periodically
+// bumps their last used time in a single write, instead of a write per
+// proxied request.
+type lastUsedTracker struct {
+	store store.Store
+
+	mu      sync.Mutex
+	touched map[string]struct{} // connection ID
+}
+
+func newLastUsedTracker(store store.Store) *lastUsedTracker {
+	return &lastUsedTracker{
+		store:   store,
+		touched: map[string]struct{}{},
+	}
+}
+
+// touch marks connection as used
+func (t *lastUsedTracker) touch(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.touched[id] = struct{}{}
+}
+
+func (t *lastUsedTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(lastUsedFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush(context.Background())
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+func (t *lastUsedTracker) flush(ctx context.Context) {
+	t.mu.Lock()
+	touched := t.touched
+	t.touched = map[string]struct{}{}
+	t.mu.Unlock()
+
+	if len(touched) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(touched))
+	for id := range touched {
+		ids = append(ids, id)
+	}
+
+	err := t.store.UpdateConnectionsLastUsed(ctx, ids)
+	if err != nil {
+		klog.Errorf("failed to update connections last used: %s", err)
+		// retry on next flush
+		t.mu.Lock()
+		for id := range touched {
+			t.touched[id] = struct{}{}
+		}
+		t.mu.Unlock()
+	}
+}
@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestLastUsedTracker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := store.NewMockStore(ctrl)
+	tracker := newLastUsedTracker(store)
+
+	for i := 0; i < 1000; i++ {
+		tracker.touch("conn1")
+	}
+
+	// touches are coalesced into a single write, failed writes are retried
+	gomock.InOrder(
+		store.EXPECT().UpdateConnectionsLastUsed(gomock.Any(), []string{"conn1"}).Return(errors.New("db down")),
+		store.EXPECT().UpdateConnectionsLastUsed(gomock.Any(), []string{"conn1"}).Return(nil),
+	)
+	tracker.flush(context.Background())
+	tracker.flush(context.Background())
+
+	// nothing touched, nothing written
+	tracker.flush(context.Background())
+}
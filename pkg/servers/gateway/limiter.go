@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+)
+
+// limits are the effective limits of a connection on this gateway replica
+type limits struct {
+	rate        rate.Limit
+	burst       int
+	maxInFlight int
+}
+
+type connectionLimiter struct {
+	limits   limits
+	rate     *rate.Limiter
+	inFlight int
+}
+
+// limiter enforces per connection requests/second and in-flight request
+// limits. Limits are split evenly between live gateway replicas, so the
+// connection as a whole gets what was configured without a store round trip
+// on every request.
+type limiter struct {
+	config   *config.Config
+	registry *registry
+	clock    clock.Clock
+
+	mu          sync.Mutex
+	connections map[string]*connectionLimiter // connection ID -> limiter
+}
+
+func newLimiter(config *config.Config, registry *registry) *limiter {
+	return &limiter{
+		config:      config,
+		registry:    registry,
+		clock:       clock.RealClock{},
+		connections: map[string]*connectionLimiter{},
+	}
+}
+
+// acquire takes a slot for request of the connection. If the request is over
+// the limit it returns false and the time after which it should be retried.
+// Release must be called once the request is done.
+func (l *limiter) acquire(conn *models.Connection) (release func(), retryAfter time.Duration, ok bool) {
+	limits := l.limits(conn)
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cl, found := l.connections[conn.ID]
+	if !found {
+		cl = &connectionLimiter{
+			limits: limits,
+			rate:   rate.NewLimiter(limits.rate, limits.burst),
+		}
+		l.connections[conn.ID] = cl
+	} else if cl.limits != limits {
+		// requests in flight release the same limiter, and tokens already
+		// taken stay taken
+		cl.rate.SetLimitAt(now, limits.rate)
+		cl.rate.SetBurstAt(now, limits.burst)
+		cl.limits = limits
+	}
+
+	if limits.maxInFlight > 0 && cl.inFlight >= limits.maxInFlight {
+		return nil, time.Second, false
+	}
+
+	reservation := cl.rate.ReserveN(now, 1)
+	if !reservation.OK() {
+		return nil, time.Second, false
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return nil, delay, false
+	}
+
+	cl.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			cl.inFlight--
+		})
+	}, 0, true
+}
+
+// forget drops limiter state of the connection
+func (l *limiter) forget(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.connections, id)
+}
+
+func (l *limiter) limits(conn *models.Connection) limits {
+	replicas := float64(l.registry.replicas())
+
+	requests := conn.RateLimit
+	if requests == 0 {
+		requests = l.config.GatewayRateLimit
+	}
+	burst := conn.RateBurst
+	if burst == 0 {
+		burst = l.config.GatewayRateBurst
+	}
+	maxInFlight := conn.MaxInFlight
+	if maxInFlight == 0 {
+		maxInFlight = l.config.GatewayMaxInFlight
+	}
+
+	result := limits{
+		rate:        rate.Inf,
+		maxInFlight: -1,
+	}
+	if requests > 0 {
+		result.rate = rate.Limit(requests / replicas)
+		result.burst = int(math.Ceil(float64(burst) / replicas))
+		if result.burst < 1 {
+			result.burst = 1
+		}
+	}
+	if maxInFlight > 0 {
+		result.maxInFlight = int(math.Ceil(float64(maxInFlight) / replicas))
+	}
+
+	return result
+}
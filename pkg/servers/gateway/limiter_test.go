@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+)
+
+func TestLimiter(t *testing.T) {
+	newTestLimiter := func(replicas int) (*limiter, *clock.FakeClock) {
+		r := &registry{}
+		for i := 0; i < replicas; i++ {
+			r.live = append(r.live, models.Gateway{})
+		}
+		fakeClock := clock.NewFakeClock(time.Now())
+		l := newLimiter(&config.Config{
+			GatewayRateLimit:   10,
+			GatewayRateBurst:   2,
+			GatewayMaxInFlight: 100,
+		}, r)
+		l.clock = fakeClock
+		return l, fakeClock
+	}
+
+	t.Run("should limit requests per second", func(t *testing.T) {
+		l, fakeClock := newTestLimiter(1)
+		conn := &models.Connection{ID: "conn1"}
+
+		for i := 0; i < 2; i++ {
+			release, _, ok := l.acquire(conn)
+			require.True(t, ok)
+			release()
+		}
+
+		_, retryAfter, ok := l.acquire(conn)
+		assert.False(t, ok)
+		assert.Equal(t, 100*time.Millisecond, retryAfter)
+
+		fakeClock.Step(100 * time.Millisecond)
+		_, _, ok = l.acquire(conn)
+		assert.True(t, ok)
+	})
+
+	t.Run("should limit in-flight requests", func(t *testing.T) {
+		l, _ := newTestLimiter(1)
+		conn := &models.Connection{ID: "conn1", RateLimit: -1, MaxInFlight: 2}
+
+		release1, _, ok := l.acquire(conn)
+		require.True(t, ok)
+		_, _, ok = l.acquire(conn)
+		require.True(t, ok)
+
+		_, _, ok = l.acquire(conn)
+		assert.False(t, ok)
+
+		release1()
+		release1() // release is idempotent
+		_, _, ok = l.acquire(conn)
+		assert.True(t, ok)
+		_, _, ok = l.acquire(conn)
+		assert.False(t, ok)
+	})
+
+	t.Run("should release requests started before limits changed", func(t *testing.T) {
+		l, _ := newTestLimiter(1)
+		conn := &models.Connection{ID: "conn1", RateLimit: -1, MaxInFlight: 2}
+
+		release1, _, ok := l.acquire(conn)
+		require.True(t, ok)
+		release2, _, ok := l.acquire(conn)
+		require.True(t, ok)
+
+		// another gateway replica halves the limit
+		l.registry.live = append(l.registry.live, models.Gateway{})
+		_, _, ok = l.acquire(conn)
+		assert.False(t, ok)
+
+		release1()
+		release2()
+		release, _, ok := l.acquire(conn)
+		assert.True(t, ok)
+		_, _, ok = l.acquire(conn)
+		assert.False(t, ok)
+		release()
+	})
+
+	t.Run("should split limits between replicas", func(t *testing.T) {
+		l, _ := newTestLimiter(4)
+		limits := l.limits(&models.Connection{RateLimit: 100, RateBurst: 10, MaxInFlight: 6})
+
+		assert.Equal(t, 25.0, float64(limits.rate))
+		assert.Equal(t, 3, limits.burst)
+		assert.Equal(t, 2, limits.maxInFlight)
+	})
+
+	t.Run("should not limit unlimited connections", func(t *testing.T) {
+		l, _ := newTestLimiter(1)
+		conn := &models.Connection{ID: "conn1", RateLimit: -1, MaxInFlight: -1}
+
+		for i := 0; i < 1000; i++ {
+			_, _, ok := l.acquire(conn)
+			require.True(t, ok)
+		}
+	})
+}
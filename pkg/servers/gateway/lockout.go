@@ -0,0 +1,257 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+var lockoutFlushInterval = 15 * time.Second
+
+// lockoutPolicy describes when failures turn into lockout and for how long.
+// Every failure past threshold doubles the lockout, up to max.
+type lockoutPolicy struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	// reset is the quiet period after which failures are forgotten
+	reset time.Duration
+}
+
+type failureEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// authStatus is the pending status of connection to be flushed to the store
+type authStatus struct {
+	failures    int
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// lockout tracks failed credential checks per hostname and per client IP of
+// the hostname and locks clients out with exponential backoff. Clients are
+// locked out of a hostname under distributed attack only if they failed
+// against it too, so sources without credentials can not lock real users
+// out.
+type lockout struct {
+	store store.Store
+	clock clock.Clock
+
+	hostPolicy lockoutPolicy
+	ipPolicy   lockoutPolicy
+	// trustForwardedFor identifies clients by X-Forwarded-For of the proxy
+	trustForwardedFor bool
+
+	mu      sync.Mutex
+	hosts   map[string]*failureEntry
+	clients map[string]*failureEntry // clientKey -> failures
+	pending map[string]*authStatus   // connection ID -> status
+}
+
+func newLockout(store store.Store, c *config.Config) *lockout {
+	return &lockout{
+		store: store,
+		clock: clock.RealClock{},
+		hostPolicy: lockoutPolicy{
+			threshold: c.GatewayAuthLockoutHostThreshold,
+			base:      c.GatewayAuthLockoutBase,
+			max:       c.GatewayAuthLockoutMax,
+			reset:     c.GatewayAuthLockoutReset,
+		},
+		ipPolicy: lockoutPolicy{
+			threshold: c.GatewayAuthLockoutIPThreshold,
+			base:      c.GatewayAuthLockoutBase,
+			max:       c.GatewayAuthLockoutMax,
+			reset:     c.GatewayAuthLockoutReset,
+		},
+		trustForwardedFor: c.GatewayTrustForwardedFor,
+		hosts:             map[string]*failureEntry{},
+		clients:           map[string]*failureEntry{},
+		pending:           map[string]*authStatus{},
+	}
+}
+
+// clientKey identifies failures of the client against the hostname
+func clientKey(hostname, ip string) string {
+	return hostname + "/" + ip
+}
+
+// locked returns for how long the client is locked out of the hostname
+func (l *lockout) locked(hostname, ip string) (time.Duration, bool) {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, ok := l.clients[clientKey(hostname, ip)]
+	if !ok {
+		return 0, false
+	}
+	until := client.lockedUntil
+	if e, ok := l.hosts[hostname]; ok && e.lockedUntil.After(until) {
+		until = e.lockedUntil
+	}
+
+	if !until.After(now) {
+		return 0, false
+	}
+	return until.Sub(now), true
+}
+
+// failure records failed credential check
+func (l *lockout) failure(conn *models.Connection, hostname, ip string) {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status, ok := l.pending[conn.ID]
+	if !ok {
+		status = &authStatus{}
+		l.pending[conn.ID] = status
+	}
+	status.failures++
+
+	if until, locked := l.record(l.hosts, hostname, l.hostPolicy, now); locked {
+		klog.InfoS("connection locked out after failed authentication", "hostname", hostname, "until", until)
+		status.lockouts++
+		status.lockedUntil = until
+	}
+	if until, locked := l.record(l.clients, clientKey(hostname, ip), l.ipPolicy, now); locked {
+		klog.InfoS("client locked out after failed authentication", "hostname", hostname, "client", ip, "until", until)
+		status.lockouts++
+	}
+}
+
+func (l *lockout) record(entries map[string]*failureEntry, key string, policy lockoutPolicy, now time.Time) (time.Time, bool) {
+	e, ok := entries[key]
+	if !ok || now.Sub(e.lastFailure) > policy.reset {
+		e = &failureEntry{}
+		entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+
+	if policy.threshold <= 0 || e.failures < policy.threshold {
+		return time.Time{}, false
+	}
+
+	duration := policy.max
+	if shift := e.failures - policy.threshold; shift < 32 {
+		if d := policy.base << shift; d > 0 && d < policy.max {
+			duration = d
+		}
+	}
+	e.lockedUntil = now.Add(duration)
+	return e.lockedUntil, true
+}
+
+// success forgets failures of the client against the hostname
+func (l *lockout) success(hostname, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.clients, clientKey(hostname, ip))
+}
+
+// run periodically flushes pending status to the store and forgets
+// entries which are past their reset period.
+func (l *lockout) run(ctx context.Context) {
+	ticker := time.NewTicker(lockoutFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.flush(context.Background())
+			return
+		case <-ticker.C:
+			l.flush(ctx)
+			l.expire()
+		}
+	}
+}
+
+func (l *lockout) flush(ctx context.Context) {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = map[string]*authStatus{}
+	l.mu.Unlock()
+
+	for id, status := range pending {
+		err := l.store.IncrementConnectionAuthFailures(ctx, models.Connection{
+			ID:              id,
+			AuthLockedUntil: status.lockedUntil,
+		}, status.failures, status.lockouts)
+		if err != nil {
+			klog.Errorf("failed to update connection auth failures: %s", err)
+		}
+	}
+}
+
+func (l *lockout) expire() {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, e := range l.hosts {
+		if now.Sub(e.lastFailure) > l.hostPolicy.reset && !e.lockedUntil.After(now) {
+			delete(l.hosts, key)
+		}
+	}
+	for key, e := range l.clients {
+		if now.Sub(e.lastFailure) > l.ipPolicy.reset && !e.lockedUntil.After(now) {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// clientIP returns the IP of the client. Unless gateway is configured to be
+// behind a proxy, remote address is used rather than spoofable forwarding
+// headers. Behind the proxy the last X-Forwarded-For address, appended by
+// the proxy itself, is used.
+func (l *lockout) clientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hasCredentials returns true if request carries any credential for the
+// connection. Requests without credentials are browser challenges and are
+// not counted as failures.
+func hasCredentials(conn *models.Connection, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	for _, credential := range conn.Credentials {
+		if credential.Type == models.CredentialTypeAPIKey && r.Header.Get(credential.Header) != "" {
+			return true
+		}
+	}
+	return false
+}
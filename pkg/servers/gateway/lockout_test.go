@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := &models.Connection{ID: "conn1"}
+	hostname := "conn1.apps.faros.sh"
+
+	newTestLockout := func(store store.Store) (*lockout, *clock.FakeClock) {
+		fakeClock := clock.NewFakeClock(time.Now())
+		l := newLockout(store, &config.Config{
+			GatewayAuthLockoutIPThreshold:   3,
+			GatewayAuthLockoutHostThreshold: 10,
+			GatewayAuthLockoutBase:          time.Second,
+			GatewayAuthLockoutMax:           time.Minute,
+			GatewayAuthLockoutReset:         time.Hour,
+		})
+		l.clock = fakeClock
+		return l, fakeClock
+	}
+
+	t.Run("should lock out client with exponential backoff", func(t *testing.T) {
+		l, fakeClock := newTestLockout(store.NewMockStore(ctrl))
+
+		for i := 0; i < 2; i++ {
+			l.failure(conn, hostname, "1.1.1.1")
+		}
+		_, locked := l.locked(hostname, "1.1.1.1")
+		assert.False(t, locked)
+
+		for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+			l.failure(conn, hostname, "1.1.1.1")
+			retryAfter, locked := l.locked(hostname, "1.1.1.1")
+			assert.True(t, locked)
+			assert.Equal(t, expected, retryAfter)
+
+			// other clients are not affected
+			_, locked = l.locked(hostname, "2.2.2.2")
+			assert.False(t, locked)
+
+			fakeClock.Step(expected)
+			_, locked = l.locked(hostname, "1.1.1.1")
+			assert.False(t, locked)
+		}
+
+		l.success(hostname, "1.1.1.1")
+		l.failure(conn, hostname, "1.1.1.1")
+		_, locked = l.locked(hostname, "1.1.1.1")
+		assert.False(t, locked)
+	})
+
+	t.Run("should forget failures only against the hostname client logged into", func(t *testing.T) {
+		l, _ := newTestLockout(store.NewMockStore(ctrl))
+		owned := "conn2.apps.faros.sh"
+
+		// logging into own connection between guesses does not help
+		for i := 0; i < 3; i++ {
+			l.failure(conn, hostname, "1.1.1.1")
+			l.success(owned, "1.1.1.1")
+		}
+		_, locked := l.locked(hostname, "1.1.1.1")
+		assert.True(t, locked)
+		_, locked = l.locked(owned, "1.1.1.1")
+		assert.False(t, locked)
+	})
+
+	t.Run("should cap lockout at max", func(t *testing.T) {
+		l, _ := newTestLockout(store.NewMockStore(ctrl))
+
+		for i := 0; i < 40; i++ {
+			l.failure(conn, hostname, "1.1.1.1")
+		}
+		retryAfter, locked := l.locked(hostname, "1.1.1.1")
+		assert.True(t, locked)
+		assert.Equal(t, time.Minute, retryAfter)
+	})
+
+	t.Run("should lock out failing clients of hostname for distributed failures", func(t *testing.T) {
+		l, _ := newTestLockout(store.NewMockStore(ctrl))
+
+		for i := 0; i < 10; i++ {
+			l.failure(conn, hostname, string(rune('a'+i)))
+		}
+		_, locked := l.locked(hostname, "a")
+		assert.True(t, locked)
+
+		// clients without failures are not locked out by others
+		_, locked = l.locked(hostname, "3.3.3.3")
+		assert.False(t, locked)
+	})
+
+	t.Run("should flush counts to store", func(t *testing.T) {
+		store := store.NewMockStore(ctrl)
+		l, _ := newTestLockout(store)
+
+		for i := 0; i < 3; i++ {
+			l.failure(conn, hostname, "1.1.1.1")
+		}
+
+		store.EXPECT().IncrementConnectionAuthFailures(gomock.Any(), gomock.Any(), 3, 1).Return(nil)
+		l.flush(context.Background())
+		// nothing pending
+		l.flush(context.Background())
+	})
+
+	t.Run("should identify client behind trusted proxy", func(t *testing.T) {
+		l, _ := newTestLockout(store.NewMockStore(ctrl))
+		r := httptest.NewRequest(http.MethodGet, "https://"+hostname, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Add("X-Forwarded-For", "6.6.6.6, 1.1.1.1")
+
+		assert.Equal(t, "10.0.0.1", l.clientIP(r))
+		l.trustForwardedFor = true
+		// visitor may send spoofed addresses, only the one proxy appended is used
+		assert.Equal(t, "1.1.1.1", l.clientIP(r))
+	})
+}
@@ -40,6 +40,15 @@ func (s *Service) director(req *http.Request) {
 		return
 	}
 
+	// connector may hold tunnels to other gateway replicas only, e.g. during
+	// rollout of this one. Forward the request to one of them.
+	peer := false
+	if s.revPool.Replicas(conn.Token) == 0 {
+		if peerURL := s.peerGateway(ctx, conn); peerURL != nil {
+			gw, peer = peerURL, true
+		}
+	}
+
 	req.Header.Add("X-Forwarded-Host", req.Host)
 	req.Header.Add("X-Origin-Host", req.Host)
 	req.URL.Scheme = "https"
@@ -50,13 +59,20 @@ func (s *Service) director(req *http.Request) {
 	// drop auth headers only if ours are used
 	if conn.Secure {
 		req.Header.Del("Authorization")
+		for _, credential := range conn.Credentials {
+			if credential.Type == models.CredentialTypeAPIKey {
+				req.Header.Del(credential.Header)
+			}
+		}
 	}
 	// Once we are in proxy mode with request, drop the client header
 
 	req.Header.Add(api.ConnectionClientHeader, api.ConnectionClientValue)
 
 	cli := s.clientCache.Get(conn.Token)
-	if cli == nil {
+	if peer {
+		cli = s.peerClient
+	} else if cli == nil {
 		var err error
 		cli, err = s.cli(ctx, gw, conn)
 		if err != nil {
@@ -71,6 +87,32 @@ func (s *Service) director(req *http.Request) {
 
 }
 
+// peerGateway returns URL of another live gateway replica holding tunnels
+// of the connection, or nil if there is none
+func (s *Service) peerGateway(ctx context.Context, conn *models.Connection) *url.URL {
+	tunnels, err := s.store.ListConnectionTunnels(ctx, models.ConnectionTunnel{ConnectionID: conn.ID})
+	if err != nil {
+		klog.Errorf("failed to list connection tunnels: %v", err)
+		return nil
+	}
+
+	for _, tunnel := range tunnels {
+		if tunnel.GatewayID == s.registry.self.ID || tunnel.Replicas == 0 || !s.registry.isLive(tunnel.GatewayID) {
+			continue
+		}
+		// replica not advertising its own URL is not reachable directly
+		if tunnel.GatewayURL == "" || tunnel.GatewayURL == s.registry.self.URL || tunnel.GatewayURL == s.config.ExternalGatewayURL {
+			continue
+		}
+		u, err := url.Parse(tunnel.GatewayURL)
+		if err != nil {
+			continue
+		}
+		return u
+	}
+	return nil
+}
+
 func (s *Service) roundTripper(r *http.Request) (*http.Response, error) {
 	if resp, ok := r.Context().Value(contextKeyResponse).(*http.Response); ok {
 		return resp, nil
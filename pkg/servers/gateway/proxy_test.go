@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/config"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestPeerGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := &models.Connection{ID: "conn1"}
+	registry := newRegistry(nil, "https://gw-1.faros.sh", "")
+	registry.live = []models.Gateway{
+		registry.self,
+		{ID: "shared", URL: "https://gateway.faros.sh"},
+		{ID: "same", URL: "https://gw-1.faros.sh"},
+		{ID: "idle", URL: "https://gw-3.faros.sh"},
+		{ID: "peer", URL: "https://gw-2.faros.sh"},
+	}
+
+	st := store.NewMockStore(ctrl)
+	s := &Service{
+		config:   &config.Config{ExternalGatewayURL: "https://gateway.faros.sh"},
+		store:    st,
+		registry: registry,
+	}
+
+	t.Run("should skip replicas not reachable directly", func(t *testing.T) {
+		st.EXPECT().ListConnectionTunnels(gomock.Any(), models.ConnectionTunnel{ConnectionID: "conn1"}).Return([]models.ConnectionTunnel{
+			{GatewayID: registry.self.ID, GatewayURL: "https://gw-1.faros.sh", Replicas: 1},
+			{GatewayID: "shared", GatewayURL: "https://gateway.faros.sh", Replicas: 1},
+			{GatewayID: "same", GatewayURL: "https://gw-1.faros.sh", Replicas: 1},
+			{GatewayID: "idle", GatewayURL: "https://gw-3.faros.sh"},
+			{GatewayID: "gone", GatewayURL: "https://gw-4.faros.sh", Replicas: 1},
+			{GatewayID: "peer", GatewayURL: "https://gw-2.faros.sh", Replicas: 1},
+		}, nil)
+
+		u := s.peerGateway(context.Background(), conn)
+		require.NotNil(t, u)
+		assert.Equal(t, "gw-2.faros.sh", u.Host)
+	})
+
+	t.Run("should not forward to the shared URL", func(t *testing.T) {
+		st.EXPECT().ListConnectionTunnels(gomock.Any(), models.ConnectionTunnel{ConnectionID: "conn1"}).Return([]models.ConnectionTunnel{
+			{GatewayID: "shared", GatewayURL: "https://gateway.faros.sh", Replicas: 1},
+		}, nil)
+
+		assert.Nil(t, s.peerGateway(context.Background(), conn))
+	})
+}
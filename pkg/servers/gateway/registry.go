@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+var gatewayHeartbeatInterval = 10 * time.Second
+
+// registry heartbeats this gateway replica into the store and keeps the
+// list of live replicas, so state like rate limits can be split between them.
+type registry struct {
+	store store.Store
+	clock clock.Clock
+	self  models.Gateway
+
+	mu   sync.RWMutex
+	live []models.Gateway
+}
+
+func newRegistry(store store.Store, url, region string) *registry {
+	return &registry{
+		store: store,
+		clock: clock.RealClock{},
+		self: models.Gateway{
+			ID:     uuid.New().String(),
+			URL:    url,
+			Region: region,
+		},
+	}
+}
+
+func (r *registry) run(ctx context.Context) {
+	ticker := time.NewTicker(gatewayHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		r.heartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			err := r.store.DeleteGateway(context.Background(), r.self)
+			if err != nil {
+				klog.Errorf("failed to deregister gateway: %s", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *registry) heartbeat(ctx context.Context) {
+	err := r.store.UpdateGatewayLastSeen(ctx, r.self)
+	if err != nil {
+		klog.Errorf("failed to update gateway last seen: %s", err)
+		return
+	}
+
+	gateways, err := r.store.ListGateways(ctx)
+	if err != nil {
+		klog.Errorf("failed to list gateways: %s", err)
+		return
+	}
+
+	// replicas missing 3 heartbeats are considered gone
+	deadline := r.clock.Now().Add(-3 * gatewayHeartbeatInterval)
+	live := []models.Gateway{}
+	for _, gateway := range gateways {
+		if gateway.LastSeenAt.After(deadline) {
+			live = append(live, gateway)
+		}
+	}
+
+	r.mu.Lock()
+	r.live = live
+	r.mu.Unlock()
+}
+
+// isLive returns true if gateway replica with id is live
+func (r *registry) isLive(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, gateway := range r.live {
+		if gateway.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// replicas returns number of live gateway replicas, including this one
+func (r *registry) replicas() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.live) == 0 {
+		return 1
+	}
+	return len(r.live)
+}
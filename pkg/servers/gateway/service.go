@@ -2,9 +2,13 @@ package gateway
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"strings"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"k8s.io/utils/clock"
 
 	"github.com/caddyserver/certmagic"
+	"github.com/faroshq/faros-ingress/pkg/api"
 	"github.com/faroshq/faros-ingress/pkg/config"
 	"github.com/faroshq/faros-ingress/pkg/h2rev2"
 	"github.com/faroshq/faros-ingress/pkg/recover"
@@ -35,9 +40,17 @@ type Service struct {
 	server        *http.Server
 	store         store.Store
 	revPool       *h2rev2.ReversePool
+	tunnels       *tunnels
 	reverseProxy  *httputil.ReverseProxy
 	authenticator *auth
+	wildcardCerts *wildcardCerts
+	lockout       *lockout
+	registry      *registry
+	limiter       *limiter
+	meter         *meter
+	lastUsed      *lastUsedTracker
 	clientCache   clientcache.ClientCache
+	peerClient    *http.Client // forwards requests to other gateway replicas
 	clock         clock.Clock
 }
 
@@ -47,18 +60,53 @@ func New(ctx context.Context, config *config.Config) (*Service, error) {
 		return nil, err
 	}
 
-	revPool := h2rev2.NewReversePool(store)
-	authenticator := newAuthenticator(store)
+	// connectors and replicas reach this replica by advertise URL, the
+	// shared URL may lead to any replica
+	switch config.GatewayAdvertiseURL {
+	case "":
+		klog.Warningf("FAROS_GATEWAY_ADVERTISE_URL is not set, this gateway replica is not offered to connectors holding tunnels to multiple gateways")
+	case config.ExternalGatewayURL:
+		return nil, fmt.Errorf("FAROS_GATEWAY_ADVERTISE_URL must be the URL of this gateway replica, not the shared gateway URL %s", config.ExternalGatewayURL)
+	}
+	registry := newRegistry(store, config.GatewayAdvertiseURL, config.GatewayRegion)
+
+	tunnels := newTunnels(store, registry.self, h2rev2.Hold{
+		Timeout:      config.GatewayHoldTimeout,
+		MaxBodyBytes: config.GatewayHoldMaxBodyBytes,
+	})
+	revPool := h2rev2.NewReversePool(h2rev2.PoolOptions{
+		Authenticator: tunnels,
+		Hooks:         tunnels.hooks(),
+		Strategy:      h2rev2.Strategy(config.GatewayLoadBalancing),
+		Liveness: h2rev2.Liveness{
+			Interval:  config.GatewayTunnelPingInterval,
+			MaxMissed: config.GatewayTunnelPingMaxMissed,
+		},
+		Hold: tunnels.hold,
+	})
+	tunnels.pool = revPool
+	authenticator := newAuthenticator(store, config.GatewayAuthCacheTTL)
 
 	s := &Service{
 		config:        config,
 		store:         store,
 		revPool:       revPool,
+		tunnels:       tunnels,
 		authenticator: authenticator,
+		wildcardCerts: newWildcardCerts(),
+		lockout:       newLockout(store, config),
+		registry:      registry,
+		limiter:       newLimiter(config, registry),
+		meter:         newMeter(store, config.UserMonthlyBandwidthQuota),
+		lastUsed:      newLastUsedTracker(store),
 		clientCache:   clientcache.New(time.Hour),
+		peerClient:    &http.Client{},
 		clock:         clock.RealClock{},
 	}
 
+	authenticator.onDelete = s.limiter.forget
+	authenticator.onHostnames = s.wildcardCerts.update
+
 	s.server = &http.Server{
 		Addr:     config.GatewayAddr,
 		ErrorLog: utilhttp.NewServerErrorLog(),
@@ -77,26 +125,26 @@ func New(ctx context.Context, config *config.Config) (*Service, error) {
 
 func (s *Service) Run(ctx context.Context) error {
 	klog.Info("Starting Gateway Service")
+	// QUIC tunnels are closed together with their listener, so it outlives
+	// ctx until tunnels are drained
+	tunnelsCtx, stopTunnels := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		defer recover.Panic()
 		<-ctx.Done()
 
-		err := s.store.Close()
-		if err != nil {
-			klog.Errorf("Error closing store: %v", err)
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-		err = s.server.Shutdown(ctx)
-		if err != nil {
-			klog.Error("gateway shutdown error", zap.Error(err))
-		}
+		s.shutdown()
+		stopTunnels()
 		klog.Info("Stopped Gateway Service")
 	}()
 
-	go s.revPool.Run(ctx)
+	go s.tunnels.run(ctx)
 	go s.authenticator.run(ctx)
+	go s.lockout.run(ctx)
+	go s.registry.run(ctx)
+	go s.meter.run(ctx)
+	go s.lastUsed.run(ctx)
 	go s.runGC(ctx)
 
 	if s.config.AutoCertEnabled() {
@@ -138,21 +186,39 @@ func (s *Service) Run(ctx context.Context) error {
 			DisableTLSALPNChallenge: true,
 		})
 
-		magic.Issuers = []certmagic.Issuer{issuer}
+		// custom domains are not in our DNS zone, so their certificates are
+		// obtained on demand with HTTP and TLS-ALPN challenges instead.
+		customDomainIssuer := certmagic.NewACMEIssuer(magic, certmagic.ACMEIssuer{
+			CA:                ca,
+			Email:             s.config.AutoCertLEEmail,
+			CertObtainTimeout: 5 * time.Minute,
+			Agreed:            true,
+			AltHTTPPort:       8080,
+			AltTLSALPNPort:    8443,
+		})
+
+		magic.Issuers = []certmagic.Issuer{issuer, customDomainIssuer}
+		magic.OnDemand = &certmagic.OnDemandConfig{
+			DecisionFunc: s.authenticator.allowCertificate,
+		}
 
 		// this obtains certificates or renews them if necessary
 		err := magic.ManageSync(ctx, s.config.AutoCertGatewayDomains)
 		if err != nil {
 			return err
 		}
+		// wildcard connections are served by wildcard certificates obtained
+		// with the DNS-01 issuer
+		s.wildcardCerts.start(ctx, magic)
 
 		s.server.TLSConfig = magic.TLSConfig()
 		s.server.TLSConfig.NextProtos = append(s.server.TLSConfig.NextProtos, tlsalpn01.ACMETLS1Protocol)
+		go s.runQUIC(tunnelsCtx, magic.TLSConfig())
 
 		log.Printf("Serving https for domains: %+v", s.config.AutoCertGatewayDomains)
 		go func() {
 			for {
-				err := http.ListenAndServe(":8080", issuer.HTTPChallengeHandler(nil))
+				err := http.ListenAndServe(":8080", customDomainIssuer.HTTPChallengeHandler(issuer.HTTPChallengeHandler(nil)))
 				if err != nil {
 					klog.Error("api listen error", zap.Error(err))
 				}
@@ -167,21 +233,92 @@ func (s *Service) Run(ctx context.Context) error {
 	} else {
 		// Bring your own certs
 		klog.V(2).InfoS("Server will now listen", "url", s.config.GatewayAddr)
+		if s.config.GatewayQUICEnabled {
+			cert, err := tls.LoadX509KeyPair(s.config.TLSCertFile, s.config.TLSKeyFile)
+			if err != nil {
+				return err
+			}
+			go s.runQUIC(tunnelsCtx, &tls.Config{Certificates: []tls.Certificate{cert}})
+		}
 		err := s.server.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
 		if err != nil {
 			klog.Error("api listen error", zap.Error(err))
 
 		}
 	}
+
+	// server stops listening right away, wait until shutdown completes
+	if ctx.Err() != nil {
+		<-stopped
+	}
 	return nil
 }
 
+// shutdown moves connector tunnels to other gateways, waits for requests in
+// flight and closes the store last, as closing tunnels updates it
+func (s *Service) shutdown() {
+	// registry removes this gateway from the store on ctx cancel, so
+	// connectors are not sent back to it
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.GatewayDrainTimeout)
+	defer cancel()
+	if err := s.revPool.Drain(ctx); err != nil {
+		klog.Errorf("tunnels left after drain timeout: %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		klog.Error("gateway shutdown error", zap.Error(err))
+	}
+
+	if err := s.store.Close(); err != nil {
+		klog.Errorf("Error closing store: %v", err)
+	}
+}
+
+// runQUIC serves connector tunnels over QUIC, if enabled
+func (s *Service) runQUIC(ctx context.Context, tlsConfig *tls.Config) {
+	if !s.config.GatewayQUICEnabled {
+		return
+	}
+	defer recover.Panic()
+
+	l, err := h2rev2.ListenQUIC(s.config.GatewayAddr, tlsConfig)
+	if err != nil {
+		klog.Error("quic listen error", zap.Error(err))
+		return
+	}
+	klog.V(2).InfoS("Server will now listen for QUIC tunnels", "url", s.config.GatewayAddr)
+	if err := s.revPool.ServeQUIC(ctx, l); err != nil {
+		klog.Error("quic serve error", zap.Error(err))
+	}
+}
+
 func (s *Service) handler() http.Handler {
+	// ping path of connection hosts belongs to the connection
+	gatewayHosts := map[string]bool{}
+	for _, gatewayURL := range []string{s.config.ExternalGatewayURL, s.config.GatewayAdvertiseURL} {
+		if u, err := url.Parse(gatewayURL); err == nil && u.Hostname() != "" {
+			gatewayHosts[u.Hostname()] = true
+		}
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api/v1alpha1/proxy/") {
+		if r.URL.Path == api.GatewayPingPath && gatewayHosts[hostname(r.Host)] {
+			// lightweight endpoint connectors measure latency against
+			w.WriteHeader(http.StatusNoContent)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1alpha1/proxy/") {
 			s.revPool.ServeHTTP(w, r)
 		} else {
 			s.serveIngestor(w, r)
 		}
 	})
 }
+
+// hostname returns host of the request without port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/config"
+)
+
+func TestHandlerPing(t *testing.T) {
+	s := &Service{
+		config: &config.Config{
+			ExternalGatewayURL:  "https://gateway.faros.sh",
+			GatewayAdvertiseURL: "https://gw-1.faros.sh:8444",
+		},
+		authenticator: newAuthenticator(nil, time.Minute),
+	}
+	handler := s.handler()
+
+	for _, tc := range []struct {
+		host     string
+		expected int
+	}{
+		{"gateway.faros.sh", http.StatusNoContent},
+		{"gateway.faros.sh:443", http.StatusNoContent},
+		{"gw-1.faros.sh:8444", http.StatusNoContent},
+		// connection hosts serve the path of the connection
+		{"app.apps.faros.sh", http.StatusUnauthorized},
+	} {
+		t.Run(tc.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, api.GatewayPingPath, nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
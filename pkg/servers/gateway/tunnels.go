@@ -0,0 +1,345 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+var _ h2rev2.Authenticator = &tunnels{}
+
+// heartbeatInterval is how often last seen time of connected tunnels is updated
+var heartbeatInterval = time.Minute
+
+// tunnels authenticates connector tunnels against the store and records their
+// state, agent information and action results
+type tunnels struct {
+	store   store.Store
+	gateway models.Gateway
+	pool    *h2rev2.ReversePool
+	// defaultHold is how requests wait for connectors to reconnect unless
+	// the connection overrides it
+	defaultHold h2rev2.Hold
+
+	mu sync.Mutex // guards below
+	// tokens of connections by connection id
+	tokens map[string]string
+	// holds of requests by connection token
+	holds map[string]h2rev2.Hold
+	// heartbeats cancel last seen updates of connected replicas
+	heartbeats map[string]context.CancelFunc
+}
+
+func newTunnels(store store.Store, gateway models.Gateway, defaultHold h2rev2.Hold) *tunnels {
+	return &tunnels{
+		store:       store,
+		gateway:     gateway,
+		defaultHold: defaultHold,
+		tokens:      map[string]string{},
+		holds:       map[string]h2rev2.Hold{},
+		heartbeats:  map[string]context.CancelFunc{},
+	}
+}
+
+// hooks returns the hooks of the reverse pool
+func (t *tunnels) hooks() h2rev2.Hooks {
+	return h2rev2.Hooks{
+		OnConnect:      t.onConnect,
+		OnDisconnect:   t.onDisconnect,
+		OnAgent:        t.onAgent,
+		OnActionResult: t.onActionResult,
+	}
+}
+
+// run keeps connection tokens in sync with the store and sends created
+// actions to connectors
+func (t *tunnels) run(ctx context.Context) error {
+	// initial build of the connection tokens
+	conns, err := t.store.ListAllConnections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, conn := range conns {
+		t.track(conn)
+	}
+
+	changesCh := make(chan *models.Event)
+
+	go func() {
+		klog.V(2).Info("Subscribing to changes")
+		defer klog.V(2).Info("Unsubscribing from changes")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				err := t.store.SubscribeChanges(ctx, func(event *models.Event) error {
+					changesCh <- event
+					return nil
+				})
+				if err != nil {
+					klog.Error(err, "failed to subscribe to changes")
+				}
+				// Retry to subscribe
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-changesCh:
+			switch event.Resource {
+			case models.EventResourceConnection:
+				switch event.Type {
+				case models.EventCreated, models.EventUpdated:
+					klog.V(2).Info("connection changed")
+					conn, err := t.store.GetConnection(ctx, models.Connection{ID: event.ObjectID})
+					if err != nil {
+						klog.Error(err, "failed to get connection")
+						continue
+					}
+					t.track(*conn)
+				case models.EventDeleted:
+					klog.V(2).Info("connection delete")
+					t.mu.Lock()
+					delete(t.holds, t.tokens[event.ObjectID])
+					delete(t.tokens, event.ObjectID)
+					t.mu.Unlock()
+				}
+			case models.EventResourceConnectionAction:
+				if event.Type == models.EventCreated {
+					t.sendAction(ctx, event.ObjectID)
+				}
+			}
+		}
+	}
+}
+
+// track records token of the connection and how its requests are held
+func (t *tunnels) track(conn models.Connection) {
+	hold := t.defaultHold
+	if conn.HoldTimeout != 0 {
+		hold.Timeout = conn.HoldTimeout
+	}
+	if conn.HoldMaxBodyBytes != 0 {
+		hold.MaxBodyBytes = conn.HoldMaxBodyBytes
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, ok := t.tokens[conn.ID]; ok && previous != conn.Token {
+		delete(t.holds, previous)
+	}
+	t.tokens[conn.ID] = conn.Token
+	t.holds[conn.Token] = hold
+}
+
+// hold returns how requests to the tunnels of the connection token wait for
+// the connector to reconnect
+func (t *tunnels) hold(id string) h2rev2.Hold {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.holds[id]
+}
+
+// connectionID returns id of the connection the token belongs to
+func (t *tunnels) connectionID(token string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, known := range t.tokens {
+		if known == token {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// AuthenticateListener allows tunnels of known connections. Listener id is
+// the connection token.
+func (t *tunnels) AuthenticateListener(r *http.Request, id string) error {
+	if _, ok := t.connectionID(id); !ok {
+		return h2rev2.ErrUnauthorized
+	}
+	return nil
+}
+
+// AuthenticateProxy allows requests to tunnels of known connections
+func (t *tunnels) AuthenticateProxy(r *http.Request, id string) error {
+	return t.AuthenticateListener(r, id)
+}
+
+func heartbeatKey(id, replicaID string) string {
+	return id + "/" + replicaID
+}
+
+// onConnect updates last seen time of the connection while the replica is connected
+func (t *tunnels) onConnect(id, replicaID string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.mu.Lock()
+	if previous, ok := t.heartbeats[heartbeatKey(id, replicaID)]; ok {
+		previous()
+	}
+	t.heartbeats[heartbeatKey(id, replicaID)] = cancel
+	t.mu.Unlock()
+
+	go t.heartbeat(ctx, id)
+}
+
+func (t *tunnels) onDisconnect(id, replicaID string) {
+	t.mu.Lock()
+	if cancel, ok := t.heartbeats[heartbeatKey(id, replicaID)]; ok {
+		cancel()
+		delete(t.heartbeats, heartbeatKey(id, replicaID))
+	}
+	t.mu.Unlock()
+
+	t.updateConnectionState(context.Background(), id)
+}
+
+// heartbeat updates the last seen time of the connection until the context is done
+// TODO: move to more scalable solution
+func (t *tunnels) heartbeat(ctx context.Context, id string) {
+	t.updateConnectionState(ctx, id)
+
+	for {
+		select {
+		case <-time.After(heartbeatInterval):
+			t.updateConnectionState(ctx, id)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// updateConnectionState stores the number of replicas of the connection
+// attached to this gateway. Connection is disconnected once it has no
+// replicas on any gateway.
+func (t *tunnels) updateConnectionState(ctx context.Context, id string) {
+	err := t.store.UpdateConnectionLastSeen(ctx, models.Connection{Token: id}, models.ConnectionTunnel{
+		GatewayID:  t.gateway.ID,
+		GatewayURL: t.gateway.URL,
+		Replicas:   t.pool.Replicas(id),
+	})
+	if err != nil {
+		klog.Errorf("failed to update connection last seen: %v", err)
+	}
+}
+
+// onAgent stores agent information reported by the listener
+func (t *tunnels) onAgent(id, replicaID string, agent api.ConnectionAgent) {
+	conditions := make([]models.ConnectionCondition, 0, len(agent.Conditions))
+	for _, condition := range agent.Conditions {
+		conditions = append(conditions, models.ConnectionCondition{
+			Type:               condition.Type,
+			Status:             models.ConditionStatus(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime,
+		})
+	}
+
+	gateways := make([]models.ConnectionAgentGateway, 0, len(agent.Gateways))
+	for _, gateway := range agent.Gateways {
+		gateways = append(gateways, models.ConnectionAgentGateway{
+			Hostname: gateway.Hostname,
+			Latency:  gateway.Latency,
+		})
+	}
+
+	var compression *models.ConnectionAgentCompression
+	if agent.Compression != nil {
+		compression = &models.ConnectionAgentCompression{
+			UncompressedBytes: agent.Compression.UncompressedBytes,
+			CompressedBytes:   agent.Compression.CompressedBytes,
+			Ratio:             agent.Compression.Ratio,
+		}
+	}
+
+	err := t.store.UpdateConnectionAgent(context.Background(), models.Connection{Token: id}, models.ConnectionAgent{
+		Version:       agent.Version,
+		Hostname:      agent.Hostname,
+		OS:            agent.OS,
+		StartedAt:     agent.StartedAt,
+		DownstreamURL: agent.DownstreamURL,
+		ReportedAt:    time.Now(),
+		Gateways:      gateways,
+		Conditions:    conditions,
+		Compression:   compression,
+	})
+	if err != nil {
+		klog.Errorf("failed to update connection agent: %v", err)
+	}
+}
+
+// sendAction sends the action to connector replicas if this gateway holds
+// their tunnels. Other gateways ignore it.
+func (t *tunnels) sendAction(ctx context.Context, actionID string) {
+	action, err := t.store.GetConnectionAction(ctx, models.ConnectionAction{ID: actionID})
+	if err != nil {
+		klog.Error(err, "failed to get connection action")
+		return
+	}
+
+	t.mu.Lock()
+	token, ok := t.tokens[action.ConnectionID]
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// every replica executes the action, result of the last one to acknowledge is kept
+	err = t.pool.SendAction(token, api.ConnectionAction{
+		ID:            action.ID,
+		Type:          api.ConnectionActionType(action.Type),
+		GatewayURL:    action.GatewayURL,
+		DownstreamURL: action.DownstreamURL,
+	})
+	if err != nil {
+		klog.Errorf("failed to send action %s: %v", action.ID, err)
+		t.updateAction(action.ConnectionID, api.ConnectionAction{
+			ID:      action.ID,
+			Status:  api.ConnectionActionFailed,
+			Message: fmt.Sprintf("failed to send action to connector: %v", err),
+		})
+	}
+}
+
+// onActionResult stores result of the action acknowledged by the listener.
+// Listener can only acknowledge actions of its own connection.
+func (t *tunnels) onActionResult(id string, action api.ConnectionAction) {
+	connectionID, ok := t.connectionID(id)
+	if !ok {
+		return
+	}
+	t.updateAction(connectionID, action)
+}
+
+// updateAction stores result of the action of the connection
+func (t *tunnels) updateAction(connectionID string, action api.ConnectionAction) {
+	if action.ID == "" {
+		return
+	}
+
+	_, err := t.store.UpdateConnectionAction(context.Background(), models.ConnectionAction{
+		ID:           action.ID,
+		ConnectionID: connectionID,
+		Status:       models.ConnectionActionStatus(action.Status),
+		Message:      action.Message,
+		Diagnostics:  action.Diagnostics,
+	})
+	if err != nil {
+		klog.Errorf("failed to update connection action: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestTunnels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := store.NewMockStore(ctrl)
+	gw := models.Gateway{ID: "gateway", URL: "https://gateway"}
+	tunnels := newTunnels(s, gw, h2rev2.Hold{})
+	tunnels.tokens["connection"] = "token"
+
+	pool := h2rev2.NewReversePool(h2rev2.PoolOptions{
+		Authenticator: tunnels,
+		Hooks:         tunnels.hooks(),
+	})
+	tunnels.pool = pool
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	t.Run("should reject tunnels of unknown connections", func(t *testing.T) {
+		_, err := h2rev2.NewListener(gateway.Client(), gateway.URL, "unknown", h2rev2.ListenerOptions{Transport: h2rev2.TransportHTTP2})
+		assert.Error(t, err)
+
+		resp, err := gateway.Client().Get(gateway.URL + "/proxy/unknown/app")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should record connected replicas", func(t *testing.T) {
+		connected := make(chan struct{})
+		disconnected := make(chan struct{})
+		gomock.InOrder(
+			s.EXPECT().UpdateConnectionLastSeen(gomock.Any(), models.Connection{Token: "token"}, models.ConnectionTunnel{
+				GatewayID:  gw.ID,
+				GatewayURL: gw.URL,
+				Replicas:   1,
+			}).DoAndReturn(func(context.Context, models.Connection, models.ConnectionTunnel) error {
+				close(connected)
+				return nil
+			}),
+			s.EXPECT().UpdateConnectionLastSeen(gomock.Any(), models.Connection{Token: "token"}, models.ConnectionTunnel{
+				GatewayID:  gw.ID,
+				GatewayURL: gw.URL,
+				Replicas:   0,
+			}).DoAndReturn(func(context.Context, models.Connection, models.ConnectionTunnel) error {
+				close(disconnected)
+				return nil
+			}),
+		)
+
+		l, err := h2rev2.NewListener(gateway.Client(), gateway.URL, "token", h2rev2.ListenerOptions{Transport: h2rev2.TransportHTTP2})
+		require.NoError(t, err)
+		downstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})}
+		go downstream.Serve(l)
+		defer downstream.Close()
+
+		select {
+		case <-connected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connected replica not recorded")
+		}
+
+		resp, err := gateway.Client().Get(gateway.URL + "/proxy/token/app")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+
+		l.Close()
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("disconnected replica not recorded")
+		}
+	})
+}
+
+func TestTunnelsHold(t *testing.T) {
+	defaultHold := h2rev2.Hold{Timeout: 10 * time.Second}
+	tunnels := newTunnels(nil, models.Gateway{}, defaultHold)
+
+	tunnels.track(models.Connection{ID: "default", Token: "default-token"})
+	tunnels.track(models.Connection{ID: "override", Token: "override-token", HoldTimeout: time.Minute, HoldMaxBodyBytes: 1024})
+	tunnels.track(models.Connection{ID: "disabled", Token: "disabled-token", HoldTimeout: -1})
+
+	assert.Equal(t, defaultHold, tunnels.hold("default-token"))
+	assert.Equal(t, h2rev2.Hold{Timeout: time.Minute, MaxBodyBytes: 1024}, tunnels.hold("override-token"))
+	assert.Equal(t, h2rev2.Hold{Timeout: -1}, tunnels.hold("disabled-token"))
+	assert.Equal(t, h2rev2.Hold{}, tunnels.hold("unknown"))
+
+	// rotated token drops hold of the previous one
+	tunnels.track(models.Connection{ID: "default", Token: "rotated-token"})
+	assert.Equal(t, h2rev2.Hold{}, tunnels.hold("default-token"))
+	assert.Equal(t, defaultHold, tunnels.hold("rotated-token"))
+}
+
+func TestTunnelsActionResult(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := store.NewMockStore(ctrl)
+	tunnels := newTunnels(s, models.Gateway{}, h2rev2.Hold{})
+	tunnels.track(models.Connection{ID: "connection", Token: "token"})
+
+	t.Run("should scope result to the connection of the listener", func(t *testing.T) {
+		s.EXPECT().UpdateConnectionAction(gomock.Any(), models.ConnectionAction{
+			ID:           "action",
+			ConnectionID: "connection",
+			Status:       models.ConnectionActionSucceeded,
+		}).Return(nil, nil)
+
+		tunnels.onActionResult("token", api.ConnectionAction{ID: "action", Status: api.ConnectionActionSucceeded})
+	})
+
+	t.Run("should ignore result of unknown listener", func(t *testing.T) {
+		tunnels.onActionResult("unknown", api.ConnectionAction{ID: "action", Status: api.ConnectionActionSucceeded})
+	})
+}
@@ -0,0 +1,267 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+var usageFlushInterval = 30 * time.Second
+
+type usageKey struct {
+	connectionID string
+	hour         time.Time
+}
+
+// meter aggregates request counts and bytes per connection in memory and
+// flushes them to the store in batches as hourly usage rows. It also keeps
+// monthly bandwidth usage per user to enforce the quota.
+type meter struct {
+	store store.Store
+	clock clock.Clock
+	// quota is the monthly bandwidth quota per user in bytes. 0 means unlimited.
+	quota int64
+
+	mu      sync.Mutex
+	pending map[usageKey]*models.ConnectionUsage
+	month   time.Time
+	users   map[string]int64 // user ID -> bytes used this month
+}
+
+func newMeter(store store.Store, quota int64) *meter {
+	return &meter{
+		store:   store,
+		clock:   clock.RealClock{},
+		quota:   quota,
+		pending: map[usageKey]*models.ConnectionUsage{},
+		users:   map[string]int64{},
+	}
+}
+
+// record adds usage of the connection
+func (m *meter) record(conn *models.Connection, requests, bytesIn, bytesOut int64) {
+	now := m.clock.Now().UTC()
+	key := usageKey{connectionID: conn.ID, hour: now.Truncate(time.Hour)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage, ok := m.pending[key]
+	if !ok {
+		usage = &models.ConnectionUsage{
+			ConnectionID: conn.ID,
+			UserID:       conn.UserID,
+			Hour:         key.hour,
+		}
+		m.pending[key] = usage
+	}
+	usage.Requests += requests
+	usage.BytesIn += bytesIn
+	usage.BytesOut += bytesOut
+
+	m.rollover(now)
+	m.users[conn.UserID] += bytesIn + bytesOut
+}
+
+// overQuota returns true and time until quota resets if user used up the
+// monthly bandwidth quota.
+func (m *meter) overQuota(userID string) (time.Duration, bool) {
+	if m.quota <= 0 {
+		return 0, false
+	}
+	now := m.clock.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rollover(now)
+	if m.users[userID] < m.quota {
+		return 0, false
+	}
+	return m.month.AddDate(0, 1, 0).Sub(now), true
+}
+
+// rollover resets monthly user usage when month changes. Must be called with lock held.
+func (m *meter) rollover(now time.Time) {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if !month.Equal(m.month) {
+		m.month = month
+		m.users = map[string]int64{}
+	}
+}
+
+func (m *meter) run(ctx context.Context) {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.flush(context.Background())
+			return
+		case <-ticker.C:
+			m.flush(ctx)
+		}
+	}
+}
+
+// flush writes pending usage to the store and refreshes monthly usage of
+// the users, so usage recorded by other gateway replicas counts too.
+func (m *meter) flush(ctx context.Context) {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[usageKey]*models.ConnectionUsage{}
+	month := m.month
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	usages := make([]models.ConnectionUsage, 0, len(pending))
+	users := map[string]struct{}{}
+	for _, usage := range pending {
+		usages = append(usages, *usage)
+		users[usage.UserID] = struct{}{}
+	}
+
+	err := m.store.AddConnectionUsage(ctx, usages)
+	if err != nil {
+		klog.Errorf("failed to flush connection usage: %s", err)
+		// put it back so it is retried on next flush
+		m.mu.Lock()
+		for key, usage := range pending {
+			if current, ok := m.pending[key]; ok {
+				current.Requests += usage.Requests
+				current.BytesIn += usage.BytesIn
+				current.BytesOut += usage.BytesOut
+			} else {
+				m.pending[key] = usage
+			}
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	if m.quota <= 0 {
+		return
+	}
+
+	for userID := range users {
+		total, err := m.store.SumUserUsage(ctx, models.ConnectionUsage{UserID: userID}, month, month.AddDate(0, 1, 0))
+		if err != nil {
+			klog.Errorf("failed to get user usage: %s", err)
+			continue
+		}
+
+		m.mu.Lock()
+		if m.month.Equal(month) {
+			used := total.BytesIn + total.BytesOut
+			// usage recorded since the flush started is not in the store yet
+			for _, usage := range m.pending {
+				if usage.UserID == userID {
+					used += usage.BytesIn + usage.BytesOut
+				}
+			}
+			m.users[userID] = used
+		}
+		m.mu.Unlock()
+	}
+}
+
+// countingReadCloser counts bytes read from the request body
+type countingReadCloser struct {
+	io.ReadCloser
+
+	bytes int64
+}
+
+func (rc *countingReadCloser) Read(b []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(b)
+	atomic.AddInt64(&rc.bytes, int64(n))
+	return n, err
+}
+
+// countingResponseWriter counts bytes written to the client, including
+// bytes of hijacked connections.
+type countingResponseWriter struct {
+	http.ResponseWriter
+
+	bytesOut int64
+	hijacked *countingConn
+	// onHijack is called once the connection is hijacked
+	onHijack func()
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	atomic.AddInt64(&w.bytesOut, int64(n))
+	return n, err
+}
+
+func (w *countingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack not supported")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = &countingConn{Conn: conn, done: make(chan struct{})}
+	if w.onHijack != nil {
+		w.onHijack()
+	}
+	return w.hijacked, rw, nil
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingConn counts bytes of hijacked connection, like websockets
+type countingConn struct {
+	net.Conn
+
+	bytesIn   int64
+	bytesOut  int64
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.bytesIn, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.bytesOut, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { close(c.done) })
+	return err
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+func TestMeter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2022, 12, 31, 23, 30, 0, 0, time.UTC)
+	conn := &models.Connection{ID: "conn1", UserID: "user1"}
+
+	t.Run("should aggregate usage per hour and flush in batch", func(t *testing.T) {
+		store := store.NewMockStore(ctrl)
+		fakeClock := clock.NewFakeClock(now)
+		m := newMeter(store, 0)
+		m.clock = fakeClock
+
+		m.record(conn, 1, 10, 100)
+		m.record(conn, 1, 10, 100)
+		fakeClock.Step(time.Hour)
+		m.record(conn, 1, 1, 1)
+
+		store.EXPECT().AddConnectionUsage(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, usages []models.ConnectionUsage) error {
+				require.Len(t, usages, 2)
+				for _, usage := range usages {
+					switch usage.Hour {
+					case now.Truncate(time.Hour):
+						assert.Equal(t, int64(2), usage.Requests)
+						assert.Equal(t, int64(20), usage.BytesIn)
+						assert.Equal(t, int64(200), usage.BytesOut)
+					default:
+						assert.Equal(t, int64(1), usage.Requests)
+					}
+				}
+				return nil
+			})
+		m.flush(context.Background())
+		// nothing pending
+		m.flush(context.Background())
+	})
+
+	t.Run("should retry failed flush", func(t *testing.T) {
+		store := store.NewMockStore(ctrl)
+		m := newMeter(store, 0)
+		m.clock = clock.NewFakeClock(now)
+
+		m.record(conn, 1, 10, 100)
+		store.EXPECT().AddConnectionUsage(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+		m.flush(context.Background())
+
+		m.record(conn, 1, 10, 100)
+		store.EXPECT().AddConnectionUsage(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, usages []models.ConnectionUsage) error {
+				require.Len(t, usages, 1)
+				assert.Equal(t, int64(2), usages[0].Requests)
+				return nil
+			})
+		m.flush(context.Background())
+	})
+
+	t.Run("should enforce monthly quota", func(t *testing.T) {
+		store := store.NewMockStore(ctrl)
+		fakeClock := clock.NewFakeClock(now)
+		m := newMeter(store, 1000)
+		m.clock = fakeClock
+
+		_, over := m.overQuota(conn.UserID)
+		assert.False(t, over)
+
+		m.record(conn, 1, 100, 100)
+
+		// other gateways used up the rest of the quota
+		store.EXPECT().AddConnectionUsage(gomock.Any(), gomock.Any()).Return(nil)
+		store.EXPECT().SumUserUsage(gomock.Any(), models.ConnectionUsage{UserID: conn.UserID}, gomock.Any(), gomock.Any()).
+			Return(&models.ConnectionUsage{BytesIn: 500, BytesOut: 500}, nil)
+		m.flush(context.Background())
+
+		retryAfter, over := m.overQuota(conn.UserID)
+		assert.True(t, over)
+		assert.Equal(t, 30*time.Minute, retryAfter)
+
+		// quota resets with new month
+		fakeClock.Step(time.Hour)
+		_, over = m.overQuota(conn.UserID)
+		assert.False(t, over)
+	})
+}
+
+func TestCountingResponseWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &countingResponseWriter{ResponseWriter: recorder}
+
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), w.bytesOut)
+
+	// recorder, like HTTP/2 writers, can not be hijacked
+	_, _, err = w.Hijack()
+	assert.Error(t, err)
+	assert.Nil(t, w.hijacked)
+
+	require.NoError(t, http.NewResponseController(w).Flush())
+	assert.True(t, recorder.Flushed)
+}
+
+// hijackableRecorder hands out one end of a pipe when hijacked
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, _ := net.Pipe()
+	return conn, nil, nil
+}
+
+func TestCountingResponseWriterHijack(t *testing.T) {
+	hijacked := 0
+	w := &countingResponseWriter{
+		ResponseWriter: hijackableRecorder{httptest.NewRecorder()},
+		onHijack:       func() { hijacked++ },
+	}
+
+	conn, _, err := w.Hijack()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 1, hijacked)
+	assert.NotNil(t, w.hijacked)
+}
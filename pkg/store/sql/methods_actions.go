@@ -0,0 +1,75 @@
+package storesql
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// GetConnectionAction gets connection action by ID
+func (s *Store) GetConnectionAction(ctx context.Context, p models.ConnectionAction) (*models.ConnectionAction, error) {
+	switch {
+	case p.ID != "":
+		// OK, getting by ID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	result := models.ConnectionAction{}
+	if err := s.db.WithContext(ctx).Where(&p).First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CreateConnectionAction creates pending action and notifies gateways, so the
+// one owning the tunnel sends it to the connector
+func (s *Store) CreateConnectionAction(ctx context.Context, p models.ConnectionAction) (*models.ConnectionAction, error) {
+	switch {
+	case p.ConnectionID != "" && p.Type != "":
+		// OK, creating for ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	p.ID = uuid.New().String()
+	p.Status = models.ConnectionActionPending
+
+	err := s.db.WithContext(ctx).Create(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyUpdatedConnectionAction(ctx, p.ID, models.EventCreated)
+
+	return &p, nil
+}
+
+// UpdateConnectionAction stores result of the action based on action and
+// connection ID. It does not emit change events, nobody reacts to
+// acknowledged actions.
+func (s *Store) UpdateConnectionAction(ctx context.Context, p models.ConnectionAction) (*models.ConnectionAction, error) {
+	switch {
+	case p.ID != "" && p.ConnectionID != "":
+		// OK, updating by ID of the connection
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	err := s.db.WithContext(ctx).Model(&models.ConnectionAction{}).Where(&models.ConnectionAction{ID: p.ID, ConnectionID: p.ConnectionID}).
+		Select("Status", "Message", "Diagnostics").Updates(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
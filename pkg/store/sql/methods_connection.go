@@ -2,10 +2,13 @@ package storesql
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/faroshq/faros-ingress/pkg/models"
 	"github.com/faroshq/faros-ingress/pkg/store"
@@ -25,7 +28,7 @@ func (s *Store) GetConnection(ctx context.Context, p models.Connection) (*models
 	}
 
 	result := models.Connection{}
-	if err := s.db.WithContext(ctx).Where(&p).First(&result).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Credentials").Preload("Domains").Where(&p).First(&result).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, store.ErrRecordNotFound
 		}
@@ -38,6 +41,9 @@ func (s *Store) GetConnection(ctx context.Context, p models.Connection) (*models
 // CreateConnection creates remote cluster object
 func (s *Store) CreateConnection(ctx context.Context, p models.Connection) (*models.Connection, error) {
 	p.ID = uuid.New().String()
+	for i := range p.Credentials {
+		p.Credentials[i].ID = uuid.New().String()
+	}
 
 	err := s.db.WithContext(ctx).Create(&p).Error
 	if err != nil {
@@ -60,8 +66,9 @@ func (s *Store) UpdateConnection(ctx context.Context, p models.Connection) (*mod
 		return nil, store.ErrFailToQuery
 	}
 
+	// credentials and domains are managed by their own methods, never save them with the connection
 	query := models.Connection{ID: p.ID}
-	err := s.db.WithContext(ctx).Model(&models.Connection{}).Where(&query).Save(&p).Error
+	err := s.db.WithContext(ctx).Model(&models.Connection{}).Where(&query).Omit(clause.Associations).Save(&p).Error
 	if err != nil {
 		return nil, err
 	}
@@ -71,26 +78,136 @@ func (s *Store) UpdateConnection(ctx context.Context, p models.Connection) (*mod
 	return s.GetConnection(ctx, models.Connection{ID: p.ID})
 }
 
-// UpdateConnection updates remote cluster based on remote cluster ID
-func (s *Store) UpdateConnectionLastSeen(ctx context.Context, p models.Connection, state models.ConnectionState) error {
+// tunnelTimeout is how long tunnels are counted without being refreshed.
+// Gateways refresh their tunnels every minute.
+var tunnelTimeout = 3 * time.Minute
+
+// UpdateConnectionLastSeen records tunnels the gateway holds for the
+// connection based on token, and updates state and number of connected
+// connector replicas of the connection summed over all gateways. Tunnel
+// without replicas is removed.
+func (s *Store) UpdateConnectionLastSeen(ctx context.Context, p models.Connection, tunnel models.ConnectionTunnel) error {
 	switch {
-	case p.Token != "":
+	case p.Token != "" && tunnel.GatewayID != "":
 		// OK, getting by token only
 	default:
 		return store.ErrFailToQuery
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		err := tx.WithContext(ctx).Model(&models.Connection{}).Where(&p).
-			Update("last_used_at", s.clock.Now()).Error
+	now := s.clock.Now()
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current := models.Connection{}
+		if err := tx.Where(&p).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return store.ErrRecordNotFound
+			}
+			return err
+		}
+
+		tunnel.ConnectionID = current.ID
+		tunnel.LastSeenAt = now
+		if tunnel.Replicas > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "connection_id"}, {Name: "gateway_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"last_seen_at", "gateway_url", "replicas", "updated_at"}),
+			}).Create(&tunnel).Error
+			if err != nil {
+				return err
+			}
+		} else {
+			err := tx.Where(&models.ConnectionTunnel{ConnectionID: current.ID, GatewayID: tunnel.GatewayID}).
+				Delete(&models.ConnectionTunnel{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var replicas int64
+		err := tx.Model(&models.ConnectionTunnel{}).
+			Where("connection_id = ? AND last_seen_at > ?", current.ID, now.Add(-tunnelTimeout)).
+			Select("COALESCE(SUM(replicas), 0)").Scan(&replicas).Error
 		if err != nil {
 			return err
 		}
-		return tx.WithContext(ctx).Model(&models.Connection{}).Where(&p).
-			Update("state", state).Error
+
+		state := models.StateConnected
+		if replicas == 0 {
+			state = models.StateDisconnected
+		}
+		return tx.Model(&models.Connection{}).Where(&models.Connection{ID: current.ID}).
+			Updates(map[string]interface{}{"last_used_at": now, "state": state, "replicas": replicas}).Error
 	})
 }
 
+// ListConnectionTunnels lists gateways holding tunnels of the connection
+func (s *Store) ListConnectionTunnels(ctx context.Context, p models.ConnectionTunnel) ([]models.ConnectionTunnel, error) {
+	switch {
+	case p.ConnectionID != "":
+		// OK, listing by ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	results := []models.ConnectionTunnel{}
+	err := s.db.WithContext(ctx).Where(&p).Where("last_seen_at > ?", s.clock.Now().Add(-tunnelTimeout)).
+		Order("last_seen_at desc").Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// UpdateConnectionAgent stores connector reported agent information. It does
+// not emit change events, gateways don't route by it.
+func (s *Store) UpdateConnectionAgent(ctx context.Context, p models.Connection, agent models.ConnectionAgent) error {
+	switch {
+	case p.Token != "":
+		// OK, getting by token only
+	default:
+		return store.ErrFailToQuery
+	}
+
+	return s.db.WithContext(ctx).Model(&models.Connection{}).Where(&p).
+		Select("Agent").Updates(&models.Connection{Agent: agent}).Error
+}
+
+// UpdateConnectionsLastUsed bumps last used time of connections in a single
+// write. Only the timestamp is updated and no change events are emitted, as
+// gateways call it for connections carrying traffic.
+func (s *Store) UpdateConnectionsLastUsed(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).Model(&models.Connection{}).Where("id IN ?", ids).
+		UpdateColumn("last_used_at", s.clock.Now()).Error
+}
+
+// IncrementConnectionAuthFailures adds failed credential checks and lockouts
+// to the connection counters. Counters are incremented so multiple gateways
+// can report. It does not emit change events as it is called by gateways.
+func (s *Store) IncrementConnectionAuthFailures(ctx context.Context, p models.Connection, failures, lockouts int) error {
+	switch {
+	case p.ID != "":
+		// OK, updating by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	updates := map[string]interface{}{
+		"auth_failures": gorm.Expr("auth_failures + ?", failures),
+		"auth_lockouts": gorm.Expr("auth_lockouts + ?", lockouts),
+	}
+	if !p.AuthLockedUntil.IsZero() {
+		updates["auth_locked_until"] = p.AuthLockedUntil
+	}
+
+	return s.db.WithContext(ctx).Model(&models.Connection{}).Where(&models.Connection{ID: p.ID}).
+		Updates(updates).Error
+}
+
 // DeleteWorkspace deletes remote clusters based on cluster ID
 func (s *Store) DeleteConnection(ctx context.Context, p models.Connection) error {
 	switch {
@@ -102,7 +219,17 @@ func (s *Store) DeleteConnection(ctx context.Context, p models.Connection) error
 
 	s.notifyUpdatedConnection(ctx, p.ID, models.EventDeleted)
 
-	return s.db.WithContext(ctx).Delete(&p).Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&models.ConnectionCredential{ConnectionID: p.ID}).Delete(&models.ConnectionCredential{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where(&models.ConnectionDomain{ConnectionID: p.ID}).Delete(&models.ConnectionDomain{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Omit(clause.Associations).Delete(&p).Error
+	})
 }
 
 // ListConnections lists clusters
@@ -115,7 +242,27 @@ func (s *Store) ListConnections(ctx context.Context, p models.Connection) ([]mod
 	}
 
 	results := []models.Connection{}
-	if err := s.db.WithContext(ctx).Where(&p).Find(&results).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Domains").Where(&p).Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ListConnectionsByHostnameSuffix lists connections with hostname ending
+// with the suffix
+func (s *Store) ListConnectionsByHostnameSuffix(ctx context.Context, suffix string) ([]models.Connection, error) {
+	if suffix == "" {
+		return nil, store.ErrFailToQuery
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(suffix)
+
+	results := []models.Connection{}
+	if err := s.db.WithContext(ctx).Where(`hostname LIKE ? ESCAPE '\'`, "%"+escaped).Find(&results).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, store.ErrRecordNotFound
 		}
@@ -129,7 +276,7 @@ func (s *Store) ListConnections(ctx context.Context, p models.Connection) ([]mod
 func (s *Store) ListAllConnections(ctx context.Context) ([]models.Connection, error) {
 	results := []models.Connection{}
 	p := models.Connection{}
-	if err := s.db.WithContext(ctx).Where(&p).Find(&results).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Credentials").Preload("Domains").Where(&p).Find(&results).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, store.ErrRecordNotFound
 		}
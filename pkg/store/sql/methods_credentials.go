@@ -0,0 +1,125 @@
+package storesql
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// ListConnectionCredentials lists credentials of the connection
+func (s *Store) ListConnectionCredentials(ctx context.Context, p models.ConnectionCredential) ([]models.ConnectionCredential, error) {
+	switch {
+	case p.ConnectionID != "":
+		// OK, listing by ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	results := []models.ConnectionCredential{}
+	if err := s.db.WithContext(ctx).Where(&p).Order("created_at").Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CreateConnectionCredential creates credential for the connection. Gateways
+// are notified via connection update event so they pick up the credential.
+func (s *Store) CreateConnectionCredential(ctx context.Context, p models.ConnectionCredential) (*models.ConnectionCredential, error) {
+	switch {
+	case p.ConnectionID != "" && p.Name != "":
+		// OK, creating for ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	p.ID = uuid.New().String()
+
+	err := s.db.WithContext(ctx).Create(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyUpdatedConnection(ctx, p.ConnectionID, models.EventUpdated)
+
+	return &p, nil
+}
+
+// ReplaceConnectionCredential creates credential for the connection in place
+// of its credential with the same name, in one transaction so the connection
+// is never left without one.
+func (s *Store) ReplaceConnectionCredential(ctx context.Context, p models.ConnectionCredential) (*models.ConnectionCredential, error) {
+	switch {
+	case p.ConnectionID != "" && p.Name != "":
+		// OK, replacing by ConnectionID and Name
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	p.ID = uuid.New().String()
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&models.ConnectionCredential{ConnectionID: p.ConnectionID, Name: p.Name}).
+			Delete(&models.ConnectionCredential{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&p).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyUpdatedConnection(ctx, p.ConnectionID, models.EventUpdated)
+
+	return &p, nil
+}
+
+// DeleteConnectionCredential revokes credential based on credential ID
+func (s *Store) DeleteConnectionCredential(ctx context.Context, p models.ConnectionCredential) error {
+	switch {
+	case p.ID != "":
+		// OK, deleting by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	current := models.ConnectionCredential{}
+	if err := s.db.WithContext(ctx).Where(&p).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrRecordNotFound
+		}
+		return err
+	}
+
+	err := s.db.WithContext(ctx).Delete(&current).Error
+	if err != nil {
+		return err
+	}
+
+	s.notifyUpdatedConnection(ctx, current.ConnectionID, models.EventUpdated)
+
+	return nil
+}
+
+// UpdateConnectionCredentialLastUsed bumps last used time of the credential.
+// It does not emit change events as it is called on the request path.
+func (s *Store) UpdateConnectionCredentialLastUsed(ctx context.Context, p models.ConnectionCredential) error {
+	switch {
+	case p.ID != "":
+		// OK, updating by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	return s.db.WithContext(ctx).Model(&models.ConnectionCredential{}).Where(&models.ConnectionCredential{ID: p.ID}).
+		Update("last_used_at", s.clock.Now()).Error
+}
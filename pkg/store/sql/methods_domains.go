@@ -0,0 +1,139 @@
+package storesql
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// GetConnectionDomain gets custom domain by ID or hostname
+func (s *Store) GetConnectionDomain(ctx context.Context, p models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	switch {
+	case p.ID != "":
+		// OK, getting by ID
+	case p.Hostname != "":
+		// OK, getting by Hostname
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	result := models.ConnectionDomain{}
+	if err := s.db.WithContext(ctx).Where(&p).First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListConnectionDomains lists custom domains of the connection
+func (s *Store) ListConnectionDomains(ctx context.Context, p models.ConnectionDomain) ([]models.ConnectionDomain, error) {
+	switch {
+	case p.ConnectionID != "":
+		// OK, listing by ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	results := []models.ConnectionDomain{}
+	if err := s.db.WithContext(ctx).Where(&p).Order("created_at").Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ListPendingConnectionDomains lists custom domains of all connections which
+// are not verified yet
+func (s *Store) ListPendingConnectionDomains(ctx context.Context) ([]models.ConnectionDomain, error) {
+	results := []models.ConnectionDomain{}
+	if err := s.db.WithContext(ctx).Where("verified = ?", false).Order("last_checked_at").Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CreateConnectionDomain attaches custom domain to the connection
+func (s *Store) CreateConnectionDomain(ctx context.Context, p models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	switch {
+	case p.ConnectionID != "" && p.Hostname != "":
+		// OK, creating for ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	p.ID = uuid.New().String()
+
+	err := s.db.WithContext(ctx).Create(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyUpdatedConnection(ctx, p.ConnectionID, models.EventUpdated)
+
+	return &p, nil
+}
+
+// UpdateConnectionDomain updates custom domain based on domain ID. Gateways
+// are notified only once the domain is verified, as pending domains are not
+// routed and verification checks would otherwise flood them with events.
+func (s *Store) UpdateConnectionDomain(ctx context.Context, p models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	switch {
+	case p.ID != "":
+		// OK, updating by ID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	err := s.db.WithContext(ctx).Model(&models.ConnectionDomain{}).Where(&models.ConnectionDomain{ID: p.ID}).Save(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Verified {
+		s.notifyUpdatedConnection(ctx, p.ConnectionID, models.EventUpdated)
+	}
+
+	return &p, nil
+}
+
+// DeleteConnectionDomain detaches custom domain based on domain ID
+func (s *Store) DeleteConnectionDomain(ctx context.Context, p models.ConnectionDomain) error {
+	switch {
+	case p.ID != "":
+		// OK, deleting by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	current := models.ConnectionDomain{}
+	if err := s.db.WithContext(ctx).Where(&p).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrRecordNotFound
+		}
+		return err
+	}
+
+	err := s.db.WithContext(ctx).Delete(&current).Error
+	if err != nil {
+		return err
+	}
+
+	s.notifyUpdatedConnection(ctx, current.ConnectionID, models.EventUpdated)
+
+	return nil
+}
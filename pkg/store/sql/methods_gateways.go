@@ -0,0 +1,55 @@
+package storesql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// ListGateways lists registered gateway replicas
+func (s *Store) ListGateways(ctx context.Context) ([]models.Gateway, error) {
+	results := []models.Gateway{}
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// UpdateGatewayLastSeen registers the gateway replica or bumps its last seen time.
+// It does not emit change events as it is called periodically.
+func (s *Store) UpdateGatewayLastSeen(ctx context.Context, p models.Gateway) error {
+	switch {
+	case p.ID != "":
+		// OK, updating by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	p.LastSeenAt = s.clock.Now()
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_seen_at", "url", "updated_at"}),
+	}).Create(&p).Error
+}
+
+// DeleteGateway deletes gateway replica based on ID
+func (s *Store) DeleteGateway(ctx context.Context, p models.Gateway) error {
+	switch {
+	case p.ID != "":
+		// OK, deleting by ID
+	default:
+		return store.ErrFailToQuery
+	}
+
+	return s.db.WithContext(ctx).Delete(&p).Error
+}
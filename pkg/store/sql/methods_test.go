@@ -41,3 +41,42 @@ func TestCascade(t *testing.T) {
 	})
 	require.Error(t, err)
 }
+
+// TestReplaceConnectionCredential tests if credential with the same name is replaced
+func TestReplaceConnectionCredential(t *testing.T) {
+	if os.Getenv("CI_ONLY") == "" {
+		t.Skip("skipping postgres tests in non-CI environment")
+		return
+	}
+
+	db, err := databasetest.NewPostgresTestingStore(t)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	user, err := db.CreateUser(ctx, models.User{
+		Email: "foo@foo.lt",
+	})
+	require.NoError(t, err)
+
+	conn, err := db.CreateConnection(ctx, models.Connection{
+		UserID: user.ID,
+	})
+	require.NoError(t, err)
+
+	for _, username := range []string{"first", "second"} {
+		_, err = db.ReplaceConnectionCredential(ctx, models.ConnectionCredential{
+			ConnectionID: conn.ID,
+			Name:         "default",
+			Username:     username,
+		})
+		require.NoError(t, err)
+	}
+
+	credentials, err := db.ListConnectionCredentials(ctx, models.ConnectionCredential{
+		ConnectionID: conn.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	require.Equal(t, "second", credentials[0].Username)
+}
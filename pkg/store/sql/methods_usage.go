@@ -0,0 +1,71 @@
+package storesql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/faroshq/faros-ingress/pkg/models"
+	"github.com/faroshq/faros-ingress/pkg/store"
+)
+
+// AddConnectionUsage adds usage to hourly usage rows in a single batch.
+// Existing rows are incremented so multiple gateways can report.
+func (s *Store) AddConnectionUsage(ctx context.Context, p []models.ConnectionUsage) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "connection_id"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":  gorm.Expr("connection_usages.requests + excluded.requests"),
+			"bytes_in":  gorm.Expr("connection_usages.bytes_in + excluded.bytes_in"),
+			"bytes_out": gorm.Expr("connection_usages.bytes_out + excluded.bytes_out"),
+		}),
+	}).Create(&p).Error
+}
+
+// ListConnectionUsage lists hourly usage of connection in [from, to) range
+func (s *Store) ListConnectionUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) ([]models.ConnectionUsage, error) {
+	switch {
+	case p.ConnectionID != "":
+		// OK, listing by ConnectionID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	results := []models.ConnectionUsage{}
+	err := s.db.WithContext(ctx).Where(&models.ConnectionUsage{ConnectionID: p.ConnectionID}).
+		Where("hour >= ? AND hour < ?", from.UTC(), to.UTC()).
+		Order("hour").Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SumUserUsage sums usage of all connections of the user in [from, to) range
+func (s *Store) SumUserUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) (*models.ConnectionUsage, error) {
+	switch {
+	case p.UserID != "":
+		// OK, summing by UserID
+	default:
+		return nil, store.ErrFailToQuery
+	}
+
+	result := models.ConnectionUsage{UserID: p.UserID}
+	err := s.db.WithContext(ctx).Model(&models.ConnectionUsage{}).
+		Select("COALESCE(SUM(requests), 0) AS requests, COALESCE(SUM(bytes_in), 0) AS bytes_in, COALESCE(SUM(bytes_out), 0) AS bytes_out").
+		Where(&models.ConnectionUsage{UserID: p.UserID}).
+		Where("hour >= ? AND hour < ?", from.UTC(), to.UTC()).
+		Scan(&result).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -18,6 +18,12 @@ func (s *Store) migrate(ctx context.Context, c *config.Database) error {
 	err := s.db.AutoMigrate(
 		&models.User{},
 		&models.Connection{},
+		&models.ConnectionCredential{},
+		&models.ConnectionDomain{},
+		&models.ConnectionAction{},
+		&models.ConnectionTunnel{},
+		&models.Gateway{},
+		&models.ConnectionUsage{},
 	)
 	if err != nil {
 		return err
@@ -92,6 +92,10 @@ func (s *Store) notifyUpdatedConnection(ctx context.Context, id string, event mo
 	s._notify(ctx, id, models.EventResourceConnection, event)
 }
 
+func (s *Store) notifyUpdatedConnectionAction(ctx context.Context, id string, event models.EventType) {
+	s._notify(ctx, id, models.EventResourceConnectionAction, event)
+}
+
 func (s *Store) notifyUpdatedUser(ctx context.Context, id string, event models.EventType) {
 	s._notify(ctx, id, models.EventResourceUser, event)
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/faroshq/faros-ingress/pkg/models"
 )
@@ -13,10 +14,40 @@ type Store interface {
 	GetConnection(context.Context, models.Connection) (*models.Connection, error)
 	ListConnections(context.Context, models.Connection) ([]models.Connection, error)
 	ListAllConnections(ctx context.Context) ([]models.Connection, error)
+	ListConnectionsByHostnameSuffix(ctx context.Context, suffix string) ([]models.Connection, error)
 	DeleteConnection(context.Context, models.Connection) error
 	CreateConnection(context.Context, models.Connection) (*models.Connection, error)
 	UpdateConnection(context.Context, models.Connection) (*models.Connection, error)
-	UpdateConnectionLastSeen(context.Context, models.Connection, models.ConnectionState) error
+	UpdateConnectionLastSeen(ctx context.Context, p models.Connection, tunnel models.ConnectionTunnel) error
+	ListConnectionTunnels(context.Context, models.ConnectionTunnel) ([]models.ConnectionTunnel, error)
+	UpdateConnectionsLastUsed(ctx context.Context, ids []string) error
+	UpdateConnectionAgent(ctx context.Context, p models.Connection, agent models.ConnectionAgent) error
+	IncrementConnectionAuthFailures(ctx context.Context, p models.Connection, failures, lockouts int) error
+
+	ListConnectionCredentials(context.Context, models.ConnectionCredential) ([]models.ConnectionCredential, error)
+	CreateConnectionCredential(context.Context, models.ConnectionCredential) (*models.ConnectionCredential, error)
+	ReplaceConnectionCredential(context.Context, models.ConnectionCredential) (*models.ConnectionCredential, error)
+	DeleteConnectionCredential(context.Context, models.ConnectionCredential) error
+	UpdateConnectionCredentialLastUsed(context.Context, models.ConnectionCredential) error
+
+	GetConnectionDomain(context.Context, models.ConnectionDomain) (*models.ConnectionDomain, error)
+	ListConnectionDomains(context.Context, models.ConnectionDomain) ([]models.ConnectionDomain, error)
+	ListPendingConnectionDomains(ctx context.Context) ([]models.ConnectionDomain, error)
+	CreateConnectionDomain(context.Context, models.ConnectionDomain) (*models.ConnectionDomain, error)
+	UpdateConnectionDomain(context.Context, models.ConnectionDomain) (*models.ConnectionDomain, error)
+	DeleteConnectionDomain(context.Context, models.ConnectionDomain) error
+
+	GetConnectionAction(context.Context, models.ConnectionAction) (*models.ConnectionAction, error)
+	CreateConnectionAction(context.Context, models.ConnectionAction) (*models.ConnectionAction, error)
+	UpdateConnectionAction(context.Context, models.ConnectionAction) (*models.ConnectionAction, error)
+
+	AddConnectionUsage(context.Context, []models.ConnectionUsage) error
+	ListConnectionUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) ([]models.ConnectionUsage, error)
+	SumUserUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) (*models.ConnectionUsage, error)
+
+	ListGateways(context.Context) ([]models.Gateway, error)
+	UpdateGatewayLastSeen(context.Context, models.Gateway) error
+	DeleteGateway(context.Context, models.Gateway) error
 
 	GetUser(context.Context, models.User) (*models.User, error)
 	ListUsers(context.Context, models.User) ([]models.User, error)
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: store.go
+// Source: pkg/store/store.go
 
 // Package store is a generated GoMock package.
 package store
@@ -7,6 +7,7 @@ package store
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/faroshq/faros-ingress/pkg/models"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +36,20 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AddConnectionUsage mocks base method.
+func (m *MockStore) AddConnectionUsage(arg0 context.Context, arg1 []models.ConnectionUsage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddConnectionUsage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddConnectionUsage indicates an expected call of AddConnectionUsage.
+func (mr *MockStoreMockRecorder) AddConnectionUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddConnectionUsage", reflect.TypeOf((*MockStore)(nil).AddConnectionUsage), arg0, arg1)
+}
+
 // Close mocks base method.
 func (m *MockStore) Close() error {
 	m.ctrl.T.Helper()
@@ -64,6 +79,51 @@ func (mr *MockStoreMockRecorder) CreateConnection(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConnection", reflect.TypeOf((*MockStore)(nil).CreateConnection), arg0, arg1)
 }
 
+// CreateConnectionAction mocks base method.
+func (m *MockStore) CreateConnectionAction(arg0 context.Context, arg1 models.ConnectionAction) (*models.ConnectionAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConnectionAction", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateConnectionAction indicates an expected call of CreateConnectionAction.
+func (mr *MockStoreMockRecorder) CreateConnectionAction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConnectionAction", reflect.TypeOf((*MockStore)(nil).CreateConnectionAction), arg0, arg1)
+}
+
+// CreateConnectionCredential mocks base method.
+func (m *MockStore) CreateConnectionCredential(arg0 context.Context, arg1 models.ConnectionCredential) (*models.ConnectionCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConnectionCredential", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateConnectionCredential indicates an expected call of CreateConnectionCredential.
+func (mr *MockStoreMockRecorder) CreateConnectionCredential(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConnectionCredential", reflect.TypeOf((*MockStore)(nil).CreateConnectionCredential), arg0, arg1)
+}
+
+// CreateConnectionDomain mocks base method.
+func (m *MockStore) CreateConnectionDomain(arg0 context.Context, arg1 models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConnectionDomain", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateConnectionDomain indicates an expected call of CreateConnectionDomain.
+func (mr *MockStoreMockRecorder) CreateConnectionDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConnectionDomain", reflect.TypeOf((*MockStore)(nil).CreateConnectionDomain), arg0, arg1)
+}
+
 // CreateUser mocks base method.
 func (m *MockStore) CreateUser(arg0 context.Context, arg1 models.User) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -93,6 +153,48 @@ func (mr *MockStoreMockRecorder) DeleteConnection(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConnection", reflect.TypeOf((*MockStore)(nil).DeleteConnection), arg0, arg1)
 }
 
+// DeleteConnectionCredential mocks base method.
+func (m *MockStore) DeleteConnectionCredential(arg0 context.Context, arg1 models.ConnectionCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConnectionCredential", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConnectionCredential indicates an expected call of DeleteConnectionCredential.
+func (mr *MockStoreMockRecorder) DeleteConnectionCredential(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConnectionCredential", reflect.TypeOf((*MockStore)(nil).DeleteConnectionCredential), arg0, arg1)
+}
+
+// DeleteConnectionDomain mocks base method.
+func (m *MockStore) DeleteConnectionDomain(arg0 context.Context, arg1 models.ConnectionDomain) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConnectionDomain", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConnectionDomain indicates an expected call of DeleteConnectionDomain.
+func (mr *MockStoreMockRecorder) DeleteConnectionDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConnectionDomain", reflect.TypeOf((*MockStore)(nil).DeleteConnectionDomain), arg0, arg1)
+}
+
+// DeleteGateway mocks base method.
+func (m *MockStore) DeleteGateway(arg0 context.Context, arg1 models.Gateway) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGateway", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGateway indicates an expected call of DeleteGateway.
+func (mr *MockStoreMockRecorder) DeleteGateway(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGateway", reflect.TypeOf((*MockStore)(nil).DeleteGateway), arg0, arg1)
+}
+
 // DeleteUser mocks base method.
 func (m *MockStore) DeleteUser(arg0 context.Context, arg1 models.User) error {
 	m.ctrl.T.Helper()
@@ -122,6 +224,36 @@ func (mr *MockStoreMockRecorder) GetConnection(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConnection", reflect.TypeOf((*MockStore)(nil).GetConnection), arg0, arg1)
 }
 
+// GetConnectionAction mocks base method.
+func (m *MockStore) GetConnectionAction(arg0 context.Context, arg1 models.ConnectionAction) (*models.ConnectionAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConnectionAction", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConnectionAction indicates an expected call of GetConnectionAction.
+func (mr *MockStoreMockRecorder) GetConnectionAction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConnectionAction", reflect.TypeOf((*MockStore)(nil).GetConnectionAction), arg0, arg1)
+}
+
+// GetConnectionDomain mocks base method.
+func (m *MockStore) GetConnectionDomain(arg0 context.Context, arg1 models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConnectionDomain", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConnectionDomain indicates an expected call of GetConnectionDomain.
+func (mr *MockStoreMockRecorder) GetConnectionDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConnectionDomain", reflect.TypeOf((*MockStore)(nil).GetConnectionDomain), arg0, arg1)
+}
+
 // GetUser mocks base method.
 func (m *MockStore) GetUser(arg0 context.Context, arg1 models.User) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -137,6 +269,20 @@ func (mr *MockStoreMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), arg0, arg1)
 }
 
+// IncrementConnectionAuthFailures mocks base method.
+func (m *MockStore) IncrementConnectionAuthFailures(ctx context.Context, p models.Connection, failures, lockouts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementConnectionAuthFailures", ctx, p, failures, lockouts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementConnectionAuthFailures indicates an expected call of IncrementConnectionAuthFailures.
+func (mr *MockStoreMockRecorder) IncrementConnectionAuthFailures(ctx, p, failures, lockouts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementConnectionAuthFailures", reflect.TypeOf((*MockStore)(nil).IncrementConnectionAuthFailures), ctx, p, failures, lockouts)
+}
+
 // ListAllConnections mocks base method.
 func (m *MockStore) ListAllConnections(ctx context.Context) ([]models.Connection, error) {
 	m.ctrl.T.Helper()
@@ -152,6 +298,66 @@ func (mr *MockStoreMockRecorder) ListAllConnections(ctx interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllConnections", reflect.TypeOf((*MockStore)(nil).ListAllConnections), ctx)
 }
 
+// ListConnectionCredentials mocks base method.
+func (m *MockStore) ListConnectionCredentials(arg0 context.Context, arg1 models.ConnectionCredential) ([]models.ConnectionCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConnectionCredentials", arg0, arg1)
+	ret0, _ := ret[0].([]models.ConnectionCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConnectionCredentials indicates an expected call of ListConnectionCredentials.
+func (mr *MockStoreMockRecorder) ListConnectionCredentials(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnectionCredentials", reflect.TypeOf((*MockStore)(nil).ListConnectionCredentials), arg0, arg1)
+}
+
+// ListConnectionDomains mocks base method.
+func (m *MockStore) ListConnectionDomains(arg0 context.Context, arg1 models.ConnectionDomain) ([]models.ConnectionDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConnectionDomains", arg0, arg1)
+	ret0, _ := ret[0].([]models.ConnectionDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConnectionDomains indicates an expected call of ListConnectionDomains.
+func (mr *MockStoreMockRecorder) ListConnectionDomains(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnectionDomains", reflect.TypeOf((*MockStore)(nil).ListConnectionDomains), arg0, arg1)
+}
+
+// ListConnectionUsage mocks base method.
+func (m *MockStore) ListConnectionUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) ([]models.ConnectionUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConnectionUsage", ctx, p, from, to)
+	ret0, _ := ret[0].([]models.ConnectionUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConnectionUsage indicates an expected call of ListConnectionUsage.
+func (mr *MockStoreMockRecorder) ListConnectionUsage(ctx, p, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnectionUsage", reflect.TypeOf((*MockStore)(nil).ListConnectionUsage), ctx, p, from, to)
+}
+
+// ListConnectionTunnels mocks base method.
+func (m *MockStore) ListConnectionTunnels(arg0 context.Context, arg1 models.ConnectionTunnel) ([]models.ConnectionTunnel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConnectionTunnels", arg0, arg1)
+	ret0, _ := ret[0].([]models.ConnectionTunnel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConnectionTunnels indicates an expected call of ListConnectionTunnels.
+func (mr *MockStoreMockRecorder) ListConnectionTunnels(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnectionTunnels", reflect.TypeOf((*MockStore)(nil).ListConnectionTunnels), arg0, arg1)
+}
+
 // ListConnections mocks base method.
 func (m *MockStore) ListConnections(arg0 context.Context, arg1 models.Connection) ([]models.Connection, error) {
 	m.ctrl.T.Helper()
@@ -167,6 +373,51 @@ func (mr *MockStoreMockRecorder) ListConnections(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnections", reflect.TypeOf((*MockStore)(nil).ListConnections), arg0, arg1)
 }
 
+// ListConnectionsByHostnameSuffix mocks base method.
+func (m *MockStore) ListConnectionsByHostnameSuffix(ctx context.Context, suffix string) ([]models.Connection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConnectionsByHostnameSuffix", ctx, suffix)
+	ret0, _ := ret[0].([]models.Connection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConnectionsByHostnameSuffix indicates an expected call of ListConnectionsByHostnameSuffix.
+func (mr *MockStoreMockRecorder) ListConnectionsByHostnameSuffix(ctx, suffix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConnectionsByHostnameSuffix", reflect.TypeOf((*MockStore)(nil).ListConnectionsByHostnameSuffix), ctx, suffix)
+}
+
+// ListGateways mocks base method.
+func (m *MockStore) ListGateways(arg0 context.Context) ([]models.Gateway, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGateways", arg0)
+	ret0, _ := ret[0].([]models.Gateway)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGateways indicates an expected call of ListGateways.
+func (mr *MockStoreMockRecorder) ListGateways(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGateways", reflect.TypeOf((*MockStore)(nil).ListGateways), arg0)
+}
+
+// ListPendingConnectionDomains mocks base method.
+func (m *MockStore) ListPendingConnectionDomains(ctx context.Context) ([]models.ConnectionDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingConnectionDomains", ctx)
+	ret0, _ := ret[0].([]models.ConnectionDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingConnectionDomains indicates an expected call of ListPendingConnectionDomains.
+func (mr *MockStoreMockRecorder) ListPendingConnectionDomains(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingConnectionDomains", reflect.TypeOf((*MockStore)(nil).ListPendingConnectionDomains), ctx)
+}
+
 // ListUsers mocks base method.
 func (m *MockStore) ListUsers(arg0 context.Context, arg1 models.User) ([]models.User, error) {
 	m.ctrl.T.Helper()
@@ -196,6 +447,21 @@ func (mr *MockStoreMockRecorder) RawDB() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RawDB", reflect.TypeOf((*MockStore)(nil).RawDB))
 }
 
+// ReplaceConnectionCredential mocks base method.
+func (m *MockStore) ReplaceConnectionCredential(arg0 context.Context, arg1 models.ConnectionCredential) (*models.ConnectionCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceConnectionCredential", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceConnectionCredential indicates an expected call of ReplaceConnectionCredential.
+func (mr *MockStoreMockRecorder) ReplaceConnectionCredential(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceConnectionCredential", reflect.TypeOf((*MockStore)(nil).ReplaceConnectionCredential), arg0, arg1)
+}
+
 // Status mocks base method.
 func (m *MockStore) Status() (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +491,21 @@ func (mr *MockStoreMockRecorder) SubscribeChanges(ctx, callback interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeChanges", reflect.TypeOf((*MockStore)(nil).SubscribeChanges), ctx, callback)
 }
 
+// SumUserUsage mocks base method.
+func (m *MockStore) SumUserUsage(ctx context.Context, p models.ConnectionUsage, from, to time.Time) (*models.ConnectionUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumUserUsage", ctx, p, from, to)
+	ret0, _ := ret[0].(*models.ConnectionUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumUserUsage indicates an expected call of SumUserUsage.
+func (mr *MockStoreMockRecorder) SumUserUsage(ctx, p, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumUserUsage", reflect.TypeOf((*MockStore)(nil).SumUserUsage), ctx, p, from, to)
+}
+
 // UpdateConnection mocks base method.
 func (m *MockStore) UpdateConnection(arg0 context.Context, arg1 models.Connection) (*models.Connection, error) {
 	m.ctrl.T.Helper()
@@ -240,18 +521,104 @@ func (mr *MockStoreMockRecorder) UpdateConnection(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnection", reflect.TypeOf((*MockStore)(nil).UpdateConnection), arg0, arg1)
 }
 
+// UpdateConnectionAction mocks base method.
+func (m *MockStore) UpdateConnectionAction(arg0 context.Context, arg1 models.ConnectionAction) (*models.ConnectionAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConnectionAction", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConnectionAction indicates an expected call of UpdateConnectionAction.
+func (mr *MockStoreMockRecorder) UpdateConnectionAction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionAction", reflect.TypeOf((*MockStore)(nil).UpdateConnectionAction), arg0, arg1)
+}
+
+// UpdateConnectionAgent mocks base method.
+func (m *MockStore) UpdateConnectionAgent(ctx context.Context, p models.Connection, agent models.ConnectionAgent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConnectionAgent", ctx, p, agent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateConnectionAgent indicates an expected call of UpdateConnectionAgent.
+func (mr *MockStoreMockRecorder) UpdateConnectionAgent(ctx, p, agent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionAgent", reflect.TypeOf((*MockStore)(nil).UpdateConnectionAgent), ctx, p, agent)
+}
+
+// UpdateConnectionCredentialLastUsed mocks base method.
+func (m *MockStore) UpdateConnectionCredentialLastUsed(arg0 context.Context, arg1 models.ConnectionCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConnectionCredentialLastUsed", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateConnectionCredentialLastUsed indicates an expected call of UpdateConnectionCredentialLastUsed.
+func (mr *MockStoreMockRecorder) UpdateConnectionCredentialLastUsed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionCredentialLastUsed", reflect.TypeOf((*MockStore)(nil).UpdateConnectionCredentialLastUsed), arg0, arg1)
+}
+
+// UpdateConnectionDomain mocks base method.
+func (m *MockStore) UpdateConnectionDomain(arg0 context.Context, arg1 models.ConnectionDomain) (*models.ConnectionDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConnectionDomain", arg0, arg1)
+	ret0, _ := ret[0].(*models.ConnectionDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConnectionDomain indicates an expected call of UpdateConnectionDomain.
+func (mr *MockStoreMockRecorder) UpdateConnectionDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionDomain", reflect.TypeOf((*MockStore)(nil).UpdateConnectionDomain), arg0, arg1)
+}
+
 // UpdateConnectionLastSeen mocks base method.
-func (m *MockStore) UpdateConnectionLastSeen(arg0 context.Context, arg1 models.Connection, arg2 models.ConnectionState) error {
+func (m *MockStore) UpdateConnectionLastSeen(ctx context.Context, p models.Connection, tunnel models.ConnectionTunnel) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateConnectionLastSeen", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "UpdateConnectionLastSeen", ctx, p, tunnel)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateConnectionLastSeen indicates an expected call of UpdateConnectionLastSeen.
-func (mr *MockStoreMockRecorder) UpdateConnectionLastSeen(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) UpdateConnectionLastSeen(ctx, p, tunnel interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionLastSeen", reflect.TypeOf((*MockStore)(nil).UpdateConnectionLastSeen), ctx, p, tunnel)
+}
+
+// UpdateConnectionsLastUsed mocks base method.
+func (m *MockStore) UpdateConnectionsLastUsed(ctx context.Context, ids []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConnectionsLastUsed", ctx, ids)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateConnectionsLastUsed indicates an expected call of UpdateConnectionsLastUsed.
+func (mr *MockStoreMockRecorder) UpdateConnectionsLastUsed(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionsLastUsed", reflect.TypeOf((*MockStore)(nil).UpdateConnectionsLastUsed), ctx, ids)
+}
+
+// UpdateGatewayLastSeen mocks base method.
+func (m *MockStore) UpdateGatewayLastSeen(arg0 context.Context, arg1 models.Gateway) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGatewayLastSeen", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGatewayLastSeen indicates an expected call of UpdateGatewayLastSeen.
+func (mr *MockStoreMockRecorder) UpdateGatewayLastSeen(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConnectionLastSeen", reflect.TypeOf((*MockStore)(nil).UpdateConnectionLastSeen), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGatewayLastSeen", reflect.TypeOf((*MockStore)(nil).UpdateGatewayLastSeen), arg0, arg1)
 }
 
 // UpdateUser mocks base method.
@@ -49,5 +49,5 @@ func WriteErrorConflict(w http.ResponseWriter, serverErr error) {
 func WriteErrorConflictWithReason(w http.ResponseWriter, userError, serverErr error) {
 	correlationID := correlationID(uuid.New().String())
 	klog.ErrorS(serverErr, userError.Error(), correlationIDKey, correlationID)
-	utilerror.WriteCloudError(w, utilerror.NewCloudError(http.StatusUnauthorized, utilerror.CloudErrorCodeUnauthorized, "Error: %s. %s: %s", userError.Error(), correlationIDKey, correlationID))
+	utilerror.WriteCloudError(w, utilerror.NewCloudError(http.StatusConflict, utilerror.CloudErrorCodeConflict, "Error: %s. %s: %s", userError.Error(), correlationIDKey, correlationID))
 }
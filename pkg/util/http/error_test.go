@@ -0,0 +1,26 @@
+package utilhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	utilerror "github.com/faroshq/faros-ingress/pkg/util/error"
+)
+
+func TestWriteErrorConflictWithReason(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteErrorConflictWithReason(w, fmt.Errorf("hostname already taken"), nil)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var cloudErr utilerror.CloudError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&cloudErr))
+	require.NotNil(t, cloudErr.CloudErrorBody)
+	assert.Equal(t, utilerror.CloudErrorCodeConflict, cloudErr.Code)
+	assert.Contains(t, cloudErr.Message, "hostname already taken")
+}
@@ -0,0 +1,23 @@
+package utilpassword
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+)
+
+var ErrKeyMismatch = errors.New("key does not match hash")
+
+// GenerateKeyHash hashes a high entropy key, such as an API key. Unlike
+// passwords these are random, so a fast hash is sufficient.
+func GenerateKeyHash(key []byte) []byte {
+	hash := sha256.Sum256(key)
+	return hash[:]
+}
+
+func CompareKeyHash(key, hash []byte) error {
+	if subtle.ConstantTimeCompare(GenerateKeyHash(key), hash) != 1 {
+		return ErrKeyMismatch
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package utilproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// Config configures outbound proxy connections are dialed through
+type Config struct {
+	// URL of the proxy with http, https or socks5 scheme and optional
+	// user:password credentials. When empty, HTTPS_PROXY, HTTP_PROXY and
+	// NO_PROXY environment variables are used.
+	URL string
+	// NoProxy lists hosts dialed directly in NO_PROXY format. Used with URL only.
+	NoProxy string
+	// Authorization is sent as Proxy-Authorization header of CONNECT requests
+	// instead of Basic credentials from URL, e.g. "Bearer <token>".
+	Authorization string
+}
+
+// Dialer dials connections through the proxy configured for the target
+// address, tunnelling them with HTTP CONNECT or SOCKS5.
+type Dialer struct {
+	proxyFunc     func(*url.URL) (*url.URL, error)
+	authorization string
+	direct        *net.Dialer
+}
+
+// NewDialer returns Dialer for the configuration
+func NewDialer(cfg Config) (*Dialer, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if cfg.URL != "" {
+		if _, err := parseProxyURL(cfg.URL); err != nil {
+			return nil, err
+		}
+		proxyConfig = &httpproxy.Config{
+			HTTPProxy:  cfg.URL,
+			HTTPSProxy: cfg.URL,
+			NoProxy:    cfg.NoProxy,
+		}
+	}
+
+	return &Dialer{
+		proxyFunc:     proxyConfig.ProxyFunc(),
+		authorization: cfg.Authorization,
+		direct: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}, nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", u.Scheme)
+	}
+	return u, nil
+}
+
+// DialContext dials addr through the proxy, or directly if no proxy applies to it
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	// tunnels are dialed to https endpoints, so HTTPS_PROXY applies
+	proxyURL, err := d.proxyFunc(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return d.direct.DialContext(ctx, network, addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5":
+		return d.dialSOCKS5(ctx, proxyURL, network, addr)
+	case "http", "https":
+		return d.dialConnect(ctx, proxyURL, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+}
+
+// DialTLSContext dials addr through the proxy and starts TLS over the connection
+func (d *Dialer) DialTLSContext(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (d *Dialer) dialSOCKS5(ctx context.Context, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", hostPort(proxyURL), auth, d.direct)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+}
+
+// dialConnect opens tunnel to addr with HTTP CONNECT request to the proxy
+func (d *Dialer) dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := d.direct.DialContext(ctx, "tcp", hostPort(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// bound the CONNECT exchange by the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if authorization := d.proxyAuthorization(proxyURL); authorization != "" {
+		req.Header.Set("Proxy-Authorization", authorization)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// body of the successful response is the tunnel, so it is not closed
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Host, addr, resp.Status)
+	}
+
+	// proxy may send tunnelled data right after its response
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// proxyAuthorization returns Proxy-Authorization header value: the configured
+// one, or Basic credentials of the proxy URL
+func (d *Dialer) proxyAuthorization(proxyURL *url.URL) string {
+	if d.authorization != "" {
+		return d.authorization
+	}
+	if proxyURL.User == nil {
+		return ""
+	}
+	password, _ := proxyURL.User.Password()
+	credentials := proxyURL.User.Username() + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+// hostPort returns proxy address with the default port of its scheme
+func hostPort(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	switch proxyURL.Scheme {
+	case "https":
+		return net.JoinHostPort(proxyURL.Hostname(), "443")
+	case "socks5":
+		return net.JoinHostPort(proxyURL.Hostname(), "1080")
+	default:
+		return net.JoinHostPort(proxyURL.Hostname(), "80")
+	}
+}
+
+// bufferedConn reads data buffered while reading the CONNECT response first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,232 @@
+package utilproxy
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is an in-process HTTP CONNECT proxy sending every tunnel to target
+type connectProxy struct {
+	target        string
+	authorization string
+
+	mu      sync.Mutex
+	tunnels []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.authorization != "" && r.Header.Get("Proxy-Authorization") != p.authorization {
+		http.Error(w, "", http.StatusProxyAuthRequired)
+		return
+	}
+
+	p.mu.Lock()
+	p.tunnels = append(p.tunnels, r.Host)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	pipe(conn, buf, upstream)
+}
+
+func (p *connectProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.tunnels...)
+}
+
+func pipe(conn net.Conn, r io.Reader, upstream net.Conn) {
+	go func() {
+		io.Copy(upstream, r)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+// socks5Proxy is an in-process SOCKS5 proxy with username/password
+// authentication sending every connection to target
+func socks5Proxy(t *testing.T, target, user, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				buf := make([]byte, 512)
+				// greeting: version, methods
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					conn.Close()
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 2}) // username/password
+				// auth: version, user, password
+				io.ReadFull(conn, buf[:2])
+				gotUser := make([]byte, buf[1])
+				io.ReadFull(conn, gotUser)
+				io.ReadFull(conn, buf[:1])
+				gotPassword := make([]byte, buf[0])
+				io.ReadFull(conn, gotPassword)
+				if string(gotUser) != user || string(gotPassword) != password {
+					conn.Write([]byte{1, 1})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{1, 0})
+				// request: version, command, reserved, address type, address, port
+				io.ReadFull(conn, buf[:4])
+				switch buf[3] {
+				case 1:
+					io.ReadFull(conn, buf[:4])
+				case 3:
+					io.ReadFull(conn, buf[:1])
+					io.ReadFull(conn, buf[:buf[0]])
+				case 4:
+					io.ReadFull(conn, buf[:16])
+				}
+				io.ReadFull(conn, buf[:2])
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				pipe(conn, conn, upstream)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestDialer(t *testing.T) {
+	// example.com is in the certificate of the test server and is sent to it by the proxies
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	target := "https://example.com:" + upstreamURL.Port()
+
+	get := func(t *testing.T, cfg Config) (string, error) {
+		d, err := NewDialer(cfg)
+		require.NoError(t, err)
+		transport := upstream.Client().Transport.(*http.Transport).Clone()
+		transport.DialContext = d.DialContext
+		resp, err := (&http.Client{Transport: transport}).Get(target)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	t.Run("should tunnel through CONNECT proxy with basic auth", func(t *testing.T) {
+		p := &connectProxy{target: upstreamURL.Host, authorization: "Basic dXNlcjpzZWNyZXQ="}
+		proxy := httptest.NewServer(p)
+		defer proxy.Close()
+
+		body, err := get(t, Config{URL: "http://user:secret@" + proxy.Listener.Addr().String()})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", body)
+		assert.Equal(t, []string{"example.com:" + upstreamURL.Port()}, p.seen())
+
+		_, err = get(t, Config{URL: "http://user:wrong@" + proxy.Listener.Addr().String()})
+		assert.ErrorContains(t, err, "407")
+	})
+
+	t.Run("should send configured authorization header", func(t *testing.T) {
+		p := &connectProxy{target: upstreamURL.Host, authorization: "Bearer token"}
+		proxy := httptest.NewServer(p)
+		defer proxy.Close()
+
+		body, err := get(t, Config{URL: proxy.URL, Authorization: "Bearer token"})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", body)
+	})
+
+	t.Run("should skip proxy for no proxy hosts", func(t *testing.T) {
+		p := &connectProxy{target: upstreamURL.Host}
+		proxy := httptest.NewServer(p)
+		defer proxy.Close()
+
+		dial := func(noProxy string) {
+			d, err := NewDialer(Config{URL: proxy.URL, NoProxy: noProxy})
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			// example.com is reachable only through the proxy, direct dial result does not matter
+			conn, err := d.DialContext(ctx, "tcp", "example.com:"+upstreamURL.Port())
+			if err == nil {
+				conn.Close()
+			}
+		}
+
+		dial("example.com")
+		assert.Empty(t, p.seen())
+		dial("other.example.org")
+		assert.Len(t, p.seen(), 1)
+	})
+
+	t.Run("should use proxy from environment", func(t *testing.T) {
+		p := &connectProxy{target: upstreamURL.Host}
+		proxy := httptest.NewServer(p)
+		defer proxy.Close()
+		t.Setenv("HTTPS_PROXY", proxy.URL)
+		t.Setenv("NO_PROXY", "")
+
+		body, err := get(t, Config{})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", body)
+		assert.Len(t, p.seen(), 1)
+	})
+
+	t.Run("should tunnel through SOCKS5 proxy", func(t *testing.T) {
+		addr := socks5Proxy(t, upstreamURL.Host, "user", "secret")
+
+		body, err := get(t, Config{URL: "socks5://user:secret@" + addr})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", body)
+
+		_, err = get(t, Config{URL: "socks5://user:wrong@" + addr})
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unsupported proxy", func(t *testing.T) {
+		_, err := NewDialer(Config{URL: "ftp://proxy"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,99 @@
+package utiltunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/net/http2"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+	utilhttp "github.com/faroshq/faros-ingress/pkg/util/http"
+	utilproxy "github.com/faroshq/faros-ingress/pkg/util/proxy"
+)
+
+// GatewayPath is the path of the gateway API tunnels attach to
+const GatewayPath = "/api/v1alpha1/proxy"
+
+// APIHTTPClient returns client for the API dialing through the proxy
+func APIHTTPClient(config *tls.Config, dialer *utilproxy.Dialer) *utilhttp.Client {
+	return &utilhttp.Client{
+		Client: &http.Client{
+			Timeout: 120 * time.Second,
+			Transport: &http.Transport{
+				DisableKeepAlives:     true,
+				TLSClientConfig:       config,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 120 * time.Second,
+				ExpectContinueTimeout: 120 * time.Second,
+				ForceAttemptHTTP2:     true,
+			},
+		},
+	}
+}
+
+// Client returns client tunnels to gateways are created with, dialing
+// through the proxy
+func Client(config *tls.Config, dialer *utilproxy.Dialer) *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		TLSClientConfig:    config,
+		DisableCompression: true,
+		AllowHTTP:          false,
+		DialTLSContext:     dialer.DialTLSContext,
+	}}
+}
+
+// Until calls f, which keeps the tunnel connected, again with exponential
+// backoff until the context is done
+func Until(ctx context.Context, f func()) {
+	var (
+		initBackoff   = time.Second
+		maxBackoff    = time.Minute
+		resetDuration = time.Second * 2
+		backoffFactor = 2.0
+		jitter        = 1.0
+		clock         = &clock.RealClock{}
+		sliding       = true
+	)
+	backoffMgr := wait.NewExponentialBackoffManager(initBackoff, maxBackoff, resetDuration, backoffFactor, jitter, clock)
+
+	wait.BackoffUntil(f, backoffMgr, sliding, ctx.Done())
+}
+
+// ActionFunc executes the action, filling the result. Returned func is run
+// once the result is acknowledged, e.g. to close the tunnel.
+type ActionFunc func(action api.ConnectionAction, result *api.ConnectionAction) (after func(), err error)
+
+// HandleAction executes remote control command sent by the gateway and
+// acknowledges it over the tunnel. Action failing with error is
+// acknowledged as failed.
+func HandleAction(logger logr.Logger, l *h2rev2.Listener, action api.ConnectionAction, execute ActionFunc) {
+	result := action
+	result.Status = api.ConnectionActionSucceeded
+
+	after, err := execute(action, &result)
+	if err != nil {
+		result.Status = api.ConnectionActionFailed
+		result.Message = err.Error()
+	}
+
+	if err := l.Acknowledge(result); err != nil {
+		logger.Error(err, "failed to acknowledge action", "action", action.Type, "id", action.ID)
+	}
+
+	if after != nil {
+		after()
+	}
+}
+
+// NotSupportedError is returned for actions the listener does not execute
+func NotSupportedError(action api.ConnectionActionType) error {
+	return fmt.Errorf("action '%s' is not supported", action)
+}
@@ -0,0 +1,70 @@
+package utiltunnel
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2"
+
+	"github.com/faroshq/faros-ingress/pkg/api"
+	"github.com/faroshq/faros-ingress/pkg/h2rev2"
+)
+
+func TestHandleAction(t *testing.T) {
+	results := make(chan api.ConnectionAction, 1)
+	pool := h2rev2.NewReversePool(h2rev2.PoolOptions{
+		Hooks: h2rev2.Hooks{
+			OnActionResult: func(id string, action api.ConnectionAction) { results <- action },
+		},
+	})
+
+	gateway := httptest.NewUnstartedServer(pool)
+	gateway.EnableHTTP2 = true
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	l, err := h2rev2.NewListener(gateway.Client(), gateway.URL, "id", h2rev2.ListenerOptions{Transport: h2rev2.TransportHTTP2})
+	require.NoError(t, err)
+	defer l.Close()
+	require.Eventually(t, func() bool { return pool.Replicas("id") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	result := func(t *testing.T) api.ConnectionAction {
+		select {
+		case action := <-results:
+			return action
+		case <-time.After(5 * time.Second):
+			t.Fatal("action not acknowledged")
+		}
+		return api.ConnectionAction{}
+	}
+
+	t.Run("should acknowledge result and run after", func(t *testing.T) {
+		ran := false
+		HandleAction(klog.Background(), l, api.ConnectionAction{ID: "action", Type: api.ConnectionActionDiagnostics},
+			func(action api.ConnectionAction, result *api.ConnectionAction) (func(), error) {
+				result.Diagnostics = map[string]string{"key": "value"}
+				return func() { ran = true }, nil
+			})
+
+		assert.True(t, ran)
+		action := result(t)
+		assert.Equal(t, "action", action.ID)
+		assert.Equal(t, api.ConnectionActionSucceeded, action.Status)
+		assert.Equal(t, map[string]string{"key": "value"}, action.Diagnostics)
+	})
+
+	t.Run("should acknowledge error as failure", func(t *testing.T) {
+		HandleAction(klog.Background(), l, api.ConnectionAction{ID: "action", Type: api.ConnectionActionReconnect},
+			func(action api.ConnectionAction, result *api.ConnectionAction) (func(), error) {
+				return nil, fmt.Errorf("failed")
+			})
+
+		action := result(t)
+		assert.Equal(t, api.ConnectionActionFailed, action.Status)
+		assert.Equal(t, "failed", action.Message)
+	})
+}